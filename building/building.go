@@ -5,6 +5,8 @@ import (
 
 	"github.com/brettschalin/factorio-min-resources/constants"
 	"github.com/brettschalin/factorio-min-resources/data"
+	"github.com/brettschalin/factorio-min-resources/shims"
+	"github.com/brettschalin/factorio-min-resources/shims/slices"
 )
 
 type slots struct {
@@ -16,6 +18,10 @@ type slots struct {
 
 type Building interface {
 	Name() string
+
+	// distinguishes buildings with the same name. 0 is the only one of its kind, the same as
+	// in tas.Build and locations.lua
+	Index() int
 	Slots() *slots
 	Inventory(slot constants.Inventory) Inventory
 	PutModules(modules []string) error
@@ -30,6 +36,7 @@ const (
 	CraftStatusRunning
 	CraftStatusWaitingForInput
 	CraftStatusOutputBlocked
+	CraftStatusNoFuel
 )
 
 type CraftingBuilding interface {
@@ -38,6 +45,10 @@ type CraftingBuilding interface {
 	EnergyUsage() float64
 	CraftingSpeed() float64
 
+	// energy, in joules, stored in the building's fuel inventory and burner. Always 0
+	// for buildings that don't burn fuel
+	Energy() float64
+
 	// the current recipe that's set or (for furnaces) computed based on the input
 	Recipe() *data.Recipe
 
@@ -45,11 +56,134 @@ type CraftingBuilding interface {
 	// as long as the ingredients are present and there's space to put the products
 	Status() CraftStatus
 
-	// applies one crafting cycle, accounting for inventory limitations and
-	// module bonuses, and returns the status of the machine
+	// applies one crafting cycle, accounting for inventory limitations,
+	// fuel and module bonuses, and returns the status of the machine
 	DoCraft() CraftStatus
 }
 
+// New constructs the nth building with the given name. Returns nil for buildings that aren't
+// modeled here, like poles and pipes. Pipes are assumed to connect everything that uses a fluid
+func New(name string, n int) Building {
+	switch {
+	case slices.Contains(constants.Furnaces, name):
+		if spec := data.GetFurnace(name); spec != nil {
+			f := NewFurnace(spec)
+			f.n = n
+			return f
+		}
+	case slices.Contains(constants.AssemblingMachines, name),
+		slices.Contains(constants.ChemicalPlants, name),
+		slices.Contains(constants.Refineries, name):
+		if spec := data.GetAssemblingMachine(name); spec != nil {
+			a := NewAssembler(spec)
+			a.n = n
+			return a
+		}
+	case slices.Contains(constants.Labs, name):
+		if spec := data.GetLab(name); spec != nil {
+			l := NewLab(spec)
+			l.n = n
+			return l
+		}
+	case slices.Contains(constants.Boilers, name):
+		if spec := data.GetBoiler(name); spec != nil {
+			b := NewBoiler(spec)
+			b.n = n
+			return b
+		}
+	case slices.Contains(constants.OffshorePumps, name):
+		if spec := data.GetOffshorePump(name); spec != nil {
+			p := NewOffshorePump(spec)
+			p.n = n
+			return p
+		}
+	case slices.Contains(constants.Pumpjacks, name):
+		if spec := data.GetMiningDrill(name); spec != nil {
+			p := NewPumpjack(spec)
+			p.n = n
+			return p
+		}
+	case slices.Contains(constants.SteamEngines, name):
+		if spec := data.GetGenerator(name); spec != nil {
+			e := NewSteamEngine(spec)
+			e.n = n
+			return e
+		}
+	case slices.Contains(constants.SolarPanels, name):
+		if spec := data.GetSolarPanel(name); spec != nil {
+			p := NewSolarPanel(spec)
+			p.n = n
+			return p
+		}
+	case slices.Contains(constants.RocketSilos, name):
+		if spec := data.GetRocketSilo(name); spec != nil {
+			s := NewRocketSilo(spec)
+			s.n = n
+			return s
+		}
+	}
+	return nil
+}
+
+// Copy returns a copy of the building that doesn't share any inventories with the original,
+// so changes to one don't show up in the other
+func Copy(b Building) Building {
+	switch b := b.(type) {
+	case *Furnace:
+		f := *b
+		f.burner = b.burner.copy()
+		f.input = b.input.copy()
+		f.output = b.output.copy()
+		f.modules = b.modules.copyFor(&f)
+		return &f
+	case *Assembler:
+		a := *b
+		a.burner = b.burner.copy()
+		a.input = b.input.copy()
+		a.output = b.output.copy()
+		a.fluids = make([]*fluidBox, len(b.fluids))
+		for i, fb := range b.fluids {
+			a.fluids[i] = fb.copy()
+		}
+		a.modules = b.modules.copyFor(&a)
+		return &a
+	case *Boiler:
+		bb := *b
+		bb.burner = b.burner.copy()
+		bb.water = b.water.copy()
+		bb.modules = b.modules.copyFor(&bb)
+		return &bb
+	case *Lab:
+		l := *b
+		l.input = b.input.copy()
+		l.modules = b.modules.copyFor(&l)
+		return &l
+	case *OffshorePump:
+		p := *b
+		p.modules = b.modules.copyFor(&p)
+		return &p
+	case *Pumpjack:
+		p := *b
+		p.modules = b.modules.copyFor(&p)
+		return &p
+	case *SteamEngine:
+		e := *b
+		e.modules = b.modules.copyFor(&e)
+		return &e
+	case *SolarPanel:
+		p := *b
+		p.modules = b.modules.copyFor(&p)
+		return &p
+	case *RocketSilo:
+		s := *b
+		s.input = b.input.copy()
+		s.output = b.output.copy()
+		s.modules = b.modules.copyFor(&s)
+		return &s
+	}
+	return b
+}
+
 func putModules(inv *Modules, modules []string) error {
 	if len(modules) == 0 {
 		return nil
@@ -98,11 +232,13 @@ func takeModules(inv *Modules, modules []string) error {
 
 type Assembler struct {
 	Entity *data.AssemblingMachine
+	n      int
 	slots  slots
 
-	fuel   *inventory
+	burner *burner
 	input  *inventory
 	output *inventory
+	fluids []*fluidBox
 
 	recipe *data.Recipe
 
@@ -116,10 +252,10 @@ func NewAssembler(spec *data.AssemblingMachine) *Assembler {
 
 	// vanilla contains no burner assemblers of any kind (only furnaces) but mods might so account for that here
 	var fuelSlot constants.Inventory
-	var fuelInv *inventory
+	var b *burner
 	if spec.IsBurner() {
 		fuelSlot = constants.InventoryFuel
-		fuelInv = newInventory(1, nil)
+		b = newBurner(spec.EnergySource)
 	}
 
 	a := &Assembler{
@@ -130,12 +266,16 @@ func NewAssembler(spec *data.AssemblingMachine) *Assembler {
 			Fuel:    fuelSlot,
 			Modules: constants.InventoryAssemblingMachineModules,
 		},
-		fuel:   fuelInv,
+		burner: b,
 		input:  newInventory(1, nil),
 		output: newInventory(1, nil),
 	}
 	a.modules = &Modules{machine: a, maxSlots: spec.ModuleSpecification.ModuleSlots}
 
+	for _, fb := range spec.FluidBoxes {
+		a.fluids = append(a.fluids, newFluidBox(fb))
+	}
+
 	return a
 }
 
@@ -171,6 +311,28 @@ func (a *Assembler) SetRecipe(recipe *data.Recipe) map[string]int {
 		limits = append(limits, prod.Name)
 	}
 	a.output = newInventory(len(limits), limits)
+
+	// fluid boxes are given to the recipe's fluids in order. Fluid left over from the old recipe is lost
+	var in, out []string
+	for _, ing := range recipe.Ingredients {
+		if ing.IsFluid {
+			in = append(in, ing.Name)
+		}
+	}
+	for _, prod := range recipe.GetResults() {
+		if prod.IsFluid {
+			out = append(out, prod.Name)
+		}
+	}
+	for _, fb := range a.fluids {
+		fb.amount, fb.fluid, fb.filter = 0, "", fb.spec.Filter
+		if fb.input && len(in) > 0 {
+			fb.filter, in = in[0], in[1:]
+		} else if !fb.input && len(out) > 0 {
+			fb.filter, out = out[0], out[1:]
+		}
+	}
+
 	a.recipe = recipe
 	a.status = CraftStatusWaitingForInput
 
@@ -185,6 +347,10 @@ func (a *Assembler) Name() string {
 	return a.Entity.Name
 }
 
+func (a *Assembler) Index() int {
+	return a.n
+}
+
 func (a *Assembler) Slots() *slots {
 	return &a.slots
 }
@@ -192,7 +358,10 @@ func (a *Assembler) Slots() *slots {
 func (a *Assembler) Inventory(slot constants.Inventory) Inventory {
 	switch slot {
 	case constants.InventoryFuel:
-		return a.fuel
+		if a.burner == nil {
+			return nil
+		}
+		return a.burner.fuel
 	case constants.InventoryAssemblingMachineInput:
 		return a.input
 	case constants.InventoryAssemblingMachineOutput:
@@ -223,6 +392,86 @@ func (a *Assembler) CraftingSpeed() float64 {
 	return a.Entity.CraftingSpeed
 }
 
+func (a *Assembler) Energy() float64 {
+	if a.burner == nil {
+		return 0
+	}
+	return a.burner.energy()
+}
+
+// Fluid returns how much of the fluid is in the assembler's fluid boxes
+func (a *Assembler) Fluid(name string) float64 {
+	var n float64
+	for _, fb := range a.fluids {
+		if fb.fluid == name {
+			n += fb.amount
+		}
+	}
+	return n
+}
+
+// PutFluid fills the input fluid boxes and returns how much fit
+func (a *Assembler) PutFluid(name string, amount float64) float64 {
+	var put float64
+	for _, fb := range a.fluids {
+		if fb.input {
+			put += fb.put(name, amount-put)
+		}
+	}
+	return put
+}
+
+// TakeFluid empties the output fluid boxes and returns how much was taken
+func (a *Assembler) TakeFluid(name string, amount float64) float64 {
+	var taken float64
+	for _, fb := range a.fluids {
+		if !fb.input {
+			taken += fb.take(name, amount-taken)
+		}
+	}
+	return taken
+}
+
+// fluidSpace returns how much more of the fluid the output boxes can hold
+func (a *Assembler) fluidSpace(name string) float64 {
+	var n float64
+	for _, fb := range a.fluids {
+		if !fb.input && fb.accepts(name) {
+			n += fb.spec.Capacity() - fb.amount
+		}
+	}
+	return n
+}
+
+// canOutput returns whether one craft's worth of products fits in the output inventory and fluid boxes
+func (a *Assembler) canOutput(rec *data.Recipe) bool {
+	if !a.output.canAdd(rec, 1, false) {
+		return false
+	}
+	for _, p := range rec.GetResults() {
+		if p.IsFluid && a.fluidSpace(p.Name) < float64(p.Amount) {
+			return false
+		}
+	}
+	return true
+}
+
+// putProducts adds one craft's worth of products
+func (a *Assembler) putProducts(rec *data.Recipe) {
+	for _, p := range rec.GetResults() {
+		if !p.IsFluid {
+			_ = a.output.Put(p.Name, p.Amount)
+			continue
+		}
+		left := float64(p.Amount)
+		for _, fb := range a.fluids {
+			if !fb.input {
+				left -= fb.put(p.Name, left)
+			}
+		}
+	}
+}
+
 func (a *Assembler) ProductivityBonus(recipe string) float64 {
 	if a == nil {
 		return 0
@@ -243,16 +492,12 @@ func (a *Assembler) DoCraft() CraftStatus {
 
 	// check productivity bonus output
 	if a.prodBonusProgress >= 1 {
-		if !a.output.canAdd(rec, 1, false) {
+		if !a.canOutput(rec) {
 			a.status = CraftStatusOutputBlocked
 			return CraftStatusOutputBlocked
 		}
 
-		for _, p := range rec.GetResults() {
-			if !p.IsFluid {
-				_ = a.output.Put(p.Name, p.Amount)
-			}
-		}
+		a.putProducts(rec)
 		a.prodBonusProgress -= 1
 	}
 
@@ -260,38 +505,47 @@ func (a *Assembler) DoCraft() CraftStatus {
 	canStart := true
 	for _, ing := range rec.Ingredients {
 		if ing.IsFluid {
-			continue
+			canStart = a.Fluid(ing.Name) >= float64(ing.Amount)
+		} else {
+			canStart = a.input.Count(ing.Name) >= ing.Amount
 		}
-		if a.input.Count(ing.Name) < ing.Amount {
-			canStart = false
+		if !canStart {
 			break
 		}
 	}
 	if !canStart {
+		a.status = CraftStatusWaitingForInput
 		return CraftStatusWaitingForInput
 	}
 
 	// check if we have enough space in the output
-	if !a.output.canAdd(rec, 1, false) {
+	if !a.canOutput(rec) {
 		a.status = CraftStatusOutputBlocked
 		return CraftStatusOutputBlocked
 	}
 
+	// burn enough fuel for one craft
+	if a.burner != nil && !a.burner.consume(craftEnergy(a, rec)) {
+		a.status = CraftStatusNoFuel
+		return CraftStatusNoFuel
+	}
+
 	// take one recipe's worth of input
 	for _, ing := range rec.Ingredients {
-		if ing.IsFluid {
+		if !ing.IsFluid {
+			_ = a.input.Take(ing.Name, ing.Amount)
 			continue
 		}
-		_ = a.input.Take(ing.Name, ing.Amount)
+		left := float64(ing.Amount)
+		for _, fb := range a.fluids {
+			if fb.input {
+				left -= fb.take(ing.Name, left)
+			}
+		}
 	}
 
 	// add one recipe's worth of output
-	for _, prod := range rec.GetResults() {
-		if prod.IsFluid {
-			continue
-		}
-		_ = a.output.Put(prod.Name, prod.Amount)
-	}
+	a.putProducts(rec)
 
 	// increment prod bonus
 	a.prodBonusProgress += a.ProductivityBonus(rec.Name)
@@ -305,11 +559,17 @@ func (a *Assembler) Status() CraftStatus {
 	return a.status
 }
 
+// craftEnergy returns how much energy, in joules, one craft of the recipe takes
+func craftEnergy(m CraftingBuilding, recipe *data.Recipe) float64 {
+	return m.EnergyUsage() * recipe.CraftingTime() / m.CraftingSpeed()
+}
+
 type Furnace struct {
 	Entity *data.Furnace
+	n      int
 	slots  slots
 
-	fuel   *inventory
+	burner *burner
 	input  *inventory
 	output *inventory
 
@@ -323,10 +583,10 @@ type Furnace struct {
 func NewFurnace(spec *data.Furnace) *Furnace {
 	// electric furnaces don't have fuel inputs
 	var fuelSlot constants.Inventory
-	var fuelInv *inventory
+	var b *burner
 	if spec.IsBurner() {
 		fuelSlot = constants.InventoryFuel
-		fuelInv = newInventory(1, nil)
+		b = newBurner(spec.EnergySource)
 	}
 
 	f := &Furnace{
@@ -338,7 +598,7 @@ func NewFurnace(spec *data.Furnace) *Furnace {
 			Modules: constants.InventoryFurnaceModules,
 		},
 
-		fuel:   fuelInv,
+		burner: b,
 		input:  newInventory(1, nil),
 		output: newInventory(1, nil),
 	}
@@ -352,6 +612,10 @@ func (f *Furnace) Name() string {
 	return f.Entity.Name
 }
 
+func (f *Furnace) Index() int {
+	return f.n
+}
+
 func (f *Furnace) Slots() *slots {
 	return &f.slots
 }
@@ -359,7 +623,10 @@ func (f *Furnace) Slots() *slots {
 func (f *Furnace) Inventory(slot constants.Inventory) Inventory {
 	switch slot {
 	case constants.InventoryFuel:
-		return f.fuel
+		if f.burner == nil {
+			return nil
+		}
+		return f.burner.fuel
 	case constants.InventoryFurnaceSource:
 		return f.input
 	case constants.InventoryFurnaceResult:
@@ -413,6 +680,13 @@ func (f *Furnace) CraftingSpeed() float64 {
 	return f.Entity.CraftingSpeed
 }
 
+func (f *Furnace) Energy() float64 {
+	if f.burner == nil {
+		return 0
+	}
+	return f.burner.energy()
+}
+
 func (f *Furnace) ProductivityBonus(recipe string) float64 {
 	if f == nil {
 		return 0
@@ -468,6 +742,12 @@ func (f *Furnace) DoCraft() CraftStatus {
 		return CraftStatusOutputBlocked
 	}
 
+	// burn enough fuel for one craft
+	if f.burner != nil && !f.burner.consume(craftEnergy(f, rec)) {
+		f.status = CraftStatusNoFuel
+		return CraftStatusNoFuel
+	}
+
 	// take one recipe's worth of input
 	for _, ing := range rec.Ingredients {
 		if ing.IsFluid {
@@ -497,16 +777,17 @@ func (f *Furnace) Status() CraftStatus {
 
 type Boiler struct {
 	Entity *data.Boiler
+	n      int
 	slots  slots
-	fuel   *inventory
+	burner *burner
+	water  *fluidBox
 
 	// boilers can't hold modules. This exists to keep compatibility with the Building interface
 	// and is given a max size of zero on initialization
 	modules *Modules
 
-	// I only care about the effective power conversion,
-	// so this will act like a combined boiler/steam engine
-	// where water and fuel directly produce electricity.
+	// I only care about the effective power conversion, so water and fuel
+	// directly produce electricity. Steam engines only cap how fast
 }
 
 func NewBoiler(spec *data.Boiler) *Boiler {
@@ -515,7 +796,11 @@ func NewBoiler(spec *data.Boiler) *Boiler {
 		slots: slots{
 			Fuel: constants.InventoryFuel,
 		},
-		fuel: newInventory(1, nil),
+		burner: newBurner(spec.EnergySource),
+		water:  newFluidBox(spec.FluidBox),
+	}
+	if b.water.filter == "" {
+		b.water.filter = "water"
 	}
 
 	b.modules = &Modules{machine: b, maxSlots: 0}
@@ -527,6 +812,10 @@ func (b *Boiler) Name() string {
 	return b.Entity.Name
 }
 
+func (b *Boiler) Index() int {
+	return b.n
+}
+
 func (b *Boiler) Slots() *slots {
 	return &b.slots
 }
@@ -541,20 +830,77 @@ func (b *Boiler) TakeModules(modules []string) error {
 
 func (b *Boiler) Inventory(slot constants.Inventory) Inventory {
 	if slot == constants.InventoryFuel {
-		return b.fuel
+		return b.burner.fuel
 	}
 	return nil
 }
 
+// Energy returns how much energy, in joules, the boiler can still produce from its fuel
+func (b *Boiler) Energy() float64 {
+	return b.burner.energy()
+}
+
+// steamEnergy returns how many joules one unit of water carries once it's been boiled
+func (b *Boiler) steamEnergy() float64 {
+	target := b.Entity.TargetTemperature
+	if target == 0 {
+		target = 165
+	}
+	// water comes in at 15C and steam holds 200J per degree
+	return 200 * (target - 15)
+}
+
+// Fluid returns how much water is in the boiler. Steam goes straight to the engine so it's never stored
+func (b *Boiler) Fluid(name string) float64 {
+	if b.water.fluid != name {
+		return 0
+	}
+	return b.water.amount
+}
+
+func (b *Boiler) PutFluid(name string, amount float64) float64 {
+	return b.water.put(name, amount)
+}
+
+func (b *Boiler) TakeFluid(name string, amount float64) float64 {
+	return 0
+}
+
+// Generate burns fuel and boils water to produce up to `energy` joules of electricity
+// and returns how much was actually produced
+func (b *Boiler) Generate(energy float64) float64 {
+	energy = shims.Min(energy, b.water.amount*b.steamEnergy())
+
+	e := energy
+	if !b.burner.consume(energy) {
+		e = b.burner.buffer
+		b.burner.buffer = 0
+	}
+	b.water.take(b.water.fluid, e/b.steamEnergy())
+	return e
+}
+
+// MaxPower is how fast the boiler can turn fuel into steam, in watts
+func (b *Boiler) MaxPower() float64 {
+	return float64(b.Entity.EnergyConsumption)
+}
+
 func (b *Boiler) ProductivityBonus(recipe string) float64 {
 	return 0
 }
 
 type Lab struct {
 	Entity  *data.Lab
+	n       int
 	slots   slots
 	input   *inventory
 	modules *Modules
+
+	research *data.Technology
+	status   CraftStatus
+
+	// research units done so far, including extras from productivity bonuses
+	progress float64
 }
 
 func NewLab(spec *data.Lab) *Lab {
@@ -576,6 +922,10 @@ func (l *Lab) Name() string {
 	return l.Entity.Name
 }
 
+func (l *Lab) Index() int {
+	return l.n
+}
+
 func (l *Lab) Slots() *slots {
 	return &l.slots
 }
@@ -600,3 +950,58 @@ func (l *Lab) Inventory(slot constants.Inventory) Inventory {
 	}
 	return nil
 }
+
+// SetResearch changes what the lab is researching. Progress on the previous research is lost
+func (l *Lab) SetResearch(tech *data.Technology) {
+	l.research = tech
+	l.progress = 0
+	l.status = CraftStatusNoRecipe
+	if tech != nil {
+		l.status = CraftStatusWaitingForInput
+	}
+}
+
+func (l *Lab) Research() *data.Technology {
+	return l.research
+}
+
+// Progress returns how many units of the current research this lab has done
+func (l *Lab) Progress() float64 {
+	return l.progress
+}
+
+// UnitTime returns how long, in seconds, one unit of the current research takes
+func (l *Lab) UnitTime() float64 {
+	if l.research == nil {
+		return 0
+	}
+	return float64(l.research.Unit.Time) / l.Entity.ResearchingSpeed
+}
+
+// DoResearch consumes one unit's worth of science packs for the current research and returns
+// the status of the lab. Like crafting, it's assumed to be instant as long as the packs are present
+func (l *Lab) DoResearch() CraftStatus {
+	if l.research == nil {
+		l.status = CraftStatusNoRecipe
+		return CraftStatusNoRecipe
+	}
+
+	for _, ing := range l.research.Unit.Ingredients {
+		if l.input.Count(ing.Name) < ing.Amount {
+			l.status = CraftStatusWaitingForInput
+			return CraftStatusWaitingForInput
+		}
+	}
+	for _, ing := range l.research.Unit.Ingredients {
+		_ = l.input.Take(ing.Name, ing.Amount)
+	}
+
+	l.progress += 1 + l.ProductivityBonus("")
+	l.status = CraftStatusRunning
+	return CraftStatusRunning
+}
+
+// Status returns what happened the last time the lab tried to research a unit
+func (l *Lab) Status() CraftStatus {
+	return l.status
+}
@@ -0,0 +1,84 @@
+package building
+
+import (
+	"sort"
+
+	"github.com/brettschalin/factorio-min-resources/data"
+)
+
+// burner is the energy source of anything that burns fuel. Like the game, fuel items stay in the
+// fuel inventory until they're needed, at which point one is burned and its energy goes into
+// a buffer that crafting draws from
+type burner struct {
+	fuel        *inventory
+	effectivity float64
+
+	// energy left over from fuel that's already been burned, in joules
+	buffer float64
+}
+
+func newBurner(source data.EnergySource) *burner {
+	e := float64(source.Effectivity)
+	if e == 0 {
+		e = 1
+	}
+	return &burner{
+		fuel:        newInventory(1, nil),
+		effectivity: e,
+	}
+}
+
+func (b *burner) copy() *burner {
+	if b == nil {
+		return nil
+	}
+	c := *b
+	c.fuel = b.fuel.copy()
+	return &c
+}
+
+func (b *burner) fuelValue(item string) float64 {
+	i := data.GetItem(item)
+	if i == nil {
+		return 0
+	}
+	return float64(i.FuelValue) * b.effectivity
+}
+
+// energy returns the energy in the buffer plus what's left in the fuel inventory
+func (b *burner) energy() float64 {
+	e := b.buffer
+	for item, n := range b.fuel.data {
+		e += float64(n) * b.fuelValue(item)
+	}
+	return e
+}
+
+// burn moves fuel into the buffer until it holds at least `energy` joules or the fuel
+// runs out. It returns whether there's enough
+func (b *burner) burn(energy float64) bool {
+	// tolerance keeps floating point errors from burning an extra item
+	for b.buffer < energy-1e-6 {
+		items := make([]string, 0, len(b.fuel.data))
+		for item := range b.fuel.data {
+			items = append(items, item)
+		}
+		if len(items) == 0 {
+			return false
+		}
+		sort.Strings(items)
+
+		_ = b.fuel.Take(items[0], 1)
+		b.buffer += b.fuelValue(items[0])
+	}
+	return true
+}
+
+// consume takes `energy` joules from the buffer. Nothing is taken if there's not enough fuel
+func (b *burner) consume(energy float64) bool {
+	if !b.burn(energy) {
+		return false
+	}
+	b.buffer -= energy
+	return true
+}
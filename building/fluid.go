@@ -0,0 +1,196 @@
+package building
+
+import (
+	"github.com/brettschalin/factorio-min-resources/constants"
+	"github.com/brettschalin/factorio-min-resources/data"
+	"github.com/brettschalin/factorio-min-resources/shims"
+)
+
+// how much crude oil a pumpjack on a 100% yield field makes per second, per unit of mining speed
+const crudeOilPerSecond = 10
+
+// fluidBox holds a single fluid. Unlike inventories the amounts aren't whole numbers
+type fluidBox struct {
+	spec   data.FluidBox
+	input  bool
+	filter string
+	fluid  string
+	amount float64
+}
+
+func newFluidBox(spec data.FluidBox) *fluidBox {
+	return &fluidBox{
+		spec:   spec,
+		input:  spec.ProductionType != "output",
+		filter: spec.Filter,
+	}
+}
+
+func (f *fluidBox) copy() *fluidBox {
+	if f == nil {
+		return nil
+	}
+	c := *f
+	return &c
+}
+
+// accepts returns whether the fluid can go in this box
+func (f *fluidBox) accepts(fluid string) bool {
+	if f.filter != "" && f.filter != fluid {
+		return false
+	}
+	return f.amount == 0 || f.fluid == fluid
+}
+
+func (f *fluidBox) put(fluid string, amount float64) float64 {
+	if !f.accepts(fluid) {
+		return 0
+	}
+	amount = shims.Min(amount, f.spec.Capacity()-f.amount)
+	f.fluid = fluid
+	f.amount += amount
+	return amount
+}
+
+func (f *fluidBox) take(fluid string, amount float64) float64 {
+	if f.fluid != fluid {
+		return 0
+	}
+	amount = shims.Min(amount, f.amount)
+	f.amount -= amount
+	return amount
+}
+
+// FluidBuilding is anything with fluid boxes that pipes connect to
+type FluidBuilding interface {
+	Building
+
+	// how much of the fluid is in the building
+	Fluid(name string) float64
+
+	// add fluid to the input boxes, returning how much fit
+	PutFluid(name string, amount float64) float64
+
+	// remove fluid from the output boxes, returning how much there was
+	TakeFluid(name string, amount float64) float64
+}
+
+// FluidSource is a building that makes fluid out of nothing but the ground it's placed on
+type FluidSource interface {
+	Building
+
+	// what fluid the building makes
+	Produces() string
+
+	// how much it makes, in units per second
+	Rate() float64
+}
+
+type OffshorePump struct {
+	Entity  *data.OffshorePump
+	n       int
+	slots   slots
+	modules *Modules
+}
+
+func NewOffshorePump(spec *data.OffshorePump) *OffshorePump {
+	p := &OffshorePump{
+		Entity: spec,
+	}
+	p.modules = &Modules{machine: p, maxSlots: 0}
+	return p
+}
+
+func (p *OffshorePump) Name() string {
+	return p.Entity.Name
+}
+
+func (p *OffshorePump) Index() int {
+	return p.n
+}
+
+func (p *OffshorePump) Slots() *slots {
+	return &p.slots
+}
+
+func (p *OffshorePump) Inventory(slot constants.Inventory) Inventory {
+	return nil
+}
+
+func (p *OffshorePump) PutModules(modules []string) error {
+	return putModules(p.modules, modules)
+}
+
+func (p *OffshorePump) TakeModules(modules []string) error {
+	return takeModules(p.modules, modules)
+}
+
+func (p *OffshorePump) ProductivityBonus(_ string) float64 {
+	return 0
+}
+
+func (p *OffshorePump) Produces() string {
+	return p.Entity.Fluid
+}
+
+func (p *OffshorePump) Rate() float64 {
+	return p.Entity.PumpingSpeed * constants.TicksPerSecond
+}
+
+// Pumpjack is assumed to be placed on a crude oil field with 100% yield
+type Pumpjack struct {
+	Entity  *data.MiningDrill
+	n       int
+	slots   slots
+	modules *Modules
+}
+
+func NewPumpjack(spec *data.MiningDrill) *Pumpjack {
+	p := &Pumpjack{
+		Entity: spec,
+		slots: slots{
+			Modules: constants.InventoryMiningDrillModules,
+		},
+	}
+	p.modules = &Modules{machine: p, maxSlots: spec.ModuleSpecification.ModuleSlots}
+	return p
+}
+
+func (p *Pumpjack) Name() string {
+	return p.Entity.Name
+}
+
+func (p *Pumpjack) Index() int {
+	return p.n
+}
+
+func (p *Pumpjack) Slots() *slots {
+	return &p.slots
+}
+
+func (p *Pumpjack) Inventory(slot constants.Inventory) Inventory {
+	if slot == constants.InventoryMiningDrillModules {
+		return p.modules
+	}
+	return nil
+}
+
+func (p *Pumpjack) PutModules(modules []string) error {
+	return putModules(p.modules, modules)
+}
+
+func (p *Pumpjack) TakeModules(modules []string) error {
+	return takeModules(p.modules, modules)
+}
+
+func (p *Pumpjack) ProductivityBonus(_ string) float64 {
+	return p.modules.ProductivityBonus("")
+}
+
+func (p *Pumpjack) Produces() string {
+	return "crude-oil"
+}
+
+func (p *Pumpjack) Rate() float64 {
+	return crudeOilPerSecond * p.Entity.MiningSpeed * (1 + p.ProductivityBonus(""))
+}
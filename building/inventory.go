@@ -3,6 +3,7 @@ package building
 import (
 	"fmt"
 
+	"github.com/brettschalin/factorio-min-resources/constants"
 	"github.com/brettschalin/factorio-min-resources/data"
 	"github.com/brettschalin/factorio-min-resources/shims/slices"
 )
@@ -84,6 +85,10 @@ func (i *inventory) canAdd(recipe *data.Recipe, amount int, input bool) bool {
 	}
 
 	for _, item := range items {
+		// fluids go in fluid boxes
+		if item.IsFluid {
+			continue
+		}
 		amt := items.Amount(item.Name) * amount
 
 		if len(i.limitations) > 0 && !slices.Contains(i.limitations, item.Name) {
@@ -105,6 +110,45 @@ func newInventory(maxSlots int, limitations []string) *inventory {
 	}
 }
 
+func (i *inventory) copy() *inventory {
+	if i == nil {
+		return nil
+	}
+	c := *i
+	c.data = make(map[string]int, len(i.data))
+	for k, v := range i.data {
+		c.data[k] = v
+	}
+	return &c
+}
+
+// Contents returns everything in the building's inventories. This is what the player gets
+// back (along with the building itself) when it's mined
+func Contents(b Building) map[string]int {
+	out := map[string]int{}
+	seen := map[constants.Inventory]bool{}
+
+	sl := b.Slots()
+	for _, slot := range []constants.Inventory{sl.Input, sl.Output, sl.Fuel, sl.Modules} {
+		if seen[slot] {
+			continue
+		}
+		seen[slot] = true
+
+		switch inv := b.Inventory(slot).(type) {
+		case *inventory:
+			for item, n := range inv.data {
+				out[item] += n
+			}
+		case *Modules:
+			for _, m := range inv.modules {
+				out[m.Name]++
+			}
+		}
+	}
+	return out
+}
+
 type ErrTooManyModules struct {
 	machine  string
 	max, got int
@@ -139,6 +183,14 @@ type Modules struct {
 	limitations []string // what modules we're allowed to add here. Determined by the machine this is part of
 }
 
+// copyFor copies the modules into the given machine
+func (m *Modules) copyFor(machine Building) *Modules {
+	c := *m
+	c.machine = machine
+	c.modules = append([]*data.Module(nil), m.modules...)
+	return &c
+}
+
 func (m Modules) ProductivityBonus(recipe string) float64 {
 	var bonus float64
 
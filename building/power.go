@@ -0,0 +1,152 @@
+package building
+
+import (
+	"github.com/brettschalin/factorio-min-resources/constants"
+	"github.com/brettschalin/factorio-min-resources/data"
+)
+
+// PowerSource is anything that puts electricity on the network
+type PowerSource interface {
+	Building
+
+	// the most power, in watts, the building can produce
+	MaxPower() float64
+}
+
+// IsElectric returns whether the building draws its power from the electric network
+func IsElectric(b Building) bool {
+	switch b := b.(type) {
+	case *Lab:
+		return true
+	case CraftingBuilding:
+		return b.EnergySource().Type == string(constants.FuelCategoryElectric)
+	}
+	return false
+}
+
+// PowerUsage returns how much power, in watts, the building draws while it's working.
+// Always 0 for buildings that don't run on electricity
+func PowerUsage(b Building) float64 {
+	if !IsElectric(b) {
+		return 0
+	}
+	switch b := b.(type) {
+	case *Lab:
+		return float64(b.Entity.EnergyUsage)
+	case CraftingBuilding:
+		return b.EnergyUsage()
+	}
+	return 0
+}
+
+// IsWorking returns whether the building is in the middle of a craft or research unit, which is
+// when electric buildings draw power
+func IsWorking(b Building) bool {
+	switch b := b.(type) {
+	case *Lab:
+		return b.Status() == CraftStatusRunning
+	case CraftingBuilding:
+		return b.Status() == CraftStatusRunning
+	}
+	return false
+}
+
+// SteamEngine turns the steam from boilers into electricity. Steam isn't stored anywhere, the boilers
+// hand their energy straight to the network, so engines only limit how fast that can happen
+type SteamEngine struct {
+	Entity  *data.Generator
+	n       int
+	slots   slots
+	modules *Modules
+}
+
+func NewSteamEngine(spec *data.Generator) *SteamEngine {
+	e := &SteamEngine{
+		Entity: spec,
+	}
+	e.modules = &Modules{machine: e, maxSlots: 0}
+	return e
+}
+
+func (e *SteamEngine) Name() string {
+	return e.Entity.Name
+}
+
+func (e *SteamEngine) Index() int {
+	return e.n
+}
+
+func (e *SteamEngine) Slots() *slots {
+	return &e.slots
+}
+
+func (e *SteamEngine) Inventory(slot constants.Inventory) Inventory {
+	return nil
+}
+
+func (e *SteamEngine) PutModules(modules []string) error {
+	return putModules(e.modules, modules)
+}
+
+func (e *SteamEngine) TakeModules(modules []string) error {
+	return takeModules(e.modules, modules)
+}
+
+func (e *SteamEngine) ProductivityBonus(_ string) float64 {
+	return 0
+}
+
+// MaxPower is how much power the engine makes from a full flow of steam at its maximum temperature
+func (e *SteamEngine) MaxPower() float64 {
+	// steam comes from water at 15C and holds 200J per degree
+	perUnit := 200 * float64(e.Entity.MaxTemp-15)
+	return e.Entity.FluidUsage * constants.TicksPerSecond * perUnit * e.Entity.Effectivity
+}
+
+// SolarPanel makes power for free. Day and night aren't modeled, so it always produces its peak output
+type SolarPanel struct {
+	Entity  *data.SolarPanel
+	n       int
+	slots   slots
+	modules *Modules
+}
+
+func NewSolarPanel(spec *data.SolarPanel) *SolarPanel {
+	p := &SolarPanel{
+		Entity: spec,
+	}
+	p.modules = &Modules{machine: p, maxSlots: 0}
+	return p
+}
+
+func (p *SolarPanel) Name() string {
+	return p.Entity.Name
+}
+
+func (p *SolarPanel) Index() int {
+	return p.n
+}
+
+func (p *SolarPanel) Slots() *slots {
+	return &p.slots
+}
+
+func (p *SolarPanel) Inventory(slot constants.Inventory) Inventory {
+	return nil
+}
+
+func (p *SolarPanel) PutModules(modules []string) error {
+	return putModules(p.modules, modules)
+}
+
+func (p *SolarPanel) TakeModules(modules []string) error {
+	return takeModules(p.modules, modules)
+}
+
+func (p *SolarPanel) ProductivityBonus(_ string) float64 {
+	return 0
+}
+
+func (p *SolarPanel) MaxPower() float64 {
+	return float64(p.Entity.Production)
+}
@@ -0,0 +1,182 @@
+package building
+
+import (
+	"github.com/brettschalin/factorio-min-resources/constants"
+	"github.com/brettschalin/factorio-min-resources/data"
+)
+
+// RocketSilo crafts rocket parts with its fixed recipe. Parts aren't items, they go straight into the
+// rocket, which can be launched once it has as many as the silo needs
+type RocketSilo struct {
+	Entity *data.RocketSilo
+	n      int
+	slots  slots
+
+	input  *inventory
+	output *inventory
+
+	recipe *data.Recipe
+	parts  int
+
+	prodBonusProgress float64
+	status            CraftStatus
+
+	modules *Modules
+}
+
+func NewRocketSilo(spec *data.RocketSilo) *RocketSilo {
+	s := &RocketSilo{
+		Entity: spec,
+		slots: slots{
+			Input:   constants.InventoryRocketSiloInput,
+			Output:  constants.InventoryRocketSiloOutput,
+			Modules: constants.InventoryRocketSiloModules,
+		},
+		recipe: data.GetRecipe(spec.FixedRecipe),
+		status: CraftStatusWaitingForInput,
+	}
+
+	var limits []string
+	if s.recipe != nil {
+		for _, ing := range s.recipe.Ingredients {
+			limits = append(limits, ing.Name)
+		}
+	}
+	s.input = newInventory(len(limits), limits)
+
+	// what the launch sends back, like space science for a satellite
+	s.output = newInventory(spec.RocketResultInventorySize, nil)
+
+	s.modules = &Modules{machine: s, maxSlots: spec.ModuleSpecification.ModuleSlots}
+
+	return s
+}
+
+func (s *RocketSilo) Name() string {
+	return s.Entity.Name
+}
+
+func (s *RocketSilo) Index() int {
+	return s.n
+}
+
+func (s *RocketSilo) Slots() *slots {
+	return &s.slots
+}
+
+func (s *RocketSilo) Inventory(slot constants.Inventory) Inventory {
+	switch slot {
+	case constants.InventoryRocketSiloInput:
+		return s.input
+	case constants.InventoryRocketSiloOutput:
+		return s.output
+	case constants.InventoryRocketSiloModules:
+		return s.modules
+	}
+	return nil
+}
+
+func (s *RocketSilo) PutModules(modules []string) error {
+	return putModules(s.modules, modules)
+}
+
+func (s *RocketSilo) TakeModules(modules []string) error {
+	return takeModules(s.modules, modules)
+}
+
+func (s *RocketSilo) ProductivityBonus(recipe string) float64 {
+	if s == nil || s.recipe == nil || recipe != s.recipe.Name {
+		return 0
+	}
+	return s.modules.ProductivityBonus(recipe)
+}
+
+func (s *RocketSilo) EnergySource() data.EnergySource {
+	return s.Entity.EnergySource
+}
+
+func (s *RocketSilo) EnergyUsage() float64 {
+	return float64(s.Entity.EnergyUsage)
+}
+
+func (s *RocketSilo) CraftingSpeed() float64 {
+	return s.Entity.CraftingSpeed
+}
+
+// silos are always electric
+func (s *RocketSilo) Energy() float64 {
+	return 0
+}
+
+func (s *RocketSilo) Recipe() *data.Recipe {
+	return s.recipe
+}
+
+func (s *RocketSilo) Status() CraftStatus {
+	return s.status
+}
+
+// Parts returns how many parts are in the rocket
+func (s *RocketSilo) Parts() int {
+	return s.parts
+}
+
+// PartsRequired returns how many parts the rocket needs before it can launch
+func (s *RocketSilo) PartsRequired() int {
+	return s.Entity.RocketPartsRequired
+}
+
+// RocketReady returns whether the rocket has all of its parts
+func (s *RocketSilo) RocketReady() bool {
+	return s.parts >= s.PartsRequired()
+}
+
+// Launch sends the rocket off and starts on the next one. Returns false if the rocket isn't ready
+func (s *RocketSilo) Launch() bool {
+	if !s.RocketReady() {
+		return false
+	}
+	s.parts = 0
+	s.status = CraftStatusWaitingForInput
+	return true
+}
+
+// DoCraft makes one rocket part. A finished rocket blocks the silo until it's launched
+func (s *RocketSilo) DoCraft() CraftStatus {
+
+	rec := s.recipe
+	if rec == nil {
+		return CraftStatusNoRecipe
+	}
+
+	if s.RocketReady() {
+		s.status = CraftStatusOutputBlocked
+		return CraftStatusOutputBlocked
+	}
+
+	// check productivity bonus output
+	if s.prodBonusProgress >= 1 {
+		s.parts++
+		s.prodBonusProgress -= 1
+		if s.RocketReady() {
+			s.status = CraftStatusOutputBlocked
+			return CraftStatusOutputBlocked
+		}
+	}
+
+	for _, ing := range rec.Ingredients {
+		if s.input.Count(ing.Name) < ing.Amount {
+			s.status = CraftStatusWaitingForInput
+			return CraftStatusWaitingForInput
+		}
+	}
+
+	for _, ing := range rec.Ingredients {
+		_ = s.input.Take(ing.Name, ing.Amount)
+	}
+	s.parts++
+	s.prodBonusProgress += s.ProductivityBonus(rec.Name)
+
+	s.status = CraftStatusRunning
+	return CraftStatusRunning
+}
@@ -33,6 +33,4 @@ var BaseItems = map[string]bool{
 	"light-oil":     true,
 	"petroleum-gas": true,
 	"solid-fuel":    true,
-	"uranium-235":   true,
-	"uranium-238":   true,
 }
@@ -3,10 +3,12 @@ package calc
 import (
 	"fmt"
 	"log"
+	"math"
 	"os"
 	"testing"
 
 	"github.com/brettschalin/factorio-min-resources/building"
+	"github.com/brettschalin/factorio-min-resources/constants"
 	"github.com/brettschalin/factorio-min-resources/data"
 	"github.com/brettschalin/factorio-min-resources/shims/maps"
 	"github.com/brettschalin/factorio-min-resources/state"
@@ -246,10 +248,12 @@ func TestRecipeFullCost(t *testing.T) {
 func TestRecipeAllIngredients(t *testing.T) {
 
 	testState := &state.State{
-		Assembler: assemblerModules,
-		Chem:      chemPlant,
-		Refinery:  refinery,
-		Furnace:   furnace,
+		Buildings: map[state.BuildingID]building.Building{
+			{Name: assemblerModules.Name()}: assemblerModules,
+			{Name: chemPlant.Name()}:        chemPlant,
+			{Name: refinery.Name()}:         refinery,
+			{Name: furnace.Name()}:          furnace,
+		},
 	}
 
 	var tests = []struct {
@@ -652,6 +656,56 @@ func TestHandcraft(t *testing.T) {
 	}
 }
 
+func TestHandcraftQueue(t *testing.T) {
+	var tests = []struct {
+		item           string
+		amount         uint
+		inventory      map[string]uint
+		expectedCrafts map[string]uint
+	}{
+		{
+			item:   "iron-gear-wheel",
+			amount: 5,
+			inventory: map[string]uint{
+				"iron-plate": 10,
+			},
+			expectedCrafts: map[string]uint{
+				"iron-gear-wheel": 5,
+			},
+		},
+		{
+			item:   "electronic-circuit",
+			amount: 3,
+			inventory: map[string]uint{
+				"iron-plate":   3,
+				"copper-plate": 5,
+				"copper-cable": 1,
+			},
+			expectedCrafts: map[string]uint{
+				"copper-cable":       4,
+				"electronic-circuit": 3,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%d %s", test.amount, test.item), func(tt *testing.T) {
+			_, crafts, err := HandcraftQueue(test.inventory, data.GetRecipe(test.item), test.amount)
+			if err != nil {
+				tt.Fatal(err)
+			}
+
+			actual := map[string]uint{}
+			for r, n := range crafts {
+				actual[r.Name] = n
+			}
+			if !maps.Equal(actual, test.expectedCrafts) {
+				tt.Errorf("wrong crafts. Wanted %v but got %v", test.expectedCrafts, actual)
+			}
+		})
+	}
+}
+
 func TestTechCost(t *testing.T) {
 
 	var tests = []struct {
@@ -720,3 +774,318 @@ func TestTechFullCost(t *testing.T) {
 		}
 	}
 }
+
+func TestSimplex(t *testing.T) {
+
+	var tests = []struct {
+		name     string
+		lp       linearProgram
+		expected []float64
+		err      error
+	}{
+		{
+			// minimize x + y where x + 2y >= 4 and 3x + y >= 6
+			name: "two constraints",
+			lp: linearProgram{
+				cost: []float64{1, 1},
+				constraints: []constraint{
+					{[]float64{1, 2}, constraintGE, 4},
+					{[]float64{3, 1}, constraintGE, 6},
+				},
+			},
+			expected: []float64{1.6, 1.2},
+		},
+		{
+			name: "equality",
+			lp: linearProgram{
+				cost: []float64{2, 1},
+				constraints: []constraint{
+					{[]float64{1, 1}, constraintEQ, 3},
+					{[]float64{0, 1}, constraintLE, 2},
+				},
+			},
+			expected: []float64{1, 2},
+		},
+		{
+			name: "infeasible",
+			lp: linearProgram{
+				cost: []float64{1},
+				constraints: []constraint{
+					{[]float64{1}, constraintGE, 2},
+					{[]float64{1}, constraintLE, 1},
+				},
+			},
+			err: errInfeasible,
+		},
+		{
+			name: "unbounded",
+			lp: linearProgram{
+				cost: []float64{-1},
+				constraints: []constraint{
+					{[]float64{1}, constraintGE, 1},
+				},
+			},
+			err: errUnbounded,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(tt *testing.T) {
+			actual, err := test.lp.solve()
+			if !cmpErr(err, test.err) {
+				tt.Fatalf("wrong error. Wanted %v but got %v", test.err, err)
+			}
+			for i := range test.expected {
+				if math.Abs(actual[i]-test.expected[i]) > 1e-6 {
+					tt.Fatalf("wrong solution. Wanted %v but got %v", test.expected, actual)
+				}
+			}
+		})
+	}
+}
+
+func TestOptimize(t *testing.T) {
+
+	var tests = []struct {
+		name           string
+		want           Items[int]
+		state          *state.State
+		expectedCrafts map[string]int
+		expectedMined  Items[int]
+	}{
+		{
+			name: "no state",
+			want: Items[int]{"iron-gear-wheel": 10},
+			expectedCrafts: map[string]int{
+				"iron-gear-wheel": 10,
+				"iron-plate":      20,
+			},
+			expectedMined: Items[int]{"iron-ore": 20},
+		},
+		{
+			name: "productivity",
+			want: Items[int]{"iron-gear-wheel": 100},
+			state: &state.State{
+				Buildings: map[state.BuildingID]building.Building{
+					{Name: "assembling-machine-2"}: assemblerModules,
+					{Name: "electric-furnace"}:     furnace,
+				},
+			},
+			// 90 crafts make 100.8 gears from 180 plates. 161 crafts make 180.32 plates
+			expectedCrafts: map[string]int{
+				"iron-gear-wheel": 90,
+				"iron-plate":      161,
+			},
+			expectedMined: Items[int]{"iron-ore": 161},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(tt *testing.T) {
+			crafts, mined, err := Optimize(test.want, test.state)
+			if err != nil {
+				tt.Fatal(err)
+			}
+			actual := map[string]int{}
+			for _, c := range crafts {
+				actual[c.Recipe.Name] += c.Count
+			}
+			if !maps.Equal(actual, test.expectedCrafts) {
+				tt.Errorf("wrong crafts. Wanted %v but got %v", test.expectedCrafts, actual)
+			}
+			if !maps.Equal(mined, test.expectedMined) {
+				tt.Errorf("wrong resources mined. Wanted %v but got %v", test.expectedMined, mined)
+			}
+		})
+	}
+}
+
+func TestRecipeCycle(t *testing.T) {
+
+	// builds the graph buildRecDeps would for `want` of "x", where recipe x uses y and y uses x
+	graph := func(rx, ry *data.Recipe, want int) *recipeDependency {
+		var (
+			x   = &recipeDependency{item: "x", recipe: rx}
+			y   = &recipeDependency{item: "y", recipe: ry}
+			ore = &recipeDependency{item: "iron-ore"}
+		)
+		root := &recipeDependency{
+			meta:   true,
+			amount: 1,
+			recipe: &data.Recipe{ResultCount: 1, Ingredients: data.Ingredients{{Name: "x", Amount: want}}},
+			deps:   []*recipeDependency{x},
+		}
+		x.uses = []*recipeDependency{root, y}
+		x.deps = []*recipeDependency{y}
+		y.uses = []*recipeDependency{x}
+		y.deps = []*recipeDependency{x}
+		if rx.Ingredients.Amount("iron-ore") > 0 {
+			x.deps = append(x.deps, ore)
+			ore.uses = []*recipeDependency{x}
+		}
+		return root
+	}
+
+	var tests = []struct {
+		name     string
+		rx, ry   *data.Recipe
+		expected data.Ingredients
+		err      error
+	}{
+		{
+			// every craft of x makes 2, one of which goes back into y
+			name: "solvable",
+			rx: &data.Recipe{
+				Name:        "x",
+				Result:      "x",
+				ResultCount: 2,
+				Ingredients: data.Ingredients{{Name: "y", Amount: 1}, {Name: "iron-ore", Amount: 1}},
+			},
+			ry: &data.Recipe{
+				Name:        "y",
+				Result:      "y",
+				Ingredients: data.Ingredients{{Name: "x", Amount: 1}},
+			},
+			expected: data.Ingredients{
+				{Name: "iron-ore", Amount: 10},
+				{Name: "y", Amount: 10},
+				{Name: "x", Amount: 20},
+			},
+		},
+		{
+			name: "no net production",
+			rx: &data.Recipe{
+				Name:        "x",
+				Result:      "x",
+				Ingredients: data.Ingredients{{Name: "y", Amount: 1}},
+			},
+			ry: &data.Recipe{
+				Name:        "y",
+				Result:      "y",
+				Ingredients: data.Ingredients{{Name: "x", Amount: 1}},
+			},
+			err: &ErrRecipeCycle{Recipes: []string{"x", "y"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(tt *testing.T) {
+			root := graph(test.rx, test.ry, 10)
+			cycles := root.cycles()
+			if len(cycles) != 1 || len(cycles[0]) != 2 {
+				tt.Fatalf("expected one cycle of 2 items, got %v", cycles)
+			}
+
+			actual, err := steadyState(root, cycles, nil)
+			if !cmpErr(err, test.err) {
+				tt.Fatalf("wrong error. Wanted %v but got %v", test.err, err)
+			}
+			if len(actual) != len(test.expected) {
+				tt.Fatalf("wrong amount of ingredients. Wanted %v but got %v", test.expected, actual)
+			}
+			for i, ing := range actual {
+				if ing != test.expected[i] {
+					tt.Errorf("wrong amount/item for index %d. Wanted %d %s but got %d %s",
+						i, test.expected[i].Amount, test.expected[i].Name, ing.Amount, ing.Name)
+				}
+			}
+		})
+	}
+
+	// kovarex-enrichment-process uses more uranium-238 than it makes, so it only works alongside
+	// uranium-processing: 11 crafts of it and 33 of uranium-processing make the 41 uranium-235
+	t.Run("kovarex", func(tt *testing.T) {
+		kovarex := data.GetRecipe("uranium-235")
+		expected := data.Ingredients{
+			{Name: "uranium-ore", Amount: 330},
+			{Name: "uranium-238", Amount: 55},
+			{Name: "uranium-235", Amount: 484},
+		}
+
+		actual, err := RecipeAllIngredients(map[*data.Recipe]int{kovarex: 1}, nil)
+		if err != nil {
+			tt.Fatal(err)
+		}
+		if len(actual) != len(expected) {
+			tt.Fatalf("wrong amount of ingredients. Wanted %v but got %v", expected, actual)
+		}
+		for i, ing := range actual {
+			if ing != expected[i] {
+				tt.Errorf("wrong amount/item for index %d. Wanted %d %s but got %d %s",
+					i, expected[i].Amount, expected[i].Name, ing.Amount, ing.Name)
+			}
+		}
+
+		ing, prod := RecipeFullCost(kovarex, 1, nil)
+		if len(ing) != 1 || ing["uranium-ore"] != 330 {
+			tt.Errorf("expected 330 uranium-ore, got %v", ing)
+		}
+		if len(prod) != 2 || prod["uranium-235"] != 41 || prod["uranium-238"] != 2 {
+			tt.Errorf("expected 41 uranium-235 and 2 uranium-238, got %v", prod)
+		}
+	})
+}
+
+func TestResearchFuelCost(t *testing.T) {
+
+	for _, test := range []struct {
+		name      string
+		buildings []string
+		recipe    string
+		crafting  bool
+		expected  float64
+	}{
+		{
+			name:      "no boiler",
+			buildings: []string{"lab"},
+		},
+		{
+			// 10 units of 10 seconds at 60kW is 6MJ, or 1.5 coal
+			name:      "boiler",
+			buildings: []string{"lab", "boiler", "steam-engine"},
+			expected:  1.5,
+		},
+		{
+			name:      "no steam engine",
+			buildings: []string{"lab", "boiler"},
+		},
+		{
+			name:      "solar covers the lab",
+			buildings: []string{"lab", "boiler", "steam-engine", "solar-panel"},
+		},
+		{
+			// the assembler (75kW) takes 5/9 of the solar, leaving the lab 33.3kW short for 100 seconds
+			name:      "solar shared with an assembler",
+			buildings: []string{"lab", "boiler", "steam-engine", "solar-panel", "assembling-machine-1"},
+			recipe:    "iron-gear-wheel",
+			crafting:  true,
+			expected:  5.0 / 6,
+		},
+		{
+			// an assembler waiting for ingredients doesn't draw anything
+			name:      "idle assembler",
+			buildings: []string{"lab", "boiler", "steam-engine", "solar-panel", "assembling-machine-1"},
+			recipe:    "iron-gear-wheel",
+		},
+	} {
+		t.Run(test.name, func(tt *testing.T) {
+			s := &state.State{}
+			for _, b := range test.buildings {
+				s.ConstructBuilding(b, 0)
+			}
+			if test.recipe != "" {
+				s.Assembler().SetRecipe(data.GetRecipe(test.recipe))
+			}
+			if test.crafting {
+				a := s.Assembler()
+				a.Inventory(constants.InventoryAssemblingMachineInput).Put("iron-plate", 2)
+				if status := a.DoCraft(); status != building.CraftStatusRunning {
+					tt.Fatalf("assembler not crafting: %v", status)
+				}
+			}
+			if fuel := ResearchFuelCost(s, "coal", "automation"); math.Abs(fuel-test.expected) > 1e-9 {
+				tt.Fatalf("expected %g fuel, got %g", test.expected, fuel)
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package calc
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/brettschalin/factorio-min-resources/data"
+	"github.com/brettschalin/factorio-min-resources/shims"
+	"github.com/brettschalin/factorio-min-resources/state"
+)
+
+// ErrRecipeCycle is returned when recipes depend on each other in a way that can't make anything on net
+type ErrRecipeCycle struct {
+	Recipes []string
+}
+
+func (e *ErrRecipeCycle) Error() string {
+	return fmt.Sprintf(`recipe cycle %q has no steady-state solution`, e.Recipes)
+}
+
+// cycles returns the strongly connected components of the dependency graph that contain a cycle,
+// found with Tarjan's algorithm. Each is sorted by item name
+func (r *recipeDependency) cycles() [][]*recipeDependency {
+
+	var (
+		index   = map[*recipeDependency]int{}
+		lowLink = map[*recipeDependency]int{}
+		onStack = map[*recipeDependency]bool{}
+		stack   []*recipeDependency
+		out     [][]*recipeDependency
+		visit   func(*recipeDependency)
+	)
+
+	visit = func(v *recipeDependency) {
+		index[v] = len(index)
+		lowLink[v] = index[v]
+		stack = append(stack, v)
+		onStack[v] = true
+
+		selfLoop := false
+		for _, w := range v.deps {
+			if w == v {
+				selfLoop = true
+			}
+			if _, ok := index[w]; !ok {
+				visit(w)
+				lowLink[v] = shims.Min(lowLink[v], lowLink[w])
+			} else if onStack[w] {
+				lowLink[v] = shims.Min(lowLink[v], index[w])
+			}
+		}
+
+		if lowLink[v] != index[v] {
+			return
+		}
+
+		var scc []*recipeDependency
+		for {
+			w := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[w] = false
+			scc = append(scc, w)
+			if w == v {
+				break
+			}
+		}
+		if len(scc) > 1 || selfLoop {
+			sort.Slice(scc, func(i, j int) bool { return scc[i].item < scc[j].item })
+			out = append(out, scc)
+		}
+	}
+
+	visit(r)
+	return out
+}
+
+// steadyState works out the amounts for a dependency graph with cycles in it. The usual pass in
+// RecipeAllIngredients goes from each item to its ingredients, which never ends when an item is its own
+// ingredient, so instead this solves for the number of crafts of every recipe at once, with each item
+// made at least as fast as it's used. Items in a cycle can be made with any recipe for them, the same as
+// Optimize, since the one in the graph might not make anything on net by itself. Items without a recipe
+// are taken as given, and as few of them as possible are used.
+// The result has the same form as RecipeAllIngredients, ingredients before what they're used in as far as
+// the cycles allow. Base items have the amount used, everything else the amount crafted
+func steadyState(root *recipeDependency, cycles [][]*recipeDependency, state *state.State) (data.Ingredients, error) {
+
+	var (
+		nodes   []*recipeDependency
+		nodeFor = map[string]*recipeDependency{}
+		seen    = map[*recipeDependency]bool{root: true}
+		q       = []*recipeDependency{root}
+		options []craftOption
+		recipes = map[string]bool{}
+		items   []string
+		itemIdx = map[string]int{}
+	)
+
+	addItem := func(item string) {
+		if _, ok := itemIdx[item]; !ok {
+			itemIdx[item] = len(items)
+			items = append(items, item)
+		}
+	}
+
+	for len(q) > 0 {
+		n := q[0]
+		q = q[1:]
+		if !n.meta {
+			nodes = append(nodes, n)
+			nodeFor[n.item] = n
+			addItem(n.item)
+		}
+		for _, d := range n.deps {
+			if !seen[d] {
+				seen[d] = true
+				q = append(q, d)
+			}
+		}
+	}
+
+	// base items are supplied from outside the system
+	supplied := func(n *recipeDependency) bool {
+		return n.recipe == nil || BaseItems[n.item]
+	}
+	free := map[string]bool{}
+
+	inCycle := map[string]bool{}
+	for _, c := range cycles {
+		for _, n := range c {
+			inCycle[n.item] = true
+		}
+	}
+
+	addRecipe := func(r *data.Recipe) {
+		if recipes[r.Name] {
+			return
+		}
+		recipes[r.Name] = true
+
+		var bonus float64
+		if state != nil {
+			bonus = state.GetProductivityBonus(r)
+		}
+		options = append(options, craftOption{recipe: r, bonus: bonus})
+		for _, ing := range r.Ingredients {
+			addItem(ing.Name)
+			// ingredients of the other recipes for an item aren't in the graph yet. Like Optimize, ones that
+			// can't be crafted or mined aren't supplied, so recipes that need them aren't used
+			if nodeFor[ing.Name] == nil && (BaseItems[ing.Name] || data.GetRecipe(ing.Name) != nil) {
+				d := &recipeDependency{item: ing.Name}
+				if !BaseItems[ing.Name] {
+					d.recipe = data.GetRecipe(ing.Name)
+				}
+				nodes = append(nodes, d)
+				nodeFor[ing.Name] = d
+			}
+		}
+		for _, p := range r.GetResults() {
+			addItem(p.Name)
+		}
+	}
+
+	// nodes can be added while going through them
+	for i := 0; i < len(nodes); i++ {
+		n := nodes[i]
+		if supplied(n) {
+			free[n.item] = true
+			continue
+		}
+		addRecipe(n.recipe)
+		if inCycle[n.item] {
+			for _, r := range data.GetRecipes(n.item) {
+				addRecipe(r)
+			}
+		}
+	}
+
+	want := Items[int]{}
+	for _, ing := range root.recipe.Ingredients {
+		want[ing.Name] += ing.Amount
+	}
+
+	// variables: crafts of each recipe, then the amount of each item supplied
+	var (
+		nOpts = len(options)
+		lp    = &linearProgram{cost: make([]float64, nOpts+len(items))}
+	)
+	for j := range options {
+		lp.cost[j] = 1e-6
+	}
+	for i, item := range items {
+		row := make([]float64, nOpts+len(items))
+		for j, o := range options {
+			row[j] -= float64(o.recipe.Ingredients.Amount(item))
+			row[j] += float64(o.recipe.GetResults().Amount(item)) * (1 + o.bonus)
+		}
+		if free[item] {
+			row[nOpts+i] = 1
+			lp.cost[nOpts+i] = 1
+		}
+		lp.addConstraint(row, constraintGE, float64(want[item]))
+	}
+
+	x, err := lp.solve()
+	if err == nil {
+		var counts []int
+		var net Items[float64]
+		counts, net, err = wholeCrafts(options, x, want, items, func(item string) bool { return free[item] })
+		if err == nil {
+			return steadyStateAmounts(nodes, options, counts, net, supplied), nil
+		}
+	}
+
+	names := []string{}
+	for _, c := range cycles {
+		for _, n := range c {
+			if n.recipe != nil {
+				names = append(names, n.recipe.Name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return nil, &ErrRecipeCycle{Recipes: names}
+}
+
+func steadyStateAmounts(nodes []*recipeDependency, options []craftOption, counts []int, net Items[float64], supplied func(*recipeDependency) bool) data.Ingredients {
+
+	out := make(data.Ingredients, 0, len(nodes))
+	for i := len(nodes) - 1; i >= 0; i-- {
+		n := nodes[i]
+		amount := 0
+		if supplied(n) {
+			amount = shims.Max(0, int(math.Ceil(-net[n.item]-1e-6)))
+		} else {
+			// an item in a cycle can be made by more than one recipe
+			for j, o := range options {
+				amount += counts[j] * o.recipe.ProductCount(n.item)
+			}
+		}
+		out = append(out, data.Ingredient{
+			Name:   n.item,
+			Amount: amount,
+		})
+	}
+	return out
+}
@@ -4,6 +4,8 @@ import (
 	"github.com/brettschalin/factorio-min-resources/building"
 	"github.com/brettschalin/factorio-min-resources/constants"
 	"github.com/brettschalin/factorio-min-resources/data"
+	"github.com/brettschalin/factorio-min-resources/shims"
+	"github.com/brettschalin/factorio-min-resources/state"
 )
 
 // TechEnergyCost returns the energy required for the given lab to research the tech
@@ -26,6 +28,50 @@ func BoilerFuelCost(boiler *building.Boiler, fuel string, energy float64) float6
 	return energy / float64(item.FuelValue)
 }
 
+// ResearchFuelCost returns the amount of fuel the boilers in `state` need to burn for its labs to research the tech.
+// The labs split the work by research speed and the solar panels cover their share of what they can while it runs. Returns 0 if there
+// are no boilers, steam engines or labs
+func ResearchFuelCost(state *state.State, fuel string, tech string) float64 {
+	labs, boiler := state.Labs(), state.Boiler()
+	if len(labs) == 0 || boiler == nil {
+		return 0
+	}
+
+	var power, speed float64
+	for _, l := range labs {
+		power += float64(l.Entity.EnergyUsage)
+		speed += l.Entity.ResearchingSpeed
+	}
+	var (
+		solar, others float64
+		engines       bool
+	)
+	for _, id := range state.IDs() {
+		switch b := state.Buildings[id].(type) {
+		case *building.SolarPanel:
+			solar += b.MaxPower()
+		case *building.SteamEngine:
+			engines = true
+		case building.CraftingBuilding:
+			if building.IsWorking(b) {
+				others += building.PowerUsage(b)
+			}
+		}
+	}
+
+	// machines that are crafting draw power alongside the labs, so the labs only get their share of the solar
+	power -= solar * power / (power + others)
+	// a boiler left placed only for its water doesn't burn anything
+	if !engines {
+		return 0
+	}
+
+	t := data.GetTech(tech)
+	seconds := float64(t.Unit.Time) * float64(t.Unit.Count) / speed
+
+	return BoilerFuelCost(boiler, fuel, shims.Max(0, power*seconds))
+}
+
 // RecipesFromFuel returns the number of recipes that can be crafted with the given amount of fuel
 func RecipesFromFuel(m building.CraftingBuilding, recipe *data.Recipe, fuel float64, fuelType string) float64 {
 
@@ -0,0 +1,242 @@
+package calc
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/brettschalin/factorio-min-resources/building"
+	"github.com/brettschalin/factorio-min-resources/data"
+	"github.com/brettschalin/factorio-min-resources/state"
+)
+
+// Craft is a number of crafts of one recipe in one machine. A nil Machine means handcrafting
+type Craft struct {
+	Recipe  *data.Recipe
+	Machine building.CraftingBuilding
+	Count   int
+}
+
+// mineCost returns what gathering one unit of `item` costs, and whether it can be gathered at all.
+// Trees count as a resource patch. Water is free since an offshore pump never runs out
+func mineCost(item string) (float64, bool) {
+	switch {
+	case item == "water":
+		return 0, true
+	case MinableResources[item], MineableFluids[item], item == "wood":
+		return 1, true
+	}
+	return 0, false
+}
+
+// a way to make something: a recipe crafted in a machine (or by hand, if machine is nil)
+type craftOption struct {
+	recipe  *data.Recipe
+	machine building.CraftingBuilding
+	bonus   float64
+}
+
+// craftOptions returns everywhere the recipe can be crafted. With no state, the recipe
+// is assumed craftable somewhere without any productivity bonus
+func craftOptions(recipe *data.Recipe, s *state.State) []craftOption {
+	if s == nil {
+		return []craftOption{{recipe: recipe}}
+	}
+
+	opts := []craftOption{}
+	if recipe.CanHandcraft() {
+		opts = append(opts, craftOption{recipe: recipe})
+	}
+	for _, id := range s.IDs() {
+		var m building.CraftingBuilding
+		switch b := s.Buildings[id].(type) {
+		case *building.Assembler:
+			if b.Entity.CanCraft(recipe) {
+				m = b
+			}
+		case *building.Furnace:
+			if b.Entity.CanCraft(recipe) {
+				m = b
+			}
+		}
+		if m != nil {
+			// the same bonus state.GetProductivityBonus reports for this machine
+			opts = append(opts, craftOption{recipe, m, m.ProductivityBonus(recipe.Name)})
+		}
+	}
+	return opts
+}
+
+// Optimize picks the recipes, and the machines in `state` to craft them in, that make `want` while mining
+// as few resources as possible. Unlike RecipeFullCost it considers every recipe for an item, so oil products,
+// solid fuel and so on can be solved for. The crafts are rounded up to whole numbers, and `mined` is what those
+// crafts need from resource patches.
+// If `state` is nil every recipe is assumed craftable with no productivity bonus
+func Optimize(want Items[int], state *state.State) (crafts []Craft, mined Items[int], err error) {
+
+	var (
+		items   = []string{}
+		itemIdx = map[string]int{}
+		options = []craftOption{}
+		seen    = map[string]bool{}
+		queue   = []string{}
+	)
+
+	for item := range want {
+		queue = append(queue, item)
+	}
+	sort.Strings(queue)
+
+	// find every recipe that could be part of the solution
+	for len(queue) > 0 {
+		item := queue[0]
+		queue = queue[1:]
+		if _, ok := itemIdx[item]; ok {
+			continue
+		}
+		itemIdx[item] = len(items)
+		items = append(items, item)
+
+		if _, ok := mineCost(item); ok {
+			continue
+		}
+
+		for _, r := range data.GetRecipes(item) {
+			if seen[r.Name] {
+				continue
+			}
+			seen[r.Name] = true
+			opts := craftOptions(r, state)
+			if len(opts) == 0 {
+				continue
+			}
+			options = append(options, opts...)
+			for _, ing := range r.Ingredients {
+				queue = append(queue, ing.Name)
+			}
+			for _, p := range r.GetResults() {
+				queue = append(queue, p.Name)
+			}
+		}
+	}
+
+	// variables: one per craft option, then one per item for mining it
+	var (
+		nOpts = len(options)
+		lp    = &linearProgram{cost: make([]float64, nOpts+len(items))}
+		rows  = make([][]float64, len(items))
+	)
+
+	for i := range rows {
+		rows[i] = make([]float64, nOpts+len(items))
+	}
+
+	for j, o := range options {
+		for _, ing := range o.recipe.Ingredients {
+			rows[itemIdx[ing.Name]][j] -= float64(ing.Amount)
+		}
+		for _, p := range o.recipe.GetResults() {
+			rows[itemIdx[p.Name]][j] += float64(p.Amount) * (1 + o.bonus)
+		}
+		// a tiny cost per craft so that recipes that don't save any resources aren't used for nothing
+		lp.cost[j] = 1e-6
+	}
+
+	for i, item := range items {
+		if cost, ok := mineCost(item); ok {
+			rows[i][nOpts+i] = 1
+			lp.cost[nOpts+i] = cost
+		}
+		lp.addConstraint(rows[i], constraintGE, float64(want[item]))
+	}
+
+	x, err := lp.solve()
+	if err == errInfeasible {
+		missing := []string{}
+		for item := range want {
+			missing = append(missing, item)
+		}
+		sort.Strings(missing)
+		return nil, nil, fmt.Errorf("optimize: %v can't be made from the available recipes and machines: %w", missing, err)
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("optimize: %w", err)
+	}
+
+	counts, net, err := wholeCrafts(options, x, want, items, func(item string) bool {
+		_, ok := mineCost(item)
+		return ok
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("optimize: %w", err)
+	}
+
+	for j, o := range options {
+		if counts[j] > 0 {
+			crafts = append(crafts, Craft{Recipe: o.recipe, Machine: o.machine, Count: counts[j]})
+		}
+	}
+
+	mined = Items[int]{}
+	for item, n := range net {
+		if _, ok := mineCost(item); ok && n < -1e-6 {
+			mined[item] = int(math.Ceil(-n - 1e-6))
+		}
+	}
+
+	return crafts, mined, nil
+}
+
+// wholeCrafts rounds the solver's craft counts up to whole numbers. That can leave intermediates short
+// (90 crafts of gears need more plates than ceil(178.6 plates worth of crafts) makes) so crafts are topped
+// up until nothing is missing. Items where `free` returns true come from outside and may go negative.
+// Returns the counts and the net amount of each item made
+func wholeCrafts(options []craftOption, x []float64, want Items[int], items []string, free func(string) bool) ([]int, Items[float64], error) {
+
+	counts := make([]int, len(options))
+	for j := range options {
+		counts[j] = int(math.Ceil(x[j] - 1e-6))
+	}
+
+	for tries := 0; ; tries++ {
+		net := Items[float64]{}
+		for item, n := range want {
+			net[item] -= float64(n)
+		}
+		for j, o := range options {
+			n := counts[j]
+			for _, ing := range o.recipe.Ingredients {
+				net[ing.Name] -= float64(ing.Amount * n)
+			}
+			for _, p := range o.recipe.GetResults() {
+				net[p.Name] += float64(p.Amount*n) * (1 + o.bonus)
+			}
+		}
+
+		short, deficit := "", 0.0
+		for _, item := range items {
+			if !free(item) && net[item] < -1e-6 {
+				short, deficit = item, -net[item]
+				break
+			}
+		}
+		if short == "" {
+			return counts, net, nil
+		}
+		if tries == 1000 {
+			return nil, nil, fmt.Errorf("could not round the crafts of %q to whole numbers", short)
+		}
+
+		// make more with whichever option the solver used most
+		best := -1
+		for j, o := range options {
+			if o.recipe.ProductCount(short) > 0 && (best < 0 || x[j] > x[best]) {
+				best = j
+			}
+		}
+		if best < 0 {
+			return nil, nil, fmt.Errorf("nothing makes %q", short)
+		}
+		o := options[best]
+		counts[best] += int(math.Ceil(deficit/(float64(o.recipe.ProductCount(short))*(1+o.bonus)) - 1e-6))
+	}
+}
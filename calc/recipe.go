@@ -95,6 +95,10 @@ type recipeDependency struct {
 }
 
 func (r *recipeDependency) reset() {
+	// nothing below an unvisited node has been visited either. This also stops at cycles
+	if !r.visited {
+		return
+	}
 	r.visited = false
 	for _, d := range r.deps {
 		d.reset()
@@ -135,9 +139,11 @@ func (r *recipeDependency) iter(f func(*recipeDependency)) {
 	r.reset()
 }
 
-func buildRecDeps(items map[string]int) *recipeDependency {
+// buildRecDeps builds the dependency graph for crafting `items`, along with any cycles in it. With a cycle
+// there's no order to set amounts in, so they're left for RecipeAllIngredients to solve separately
+func buildRecDeps(items map[string]int) (*recipeDependency, [][]*recipeDependency) {
 	if len(items) == 0 {
-		return nil
+		return nil, nil
 	}
 
 	ings := make(data.Ingredients, 0, len(items))
@@ -191,8 +197,13 @@ func buildRecDeps(items map[string]int) *recipeDependency {
 		}
 	}
 
-	// set amounts of each item
 	root.reset()
+
+	if cycles := root.cycles(); len(cycles) > 0 {
+		return root, cycles
+	}
+
+	// set amounts of each item
 	root.iter(func(r *recipeDependency) {
 		if r.meta {
 			return
@@ -209,11 +220,12 @@ func buildRecDeps(items map[string]int) *recipeDependency {
 		r.amount = amt
 		r.originalAmount = amt
 	})
-	return root
+	return root, nil
 }
 
 // RecipeAllIngredients returns the list of recipes that need to be created in order
-// to craft the final item(s).
+// to craft the final item(s). Recipes that depend on each other are solved as a steady-state
+// system; if that has no solution an *ErrRecipeCycle naming them is returned
 func RecipeAllIngredients(recipes map[*data.Recipe]int, state *state.State) (data.Ingredients, error) {
 	if len(recipes) == 0 {
 		return nil, errors.New("no recipe to craft")
@@ -223,10 +235,18 @@ func RecipeAllIngredients(recipes map[*data.Recipe]int, state *state.State) (dat
 
 	ings := make(map[string]int, len(recipes))
 	for r, n := range recipes {
-		ings[r.Name] = n * r.ProductCount(r.Name)
+		item := r.Name
+		if r.ProductCount(item) == 0 {
+			// some recipes, like kovarex-enrichment-process, aren't named after what they make
+			item = r.GetResults()[0].Name
+		}
+		ings[item] += n * r.ProductCount(item)
 	}
 
-	deps := buildRecDeps(ings)
+	deps, cycles := buildRecDeps(ings)
+	if len(cycles) > 0 {
+		return steadyState(deps, cycles, state)
+	}
 
 	// total amounts can be messed up by rounding during recipe-ingredient count conversions.
 	// Keep everything as ingredient count until we actually process it
@@ -321,6 +341,22 @@ func (e *ErrMissingIngredient) Error() string {
 
 // Handcraft performs a handcrafting action
 func Handcraft(inventory Items[uint], recipe *data.Recipe, amount uint) (newInventory Items[uint], err error) {
+	return handcraft(inventory, recipe, amount, nil)
+}
+
+// HandcraftQueue is like Handcraft, but also returns what the game actually puts in the crafting
+// queue: the number of times each recipe is crafted, including intermediates that weren't already
+// in the inventory
+func HandcraftQueue(inventory Items[uint], recipe *data.Recipe, amount uint) (newInventory Items[uint], crafts map[*data.Recipe]uint, err error) {
+	crafts = map[*data.Recipe]uint{}
+	newInventory, err = handcraft(inventory, recipe, amount, crafts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return newInventory, crafts, nil
+}
+
+func handcraft(inventory Items[uint], recipe *data.Recipe, amount uint, crafts map[*data.Recipe]uint) (newInventory Items[uint], err error) {
 
 	if recipe == nil || !recipe.CanHandcraft() {
 		return nil, ErrCantHandcraft
@@ -329,16 +365,17 @@ func Handcraft(inventory Items[uint], recipe *data.Recipe, amount uint) (newInve
 	newInventory = make(Items[uint])
 	newInventory.Merge(inventory)
 
-	ingredients, products := RecipeCost(recipe, int(math.Ceil(float64(amount)/float64(recipe.ProductCount(recipe.Name)))), nil)
+	nCrafts := int(math.Ceil(float64(amount) / float64(recipe.ProductCount(recipe.Name))))
+	ingredients, products := RecipeCost(recipe, nCrafts, nil)
 
 	for ing, n := range ingredients {
 		diff := n - int(newInventory[ing])
 		if diff > 0 {
-			if r := data.GetRecipe(ing); !r.CanHandcraft() {
+			if r := data.GetRecipe(ing); r == nil || !r.CanHandcraft() {
 				return nil, &ErrMissingIngredient{ing, diff}
 			}
 			// not enough in inventory. Try to craft it
-			newInventory, err = Handcraft(newInventory, data.GetRecipe(ing), uint(diff))
+			newInventory, err = handcraft(newInventory, data.GetRecipe(ing), uint(diff), crafts)
 			if err != nil {
 				return nil, err
 			}
@@ -352,6 +389,10 @@ func Handcraft(inventory Items[uint], recipe *data.Recipe, amount uint) (newInve
 		newInventory[p] += uint(n)
 	}
 
+	if crafts != nil {
+		crafts[recipe] += uint(nCrafts)
+	}
+
 	return newInventory, nil
 }
 
@@ -0,0 +1,224 @@
+package calc
+
+import (
+	"errors"
+	"math"
+)
+
+// a small dense two-phase simplex solver. The problems this package deals with have at most a few hundred
+// recipes and items, so there's no need for anything cleverer
+
+var (
+	errInfeasible = errors.New("no solution satisfies the constraints")
+	errUnbounded  = errors.New("the objective is unbounded")
+)
+
+const simplexEpsilon = 1e-9
+
+type constraintKind int
+
+const (
+	constraintLE constraintKind = iota
+	constraintEQ
+	constraintGE
+)
+
+type constraint struct {
+	coef []float64
+	kind constraintKind
+	rhs  float64
+}
+
+// linearProgram is "minimize cost·x subject to the constraints and x >= 0"
+type linearProgram struct {
+	cost        []float64
+	constraints []constraint
+}
+
+// addConstraint adds a row. `coef` is indexed by variable and may be shorter than the number of variables
+func (lp *linearProgram) addConstraint(coef []float64, kind constraintKind, rhs float64) {
+	lp.constraints = append(lp.constraints, constraint{coef, kind, rhs})
+}
+
+type tableau struct {
+	rows  [][]float64 // the last column is the right hand side
+	basis []int
+	nCols int
+}
+
+func (t *tableau) pivot(row, col int) {
+	r := t.rows[row]
+	p := r[col]
+	for j := range r {
+		r[j] /= p
+	}
+	for i, other := range t.rows {
+		if i == row {
+			continue
+		}
+		f := other[col]
+		if math.Abs(f) < simplexEpsilon {
+			continue
+		}
+		for j := range other {
+			other[j] -= f * r[j]
+		}
+	}
+	t.basis[row] = col
+}
+
+// minimize runs the simplex method on the given costs. Only columns where `allowed` returns true may enter the basis.
+// Bland's rule is used to pick pivots, which is slow but can't cycle
+func (t *tableau) minimize(cost []float64, allowed func(col int) bool) error {
+	for {
+		enter := -1
+		for j := 0; j < t.nCols && enter < 0; j++ {
+			if !allowed(j) {
+				continue
+			}
+			reduced := cost[j]
+			for i, b := range t.basis {
+				reduced -= cost[b] * t.rows[i][j]
+			}
+			if reduced < -simplexEpsilon {
+				enter = j
+			}
+		}
+		if enter < 0 {
+			return nil
+		}
+
+		leave := -1
+		var best float64
+		for i, r := range t.rows {
+			if r[enter] <= simplexEpsilon {
+				continue
+			}
+			ratio := r[t.nCols] / r[enter]
+			if leave < 0 || ratio < best-simplexEpsilon || (ratio < best+simplexEpsilon && t.basis[i] < t.basis[leave]) {
+				leave, best = i, ratio
+			}
+		}
+		if leave < 0 {
+			return errUnbounded
+		}
+		t.pivot(leave, enter)
+	}
+}
+
+// solve returns the values of the variables at the optimum
+func (lp *linearProgram) solve() ([]float64, error) {
+
+	var (
+		nVars  = len(lp.cost)
+		nSlack int
+		nArt   int
+		m      = len(lp.constraints)
+	)
+
+	// make every right hand side non-negative, flipping the constraint if needed
+	cons := make([]constraint, m)
+	for i, c := range lp.constraints {
+		coef := make([]float64, nVars)
+		copy(coef, c.coef)
+		if c.rhs < 0 {
+			for j := range coef {
+				coef[j] = -coef[j]
+			}
+			c.rhs = -c.rhs
+			switch c.kind {
+			case constraintLE:
+				c.kind = constraintGE
+			case constraintGE:
+				c.kind = constraintLE
+			}
+		}
+		c.coef = coef
+		cons[i] = c
+
+		if c.kind != constraintEQ {
+			nSlack++
+		}
+		if c.kind != constraintLE {
+			nArt++
+		}
+	}
+
+	t := &tableau{
+		rows:  make([][]float64, m),
+		basis: make([]int, m),
+		nCols: nVars + nSlack + nArt,
+	}
+
+	slack, art := nVars, nVars+nSlack
+	for i, c := range cons {
+		row := make([]float64, t.nCols+1)
+		copy(row, c.coef)
+		row[t.nCols] = c.rhs
+
+		switch c.kind {
+		case constraintLE:
+			row[slack] = 1
+			t.basis[i] = slack
+			slack++
+		case constraintGE:
+			row[slack] = -1
+			slack++
+			row[art] = 1
+			t.basis[i] = art
+			art++
+		case constraintEQ:
+			row[art] = 1
+			t.basis[i] = art
+			art++
+		}
+		t.rows[i] = row
+	}
+
+	isArt := func(col int) bool { return col >= nVars+nSlack }
+
+	// phase 1: find a feasible point by driving the artificial variables to 0
+	if nArt > 0 {
+		cost := make([]float64, t.nCols)
+		for j := nVars + nSlack; j < t.nCols; j++ {
+			cost[j] = 1
+		}
+		if err := t.minimize(cost, func(int) bool { return true }); err != nil {
+			return nil, err
+		}
+		for i, b := range t.basis {
+			if isArt(b) && t.rows[i][t.nCols] > 1e-7 {
+				return nil, errInfeasible
+			}
+		}
+
+		// any artificial variables left are 0. Swap them out where possible; if they can't be
+		// the row is redundant and they'll stay 0
+		for i, b := range t.basis {
+			if !isArt(b) {
+				continue
+			}
+			for j := 0; j < nVars+nSlack; j++ {
+				if math.Abs(t.rows[i][j]) > simplexEpsilon {
+					t.pivot(i, j)
+					break
+				}
+			}
+		}
+	}
+
+	// phase 2: the real objective
+	cost := make([]float64, t.nCols)
+	copy(cost, lp.cost)
+	if err := t.minimize(cost, func(col int) bool { return !isArt(col) }); err != nil {
+		return nil, err
+	}
+
+	x := make([]float64, nVars)
+	for i, b := range t.basis {
+		if b < nVars {
+			x[b] = t.rows[i][t.nCols]
+		}
+	}
+	return x, nil
+}
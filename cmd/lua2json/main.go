@@ -0,0 +1,52 @@
+// lua2json reads a tasks.lua that's been edited by hand, verifies it, and writes the tasks in the JSON format
+// (see tas.ExportJSON) so the edits can be diffed against tasks.json and copied back into the Go code.
+//
+// Usage:
+//
+//	lua2json tasks.lua [tasks.json]
+//
+// The output goes to stdout if no file is given. Run it from the repository root so the data dump can be found
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/brettschalin/factorio-min-resources/data"
+	"github.com/brettschalin/factorio-min-resources/tas"
+)
+
+func main() {
+	if len(os.Args) < 2 || len(os.Args) > 3 {
+		fmt.Fprintln(os.Stderr, "usage: lua2json tasks.lua [tasks.json]")
+		os.Exit(2)
+	}
+
+	must(data.Init(
+		"./data/data-raw-dump.json",
+	))
+
+	in, err := os.Open(os.Args[1])
+	must(err)
+	defer in.Close()
+
+	t, err := tas.ImportLua(in)
+	must(err)
+
+	var out io.Writer = os.Stdout
+	if len(os.Args) == 3 {
+		f, err := os.Create(os.Args[2])
+		must(err)
+		defer f.Close()
+		out = f
+	}
+	must(t.ExportJSON(out))
+}
+
+func must(e error) {
+	if e != nil {
+		fmt.Fprintln(os.Stderr, e)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,69 @@
+// resdiff compares the resources the Go code expects a run to mine against what the mod reports.
+//
+// Usage:
+//
+//	resdiff expected_resources.json <factorio log or script-output/MinPctTAS/resources.json>
+//
+// expected_resources.json is written next to tasks.lua when the TAS is generated. The exit status is 1 if
+// any resource doesn't match
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/brettschalin/factorio-min-resources/runlog"
+)
+
+func main() {
+	if len(os.Args) != 3 {
+		fmt.Fprintln(os.Stderr, "usage: resdiff expected_resources.json <log or resources.json>")
+		os.Exit(2)
+	}
+
+	expected, err := readExpected(os.Args[1])
+	must(err)
+
+	f, err := os.Open(os.Args[2])
+	must(err)
+	defer f.Close()
+
+	res, err := runlog.Parse(f)
+	must(err)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "resource\texpected\tactual\tdiff\t")
+
+	mismatch := false
+	for _, d := range runlog.Compare(expected, res.Mined()) {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%+d\t\n", d.Resource, d.Expected, d.Actual, d.Delta())
+		mismatch = mismatch || d.Delta() != 0
+	}
+	w.Flush()
+
+	if res.Ticks > 0 {
+		fmt.Printf("\nrun took %d ticks\n", res.Ticks)
+	}
+	if mismatch {
+		os.Exit(1)
+	}
+}
+
+func readExpected(path string) (map[string]uint, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var out map[string]uint
+	err = json.Unmarshal(b, &out)
+	return out, err
+}
+
+func must(e error) {
+	if e != nil {
+		fmt.Fprintln(os.Stderr, e)
+		os.Exit(2)
+	}
+}
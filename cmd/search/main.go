@@ -0,0 +1,87 @@
+// search tries every order of a set of techs, with and without some optional buildings, and prints the
+// plans that mine the least. Plans are made with the planner package, so they won't match main.go's
+// hand-made run exactly, but they're good for comparing one choice against another.
+//
+// Usage:
+//
+//	search [-techs a,b,c] [-base stone-furnace,offshore-pump,boiler,steam-engine,lab] [-optional steel-furnace] [-orders 100] [-n 5] [-workers 8]
+//
+// Run it from the repository root so the data dump can be found
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/brettschalin/factorio-min-resources/data"
+	"github.com/brettschalin/factorio-min-resources/search"
+)
+
+func main() {
+
+	var (
+		techs    = flag.String("techs", "steel-processing,logistic-science-pack,automation,advanced-material-processing", "techs to research, comma separated")
+		base     = flag.String("base", "stone-furnace,offshore-pump,boiler,steam-engine,lab", "buildings every plan builds, comma separated")
+		optional = flag.String("optional", "steel-furnace", "buildings to try with and without, comma separated")
+		orders   = flag.Int("orders", 100, "most tech orders to try. 0 tries them all")
+		best     = flag.Int("n", 5, "how many plans to print")
+		workers  = flag.Int("workers", runtime.NumCPU(), "how many plans to evaluate at once")
+	)
+	flag.Parse()
+
+	must(data.Init(
+		"./data/data-raw-dump.json",
+	))
+
+	var candidates []search.Candidate
+	for _, buildings := range search.Subsets(list(*base), list(*optional)) {
+		for _, order := range search.TechOrders(list(*techs), *orders) {
+			candidates = append(candidates, search.Planned(buildings, order))
+		}
+	}
+	fmt.Fprintf(os.Stderr, "evaluating %d plans\n", len(candidates))
+
+	results, err := search.Search(candidates, *workers, *best)
+	must(err)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "total\tticks\tmined\tplan")
+	for _, r := range results {
+		fmt.Fprintf(w, "%d\t%d\t%s\t%s\n", r.Total, r.Ticks, mined(r.Mined), r.Candidate.Name)
+	}
+	w.Flush()
+}
+
+// list splits a comma separated flag
+func list(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+func mined(m map[string]uint) string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s=%d", name, m[name])
+	}
+	return strings.Join(parts, " ")
+}
+
+func must(e error) {
+	if e != nil {
+		fmt.Fprintln(os.Stderr, e)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,53 @@
+// tas2lua turns a task list in the JSON format (see tas.ExportJSON) into tasks.lua, so runs can be
+// edited without recompiling anything.
+//
+// Usage:
+//
+//	tas2lua tasks.json [tasks.lua]
+//
+// The tasks are verified the same as in main.go. The output goes to stdout if no file is given.
+// Run it from the repository root so the data dump can be found
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/brettschalin/factorio-min-resources/data"
+	"github.com/brettschalin/factorio-min-resources/tas"
+)
+
+func main() {
+	if len(os.Args) < 2 || len(os.Args) > 3 {
+		fmt.Fprintln(os.Stderr, "usage: tas2lua tasks.json [tasks.lua]")
+		os.Exit(2)
+	}
+
+	must(data.Init(
+		"./data/data-raw-dump.json",
+	))
+
+	in, err := os.Open(os.Args[1])
+	must(err)
+	defer in.Close()
+
+	t, err := tas.ImportJSON(in)
+	must(err)
+
+	var out io.Writer = os.Stdout
+	if len(os.Args) == 3 {
+		f, err := os.Create(os.Args[2])
+		must(err)
+		defer f.Close()
+		out = f
+	}
+	must(t.Export(out))
+}
+
+func must(e error) {
+	if e != nil {
+		fmt.Fprintln(os.Stderr, e)
+		os.Exit(1)
+	}
+}
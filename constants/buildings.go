@@ -31,3 +31,23 @@ var Labs = []string{
 var Boilers = []string{
 	"boiler",
 }
+
+var OffshorePumps = []string{
+	"offshore-pump",
+}
+
+var Pumpjacks = []string{
+	"pumpjack",
+}
+
+var SteamEngines = []string{
+	"steam-engine",
+}
+
+var SolarPanels = []string{
+	"solar-panel",
+}
+
+var RocketSilos = []string{
+	"rocket-silo",
+}
@@ -32,3 +32,13 @@ const (
 	// what fuel the boiler/furnace should use. This is assumed to be minable
 	PreferredFuel = "coal"
 )
+
+// Bonuses the mod gives the character on the first tick. See control.lua
+const (
+	BuildDistanceBonus         = 10
+	ReachDistanceBonus         = 10
+	ResourceReachDistanceBonus = 10
+)
+
+// TicksPerSecond is how many times per second the game updates at normal speed
+const TicksPerSecond = 60
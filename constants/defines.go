@@ -1,5 +1,10 @@
 package constants
 
+import (
+	"fmt"
+	"strings"
+)
+
 type Inventory int
 
 const (
@@ -29,6 +34,7 @@ const (
 	InventoryAssemblingMachineModules
 	InventoryLabInput
 	InventoryLabModules
+	InventoryMiningDrillModules
 	InventoryItemMain
 	InventoryRocketSiloRocket
 	InventoryRocketSiloResult
@@ -104,6 +110,34 @@ func (i Inventory) String() string {
 	return ""
 }
 
+// ParseInventory finds an inventory by name, with or without the "defines.inventory." prefix
+func ParseInventory(name string) (Inventory, bool) {
+	name = strings.TrimPrefix(name, "defines.inventory.")
+	for i, n := range inventoryNames {
+		if n == name {
+			return Inventory(i), true
+		}
+	}
+	return 0, false
+}
+
+// MarshalText writes the inventory's name without the prefix, eg "fuel"
+func (i Inventory) MarshalText() ([]byte, error) {
+	if i < 0 || int(i) >= len(inventoryNames) {
+		return nil, fmt.Errorf("unknown inventory %d", int(i))
+	}
+	return []byte(inventoryNames[i]), nil
+}
+
+func (i *Inventory) UnmarshalText(b []byte) error {
+	inv, ok := ParseInventory(string(b))
+	if !ok {
+		return fmt.Errorf("unknown inventory %q", string(b))
+	}
+	*i = inv
+	return nil
+}
+
 type Direction int
 
 const (
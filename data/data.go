@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -19,19 +20,36 @@ const (
 	recipeTypeSmelt = "smelting"
 )
 
+// d is what the package level functions use. It's empty until Init is called
 var (
-	d Data
+	d = &Data{}
 )
 
+// Init loads the data the package level functions (GetRecipe and so on) use. It shouldn't be called while
+// anything else might be using them
 func Init(dataFile string) error {
-	df, err := os.Open(dataFile)
+	loaded, err := Load(dataFile)
 	if err != nil {
 		return err
 	}
+	d = loaded
+	return nil
+}
+
+// Load reads a data dump. Nothing changes it after it's loaded, so it's safe to use from several goroutines
+func Load(dataFile string) (*Data, error) {
+	df, err := os.Open(dataFile)
+	if err != nil {
+		return nil, err
+	}
 	defer df.Close()
 
-	dec := json.NewDecoder(df)
-	return dec.Decode(&d)
+	out := &Data{}
+	if err := json.NewDecoder(df).Decode(out); err != nil {
+		return nil, err
+	}
+	out.index()
+	return out, nil
 }
 
 type Data struct {
@@ -47,94 +65,138 @@ type Data struct {
 	Lab        map[string]Lab        `json:"lab"`
 	Module     map[string]Module     `json:"module"`
 	Recipe     map[string]Recipe     `json:"recipe"`
+	Resource   map[string]Resource   `json:"resource"`
 	RocketSilo map[string]RocketSilo `json:"rocket-silo"`
+	SolarPanel map[string]SolarPanel `json:"solar-panel"`
 	Technology map[string]Technology `json:"technology"`
 
-	recipeCache map[string]*Recipe
-	techCache   map[string]*Technology
+	OffshorePump map[string]OffshorePump `json:"offshore-pump"`
+	MiningDrill  map[string]MiningDrill  `json:"mining-drill"`
+	Pipe         map[string]Pipe         `json:"pipe"`
+	ElectricPole map[string]ElectricPole `json:"electric-pole"`
+
+	// lookups built by index when the data is loaded, so the getters don't have to search or cache anything
+	recipeNames []string
+	recipes     map[string]*Recipe
+	recipeFor   map[string]*Recipe
+	smeltingFor map[string]*Recipe
+	techs       map[string]*Technology
+	unlockedBy  map[string]string
 }
 
-func (d *Data) GetRecipe(item string) *Recipe {
+// index builds the lookups. Recipes are looked at in order of their names so the same recipe is always
+// picked for an item that has more than one
+func (d *Data) index() {
 
-	if d.recipeCache == nil {
-		d.recipeCache = make(map[string]*Recipe)
-	}
-
-	if r, ok := d.recipeCache[item]; ok {
-		return r
+	d.recipeNames = make([]string, 0, len(d.Recipe))
+	for name := range d.Recipe {
+		d.recipeNames = append(d.recipeNames, name)
 	}
+	sort.Strings(d.recipeNames)
+
+	d.recipes = make(map[string]*Recipe, len(d.Recipe))
+	d.recipeFor = map[string]*Recipe{}
+	d.smeltingFor = map[string]*Recipe{}
+
+	for _, name := range d.recipeNames {
+		r := d.Recipe[name]
+		for _, v := range []*Recipe{r.Normal, r.Expensive} {
+			if v != nil {
+				v.Category = r.Category
+				v.Name = r.Name
+			}
+		}
+		rec := r.Get()
+		d.recipes[name] = rec
 
-	for s, r := range d.Recipe {
 		// barreling recipes don't really produce anything and can lead to infinite loops very easily
 		if strings.HasSuffix(r.Subgroup, "-barrel") {
 			continue
 		}
-		if s == item {
-			rec := r.Get()
-			d.recipeCache[item] = rec
-			return rec
-		}
-
-		rec := r.Get()
-
-		if rec.Result == item {
-			d.recipeCache[item] = rec
-			return rec
+		for _, p := range rec.GetResults() {
+			if _, ok := d.recipeFor[p.Name]; !ok {
+				d.recipeFor[p.Name] = rec
+			}
 		}
-
-		for _, p := range rec.Results {
-			if p.Name == item {
-				d.recipeCache[item] = rec
-				return rec
+		if rec.Category == recipeTypeSmelt && len(rec.Ingredients) == 1 {
+			if ore := rec.Ingredients[0].Name; d.smeltingFor[ore] == nil {
+				d.smeltingFor[ore] = rec
 			}
 		}
 	}
-	return nil
-}
 
-func GetSmeltingRecipe(ore string) *Recipe {
-	cacheKey := "SMELT_" + ore
-	if d.recipeCache == nil {
-		d.recipeCache = make(map[string]*Recipe)
+	// a recipe with the item's name beats any other that makes it
+	for _, name := range d.recipeNames {
+		if r := d.Recipe[name]; !strings.HasSuffix(r.Subgroup, "-barrel") {
+			d.recipeFor[name] = d.recipes[name]
+		}
 	}
 
-	if r, ok := d.recipeCache[cacheKey]; ok {
-		return r
+	techNames := make([]string, 0, len(d.Technology))
+	for name := range d.Technology {
+		techNames = append(techNames, name)
+	}
+	sort.Strings(techNames)
+
+	d.techs = make(map[string]*Technology, len(d.Technology))
+	d.unlockedBy = map[string]string{}
+	for _, name := range techNames {
+		t := d.Technology[name]
+		d.techs[name] = &t
+		for _, e := range t.Effects {
+			if _, ok := d.unlockedBy[e.Recipe]; e.Type == "unlock-recipe" && !ok {
+				d.unlockedBy[e.Recipe] = name
+			}
+		}
 	}
+}
 
-	for _, r := range d.Recipe {
-		rec := r.Get()
+// GetRecipe returns the recipe named `item`, or if there isn't one a recipe that makes it
+func (d *Data) GetRecipe(item string) *Recipe {
+	return d.recipeFor[item]
+}
 
-		if rec.Category != recipeTypeSmelt {
-			continue
-		}
+// GetSmeltingRecipe returns the recipe that smelts `ore` on its own
+func (d *Data) GetSmeltingRecipe(ore string) *Recipe {
+	return d.smeltingFor[ore]
+}
 
-		if len(rec.Ingredients) > 1 {
+// GetRecipes returns every recipe that has `item` as a result, sorted by name.
+// Unlike GetRecipe this doesn't pick one, so it's up to the caller to decide which to use
+func (d *Data) GetRecipes(item string) []*Recipe {
+	out := []*Recipe{}
+	for _, name := range d.recipeNames {
+		// same as GetRecipe, barreling is skipped
+		if strings.HasSuffix(d.Recipe[name].Subgroup, "-barrel") {
 			continue
 		}
-
-		if rec.Ingredients.Amount(ore) > 0 {
-			d.recipeCache[cacheKey] = rec
-			return rec
+		if rec := d.recipes[name]; rec.ProductCount(item) > 0 {
+			out = append(out, rec)
 		}
 	}
-	return nil
+	return out
 }
 
 func (d *Data) GetTech(tech string) *Technology {
-	if d.techCache == nil {
-		d.techCache = make(map[string]*Technology)
-	}
-	if cached, ok := d.techCache[tech]; ok {
-		return cached
-	}
-	for n, t := range d.Technology {
-		if n == tech {
-			d.techCache[n] = &t
-			return &t
-		}
-	}
-	return nil
+	return d.techs[tech]
+}
+
+// UnlockedBy returns the technology that unlocks the recipe, or "" if none do (recipes that are
+// enabled from the start don't need one)
+func (d *Data) UnlockedBy(recipe string) string {
+	return d.unlockedBy[recipe]
+}
+
+func GetSmeltingRecipe(ore string) *Recipe {
+	return d.GetSmeltingRecipe(ore)
+}
+
+func UnlockedBy(recipe string) string {
+	return d.UnlockedBy(recipe)
+}
+
+func GetRecipes(item string) []*Recipe {
+	return d.GetRecipes(item)
 }
 
 type AssemblingMachine struct {
@@ -143,6 +205,7 @@ type AssemblingMachine struct {
 	CraftingSpeed       float64             `json:"crafting_speed"`
 	EnergySource        EnergySource        `json:"energy_source"`
 	EnergyUsage         EnergyString        `json:"energy_usage"`
+	FluidBoxes          []FluidBox          `json:"fluid_boxes"`
 	Minable             Minable             `json:"minable"`
 	ModuleSpecification ModuleSpecification `json:"module_specification"`
 	Name                string              `json:"name"`
@@ -154,9 +217,103 @@ type Boiler struct {
 	CollisionBox      geo.Rectangle `json:"collision_box"`
 	EnergyConsumption EnergyString  `json:"energy_consumption"`
 	EnergySource      EnergySource  `json:"energy_source"`
+	FluidBox          FluidBox      `json:"fluid_box"`
 	Minable           Minable       `json:"minable"`
 	Name              string        `json:"name"`
+	OutputFluidBox    FluidBox      `json:"output_fluid_box"`
 	SelectionBox      geo.Rectangle `json:"selection_box"`
+	TargetTemperature float64       `json:"target_temperature"`
+}
+
+// FluidBox is a building's storage for one fluid
+type FluidBox struct {
+	BaseArea float64 `json:"base_area"`
+
+	// if set, the only fluid allowed in the box
+	Filter string `json:"filter"`
+
+	// "input", "output", or "input-output"
+	ProductionType string `json:"production_type"`
+}
+
+// Capacity returns how many units of fluid the box holds
+func (f FluidBox) Capacity() float64 {
+	return f.BaseArea * 100
+}
+
+// OffshorePump pumps water out of the ground (or lake) for free
+type OffshorePump struct {
+	CollisionBox geo.Rectangle `json:"collision_box"`
+	Fluid        string        `json:"fluid"`
+	Minable      Minable       `json:"minable"`
+	Name         string        `json:"name"`
+	PumpingSpeed float64       `json:"pumping_speed"` // units per tick
+}
+
+// MiningDrill covers both pumpjacks and the drills that mine ore
+type MiningDrill struct {
+	CollisionBox        geo.Rectangle       `json:"collision_box"`
+	EnergySource        EnergySource        `json:"energy_source"`
+	EnergyUsage         EnergyString        `json:"energy_usage"`
+	Minable             Minable             `json:"minable"`
+	MiningSpeed         float64             `json:"mining_speed"`
+	ModuleSpecification ModuleSpecification `json:"module_specification"`
+	Name                string              `json:"name"`
+	ResourceCategories  []string            `json:"resource_categories"`
+}
+
+type Pipe struct {
+	CollisionBox geo.Rectangle `json:"collision_box"`
+	FluidBox     FluidBox      `json:"fluid_box"`
+	Minable      Minable       `json:"minable"`
+	Name         string        `json:"name"`
+}
+
+type ElectricPole struct {
+	CollisionBox       geo.Rectangle `json:"collision_box"`
+	MaxWireDistance    float64       `json:"maximum_wire_distance"`
+	Minable            Minable       `json:"minable"`
+	Name               string        `json:"name"`
+	SupplyAreaDistance float64       `json:"supply_area_distance"`
+}
+
+// CollisionBox returns the collision box of anything that can be placed, centered on the origin and
+// facing north. Returns false if there's no entity with that name
+func CollisionBox(name string) (geo.Rectangle, bool) {
+	if x, ok := d.AssemblingMachine[name]; ok {
+		return x.CollisionBox, true
+	}
+	if x, ok := d.Boiler[name]; ok {
+		return x.CollisionBox, true
+	}
+	if x, ok := d.Furnace[name]; ok {
+		return x.CollisionBox, true
+	}
+	if x, ok := d.Generator[name]; ok {
+		return x.CollisionBox, true
+	}
+	if x, ok := d.Lab[name]; ok {
+		return x.CollisionBox, true
+	}
+	if x, ok := d.RocketSilo[name]; ok {
+		return x.CollisionBox, true
+	}
+	if x, ok := d.SolarPanel[name]; ok {
+		return x.CollisionBox, true
+	}
+	if x, ok := d.OffshorePump[name]; ok {
+		return x.CollisionBox, true
+	}
+	if x, ok := d.MiningDrill[name]; ok {
+		return x.CollisionBox, true
+	}
+	if x, ok := d.Pipe[name]; ok {
+		return x.CollisionBox, true
+	}
+	if x, ok := d.ElectricPole[name]; ok {
+		return x.CollisionBox, true
+	}
+	return geo.Rectangle{}, false
 }
 
 type EnergySource struct {
@@ -177,7 +334,7 @@ func (e *EnergyString) UnmarshalJSON(b []byte) error {
 	b = bytes.Trim(b, `"`)
 
 	numIdx := bytes.IndexFunc(b, func(r rune) bool {
-		return r > '9' || r < '0'
+		return (r > '9' || r < '0') && r != '.'
 	})
 
 	suffix := bytes.ToLower(b[numIdx:])
@@ -212,6 +369,11 @@ type Character struct {
 	RunningSpeed          float64  `json:"running_speed"`
 }
 
+// GetCharacter returns the stats of the player's character
+func GetCharacter() *Character {
+	return &d.Character.Character
+}
+
 type Furnace struct {
 	AllowedEffects      []string            `json:"allowed_effects"`
 	CollisionBox        geo.Rectangle       `json:"collision_box"`
@@ -236,6 +398,14 @@ type Generator struct {
 	SelectionBox geo.Rectangle `json:"selection_box"`
 }
 
+type SolarPanel struct {
+	CollisionBox geo.Rectangle `json:"collision_box"`
+	Minable      Minable       `json:"minable"`
+	Name         string        `json:"name"`
+	Production   EnergyString  `json:"production"`
+	SelectionBox geo.Rectangle `json:"selection_box"`
+}
+
 type Item struct {
 	Name      string       `json:"name"`
 	StackSize int          `json:"stack_size"`
@@ -267,10 +437,6 @@ type Minable struct {
 	Result     string  `json:"result"`
 }
 
-var (
-	modLimitCache = map[string]map[string]bool{}
-)
-
 type Module struct {
 	Category   string       `json:"category"`
 	Effect     ModuleEffect `json:"effect"`
@@ -284,19 +450,7 @@ func (m *Module) ProductivityBonus() float64 {
 }
 
 func (m *Module) AppliesTo(recipe string) bool {
-	b, ok := modLimitCache[m.Name]
-	if !ok {
-		b = map[string]bool{}
-		modLimitCache[m.Name] = b
-	}
-
-	if a, ok := b[recipe]; ok {
-		return a
-	}
-
-	a := slices.Contains(m.Limitation, recipe)
-	b[recipe] = a
-	return a
+	return slices.Contains(m.Limitation, recipe)
 }
 
 type ModuleEffect struct {
@@ -327,6 +481,10 @@ type Recipe struct {
 	Results     Ingredients `json:"results"`
 	ResultCount int         `json:"result_count"`
 
+	// whether the recipe can be used from the start. Recipes that can't have to be unlocked by a tech.
+	// Nil means true, which is the game's default
+	Enabled *bool `json:"enabled"`
+
 	// Some recipes have expensive variants. If these are
 	// not nil, the other fields won't be populated
 	Expensive *Recipe `json:"expensive"`
@@ -349,15 +507,18 @@ func (r *Recipe) ProductCount(item string) int {
 	return 0
 }
 
+// IsEnabled returns whether the recipe is available without researching anything
+func (r *Recipe) IsEnabled() bool {
+	return r.Enabled == nil || *r.Enabled
+}
+
+// Get returns the normal or expensive version of the recipe, depending on constants.UseExpensive. Their
+// names and categories are filled in when the data is loaded
 func (r *Recipe) Get() *Recipe {
 	if e := r.Expensive; constants.UseExpensive && e != nil {
-		e.Category = r.Category
-		e.Name = r.Name
 		return e
 	}
 	if n := r.Normal; n != nil {
-		n.Category = r.Category
-		n.Name = r.Name
 		return n
 	}
 	return r
@@ -498,6 +659,13 @@ type ModuleSpecification struct {
 	ModuleSlots int `json:"module_slots"`
 }
 
+// Resource is anything found in a resource patch, like ores or crude oil
+type Resource struct {
+	Category string  `json:"category"`
+	Minable  Minable `json:"minable"`
+	Name     string  `json:"name"`
+}
+
 type RocketSilo struct {
 	AssemblingMachine         `json:",inline"`
 	FixedRecipe               string `json:"fixed_recipe"`
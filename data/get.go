@@ -73,6 +73,14 @@ func GetModule(name string) *Module {
 	return &x
 }
 
+func GetResource(name string) *Resource {
+	x := d.Resource[name]
+	if x.Name == "" {
+		return nil
+	}
+	return &x
+}
+
 func GetRocketSilo(name string) *RocketSilo {
 	x := d.RocketSilo[name]
 	if x.Name == "" {
@@ -81,3 +89,43 @@ func GetRocketSilo(name string) *RocketSilo {
 	return &x
 }
 
+func GetSolarPanel(name string) *SolarPanel {
+	x := d.SolarPanel[name]
+	if x.Name == "" {
+		return nil
+	}
+	return &x
+}
+
+func GetOffshorePump(name string) *OffshorePump {
+	x := d.OffshorePump[name]
+	if x.Name == "" {
+		return nil
+	}
+	return &x
+}
+
+func GetMiningDrill(name string) *MiningDrill {
+	x := d.MiningDrill[name]
+	if x.Name == "" {
+		return nil
+	}
+	return &x
+}
+
+func GetPipe(name string) *Pipe {
+	x := d.Pipe[name]
+	if x.Name == "" {
+		return nil
+	}
+	return &x
+}
+
+func GetElectricPole(name string) *ElectricPole {
+	x := d.ElectricPole[name]
+	if x.Name == "" {
+		return nil
+	}
+	return &x
+}
+
@@ -50,10 +50,12 @@ func (p Point) Magnitude() float64 {
 	return math.Hypot(p.X, p.Y)
 }
 
-// ClosestWithin returns the closest point that is
+// ClosestWithin returns the closest point to o that is
 // - within d units of p
 // - in the direction of o
 // - outside the box (if any) defined by placing colBox[0] at p
+//
+// If the box reaches further than d, the point on its edge is returned instead
 func (p Point) ClosestWithin(o Point, d float64, colBox ...Rectangle) Point {
 
 	// sanity check
@@ -61,26 +63,31 @@ func (p Point) ClosestWithin(o Point, d float64, colBox ...Rectangle) Point {
 		return p
 	}
 
-	if len(colBox) > 0 {
-		box := colBox[1].Add(p)
-		other := box.ClosestTo(o)
-		if o.In(box) {
-			// go to the edge and a bit further beyond
-			return other.Add(other.Sub(o).normalize(d))
-		}
-		o = other
+	l := o.Sub(p).Magnitude()
+	if l == 0 {
+		return p
 	}
 
-	// vector from p to o, normalized to the right distance
-	v := o.Sub(p).normalize(d)
+	// unit vector from p to o
+	v := o.Sub(p).normalize(1)
+
+	var edge float64
+	if len(colBox) > 0 {
+		edge = colBox[0].exit(v)
+	}
 
-	// and add it to p
-	return p.Add(v)
+	switch {
+	case l > d:
+		return p.Add(v.Mul(math.Max(d, edge)))
+	case l <= edge:
+		return p.Add(v.Mul(edge))
+	}
+	return o
 }
 
 // treats p as a vector from the origin and ensures it has magnitude l
 func (p Point) normalize(l float64) Point {
-	return p.Mul(math.Sqrt(l) / p.Magnitude())
+	return p.Mul(l / p.Magnitude())
 }
 
 // PathDistance returns the distance between two points by
@@ -148,7 +155,24 @@ func (r Rectangle) Dy() float64 {
 // Overlap determines if two Rectangles overlap
 func (r Rectangle) Overlap(s Rectangle) bool {
 	return r.TopLeft.X < s.BottomRight.X && s.TopLeft.X < r.BottomRight.X &&
-		r.TopLeft.Y < s.BottomRight.Y && s.BottomRight.Y < r.BottomRight.Y
+		r.TopLeft.Y < s.BottomRight.Y && s.TopLeft.Y < r.BottomRight.Y
+}
+
+// exit returns how far from the origin a ray in the direction of the unit vector v leaves the Rectangle.
+// The Rectangle must contain the origin
+func (r Rectangle) exit(v Point) float64 {
+	t := math.Inf(1)
+	if v.X > 0 {
+		t = math.Min(t, r.BottomRight.X/v.X)
+	} else if v.X < 0 {
+		t = math.Min(t, r.TopLeft.X/v.X)
+	}
+	if v.Y > 0 {
+		t = math.Min(t, r.BottomRight.Y/v.Y)
+	} else if v.Y < 0 {
+		t = math.Min(t, r.TopLeft.Y/v.Y)
+	}
+	return t
 }
 
 // ClosestTo returns a Point on the Rectangle's edge that is closest to the provided Point
@@ -180,7 +204,7 @@ func (r Rectangle) ClosestTo(p Point) Point {
 
 	if p.Y <= r.TopLeft.Y {
 		retY = r.TopLeft.Y
-	} else if p.Y <= r.BottomRight.Y {
+	} else if p.Y >= r.BottomRight.Y {
 		retY = r.BottomRight.Y
 	}
 
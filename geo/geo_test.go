@@ -0,0 +1,70 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestClosestWithin(t *testing.T) {
+
+	box := Rectangle{TopLeft: Point{-1.5, -1.5}, BottomRight: Point{1.5, 1.5}}
+
+	for _, test := range []struct {
+		name     string
+		o        Point
+		d        float64
+		box      []Rectangle
+		expected Point
+	}{
+		{
+			name:     "out of reach",
+			o:        Point{10, 0},
+			d:        4,
+			expected: Point{4, 0},
+		},
+		{
+			name:     "already within reach",
+			o:        Point{3, 4},
+			d:        10,
+			expected: Point{3, 4},
+		},
+		{
+			name:     "box bigger than the reach",
+			o:        Point{0, -10},
+			d:        1,
+			box:      []Rectangle{box},
+			expected: Point{0, -1.5},
+		},
+		{
+			name:     "inside the box",
+			o:        Point{1, 1},
+			d:        10,
+			box:      []Rectangle{box},
+			expected: Point{1.5, 1.5},
+		},
+	} {
+		t.Run(test.name, func(tt *testing.T) {
+			p := Point{}.ClosestWithin(test.o, test.d, test.box...)
+			if math.Abs(p.X-test.expected.X) > 1e-9 || math.Abs(p.Y-test.expected.Y) > 1e-9 {
+				tt.Fatalf("expected %v, got %v", test.expected, p)
+			}
+		})
+	}
+}
+
+func TestOverlap(t *testing.T) {
+	a := Rectangle{TopLeft: Point{0, 0}, BottomRight: Point{2, 2}}
+	for _, test := range []struct {
+		b        Rectangle
+		expected bool
+	}{
+		{Rectangle{TopLeft: Point{1, 1}, BottomRight: Point{3, 3}}, true},
+		{Rectangle{TopLeft: Point{1, 1}, BottomRight: Point{3, 1.5}}, true},
+		{Rectangle{TopLeft: Point{2, 0}, BottomRight: Point{3, 2}}, false},
+		{Rectangle{TopLeft: Point{0, 3}, BottomRight: Point{2, 4}}, false},
+	} {
+		if a.Overlap(test.b) != test.expected || test.b.Overlap(a) != test.expected {
+			t.Errorf("expected %v to overlap %v: %v", a, test.b, test.expected)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package layout
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/brettschalin/factorio-min-resources/constants"
+	"github.com/brettschalin/factorio-min-resources/geo"
+	"github.com/brettschalin/factorio-min-resources/shims"
+	"github.com/brettschalin/factorio-min-resources/state"
+)
+
+// Blueprint strings are a version byte ("0" for every version so far) followed by base64 of zlib
+// compressed JSON. See https://wiki.factorio.com/Blueprint_string_format
+
+const blueprintStringVersion = "0"
+
+// 1.1.88, the version the map in SETUP.md was made with. Packed the way the game does it, 16 bits per part
+const blueprintVersion uint64 = 1<<48 | 1<<32 | 88<<16
+
+// blueprints use 8 directions, so the cardinal ones are every other number
+var blueprintDirections = map[constants.Direction]int{
+	constants.DirectionNorth: 0,
+	constants.DirectionEast:  2,
+	constants.DirectionSouth: 4,
+	constants.DirectionWest:  6,
+}
+
+type blueprintString struct {
+	Blueprint *blueprint `json:"blueprint"`
+}
+
+type blueprint struct {
+	Item     string            `json:"item"`
+	Label    string            `json:"label,omitempty"`
+	Icons    []blueprintIcon   `json:"icons,omitempty"`
+	Entities []blueprintEntity `json:"entities"`
+	Version  uint64            `json:"version"`
+}
+
+type blueprintIcon struct {
+	Signal struct {
+		Type string `json:"type"`
+		Name string `json:"name"`
+	} `json:"signal"`
+	Index int `json:"index"`
+}
+
+type blueprintEntity struct {
+	EntityNumber int       `json:"entity_number"`
+	Name         string    `json:"name"`
+	Position     geo.Point `json:"position"`
+
+	// missing means north, or that the building can't be rotated
+	Direction *int   `json:"direction,omitempty"`
+	Recipe    string `json:"recipe,omitempty"`
+
+	// the game keeps these for us, so they're where the index goes
+	Tags *blueprintTags `json:"tags,omitempty"`
+}
+
+type blueprintTags struct {
+	N int `json:"n,omitempty"`
+}
+
+// Blueprint returns a blueprint string of the buildings placed in s. Some placements share a spot, so
+// only what's on the map at once can go in one blueprint. Positions are the map's, but the game places
+// blueprints around the cursor anyway
+func (l *Layout) Blueprint(s *state.State) (string, error) {
+
+	if err := l.Check(s); err != nil {
+		return "", err
+	}
+
+	bp := &blueprint{
+		Item:    "blueprint",
+		Label:   "MinPctTAS layout",
+		Version: blueprintVersion,
+	}
+	for _, id := range s.IDs() {
+		p, _ := l.Get(id.Name, id.N)
+		e := blueprintEntity{
+			EntityNumber: len(bp.Entities) + 1,
+			Name:         p.Name,
+			Position:     p.Position,
+			Recipe:       p.Recipe,
+		}
+		// north is written out so it comes back as north rather than DirectionNone
+		if d, ok := blueprintDirections[p.Direction]; ok {
+			e.Direction = &d
+		}
+		if p.N != 0 {
+			e.Tags = &blueprintTags{N: p.N}
+		}
+		bp.Entities = append(bp.Entities, e)
+	}
+
+	// the game wants at least one icon to show
+	if len(bp.Entities) > 0 {
+		icon := blueprintIcon{Index: 1}
+		icon.Signal.Type = "item"
+		icon.Signal.Name = bp.Entities[0].Name
+		bp.Icons = []blueprintIcon{icon}
+	}
+
+	js, err := json.Marshal(blueprintString{Blueprint: bp})
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	zw, err := zlib.NewWriterLevel(&buf, zlib.BestCompression)
+	if err != nil {
+		return "", err
+	}
+	zw.Write(js)
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	return blueprintStringVersion + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// ParseBlueprint reads a blueprint string into a layout with no resources. Buildings exported by
+// Blueprint keep their index; for ones made in the game, a name that appears once gets index 0 and
+// otherwise they're numbered from 1 in the blueprint's order. Blueprints made in the game are centered
+// on the origin, so use Translate to put them back on the map before checking them
+func ParseBlueprint(s string) (*Layout, error) {
+
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, blueprintStringVersion) {
+		return nil, fmt.Errorf(`[layout] unsupported blueprint string version %q`, s[:shims.Min(len(s), 1)])
+	}
+	compressed, err := base64.StdEncoding.DecodeString(s[len(blueprintStringVersion):])
+	if err != nil {
+		return nil, fmt.Errorf(`[layout] %w`, err)
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf(`[layout] %w`, err)
+	}
+	js, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf(`[layout] %w`, err)
+	}
+
+	var bs blueprintString
+	if err := json.Unmarshal(js, &bs); err != nil {
+		return nil, fmt.Errorf(`[layout] %w`, err)
+	}
+	if bs.Blueprint == nil {
+		return nil, fmt.Errorf(`[layout] not a blueprint; books and planners aren't supported`)
+	}
+
+	count := map[string]int{}
+	for _, e := range bs.Blueprint.Entities {
+		count[e.Name]++
+	}
+
+	l := &Layout{}
+	next := map[string]int{}
+	for _, e := range bs.Blueprint.Entities {
+		p := Placement{
+			Name:     e.Name,
+			Position: e.Position,
+			Recipe:   e.Recipe,
+		}
+
+		if e.Direction != nil {
+			found := false
+			for d, n := range blueprintDirections {
+				if n == *e.Direction {
+					p.Direction, found = d, true
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf(`[layout] %s at %v has unsupported direction %d`, e.Name, e.Position, *e.Direction)
+			}
+		}
+
+		switch {
+		case e.Tags != nil && e.Tags.N != 0:
+			p.N = e.Tags.N
+		case count[e.Name] > 1:
+			next[e.Name]++
+			p.N = next[e.Name]
+		}
+
+		if _, ok := l.Get(p.Name, p.N); ok {
+			return nil, fmt.Errorf(`[layout] %q is in the blueprint twice`, label(p.Name, p.N))
+		}
+		l.Buildings = append(l.Buildings, p)
+	}
+	return l, nil
+}
+
+// Translate moves every building by d. Resources stay where they are
+func (l *Layout) Translate(d geo.Point) {
+	for i := range l.Buildings {
+		l.Buildings[i].Position = l.Buildings[i].Position.Add(d)
+	}
+}
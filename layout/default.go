@@ -0,0 +1,104 @@
+package layout
+
+import (
+	"github.com/brettschalin/factorio-min-resources/constants"
+	"github.com/brettschalin/factorio-min-resources/geo"
+)
+
+// Default is the layout the run in main.go uses. Change this if you use a different map or don't like the layout
+var Default = Layout{
+	Buildings: append([]Placement{
+		{Name: "lab", Position: geo.Point{X: 306.5, Y: 172.5}},
+		{Name: "boiler", Position: geo.Point{X: 300, Y: 175.5}, Direction: constants.DirectionEast},
+
+		{Name: "stone-furnace", Position: geo.Point{X: 11, Y: 45}},
+		{Name: "steel-furnace", Position: geo.Point{X: 11, Y: 45}},
+
+		{Name: "small-electric-pole", N: 1, Position: geo.Point{X: 303.5, Y: 172.5}},
+		{Name: "small-electric-pole", N: 2, Position: geo.Point{X: 303.5, Y: 165.5}},
+
+		{Name: "offshore-pump", Position: geo.Point{X: 299.5, Y: 177.5}, Direction: constants.DirectionSouth},
+		{Name: "steam-engine", Position: geo.Point{X: 303.5, Y: 175.5}, Direction: constants.DirectionEast},
+		{Name: "solar-panel", Position: geo.Point{X: 301.5, Y: 171.5}},
+		{Name: "oil-refinery", Position: geo.Point{X: 300.5, Y: 166.5}, Direction: constants.DirectionNorth, Recipe: "basic-oil-processing"},
+		{Name: "chemical-plant", Position: geo.Point{X: 306.5, Y: 163.5}, Direction: constants.DirectionWest},
+		{Name: "assembling-machine-2", Position: geo.Point{X: 306.5, Y: 175.5}}, // overlaps with steam-engine
+
+		// we won't have enough modules to run both at the same time so we'll need to switch off
+		{Name: "assembling-machine-3", Position: geo.Point{X: 306.5, Y: 175.5}},
+		{Name: "electric-furnace", Position: geo.Point{X: 306.5, Y: 175.5}},
+
+		{Name: "pumpjack", Position: geo.Point{X: 304.5, Y: 168.5}, Direction: constants.DirectionWest},
+
+		// TODO: will require mining something to make space near the power poles
+		// {Name: "rocket-silo"},
+	}, pipes(
+		// boiler to refinery
+		geo.Point{X: 299.5, Y: 173.5},
+		geo.Point{X: 299.5, Y: 172.5},
+		geo.Point{X: 299.5, Y: 171.5},
+		geo.Point{X: 299.5, Y: 170.5},
+		geo.Point{X: 299.5, Y: 169.5},
+
+		// boiler to chem plant
+		geo.Point{X: 300.5, Y: 173.5},
+		geo.Point{X: 301.5, Y: 173.5},
+		geo.Point{X: 302.5, Y: 173.5},
+		geo.Point{X: 303.5, Y: 173.5},
+		geo.Point{X: 304.5, Y: 173.5},
+		geo.Point{X: 304.5, Y: 172.5},
+		geo.Point{X: 304.5, Y: 171.5},
+		geo.Point{X: 304.5, Y: 170.5},
+		geo.Point{X: 305.5, Y: 170.5},
+		geo.Point{X: 306.5, Y: 170.5},
+		geo.Point{X: 306.5, Y: 169.5},
+		geo.Point{X: 306.5, Y: 168.5},
+		geo.Point{X: 306.5, Y: 167.5},
+		geo.Point{X: 306.5, Y: 166.5},
+		geo.Point{X: 306.5, Y: 165.5},
+		geo.Point{X: 305.5, Y: 165.5},
+		geo.Point{X: 304.5, Y: 165.5},
+		geo.Point{X: 304.5, Y: 164.5},
+
+		// pumpjack to refinery
+		geo.Point{X: 302.5, Y: 169.5},
+		geo.Point{X: 301.5, Y: 169.5},
+
+		// chem plant to assembler
+		geo.Point{X: 308.5, Y: 163.5},
+		geo.Point{X: 308.5, Y: 164.5},
+		geo.Point{X: 308.5, Y: 165.5},
+		geo.Point{X: 308.5, Y: 166.5},
+		geo.Point{X: 308.5, Y: 167.5},
+		geo.Point{X: 308.5, Y: 168.5},
+		geo.Point{X: 308.5, Y: 169.5},
+		geo.Point{X: 308.5, Y: 170.5},
+		geo.Point{X: 308.5, Y: 171.5},
+		geo.Point{X: 308.5, Y: 172.5},
+		geo.Point{X: 308.5, Y: 173.5},
+		geo.Point{X: 308.5, Y: 174.5},
+		geo.Point{X: 308.5, Y: 175.5},
+
+		// refinery to chem plant
+		geo.Point{X: 302.5, Y: 163.5},
+		geo.Point{X: 302.5, Y: 162.5},
+		geo.Point{X: 303.5, Y: 162.5},
+		geo.Point{X: 304.5, Y: 162.5},
+	)...),
+
+	Resources: map[string]geo.Point{
+		"coal":       {X: 12.5, Y: 46.5},
+		"stone":      {X: 28.5, Y: 56.5},
+		"iron-ore":   {X: 11.5, Y: 43.5},
+		"copper-ore": {X: 8.5, Y: 43.5},
+	},
+}
+
+// pipes numbers pipe placements from 1, in the order they're built
+func pipes(points ...geo.Point) []Placement {
+	out := make([]Placement, len(points))
+	for i, p := range points {
+		out[i] = Placement{Name: "pipe", N: i + 1, Position: p}
+	}
+	return out
+}
@@ -0,0 +1,192 @@
+package layout
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/brettschalin/factorio-min-resources/constants"
+)
+
+// Export writes the layout as locations.lua
+func (l *Layout) Export(w io.Writer) error {
+
+	var b strings.Builder
+	b.WriteString(LocationsLuaHeader)
+
+	b.WriteString("-- where the machines are placed. Change the layout in the Go code instead of here\n")
+	b.WriteString("local locations = {\n")
+	for _, name := range l.names() {
+		var ps []Placement
+		for _, p := range l.Buildings {
+			if p.Name == name {
+				ps = append(ps, p)
+			}
+		}
+		if len(ps) == 1 && ps[0].N == 0 {
+			fmt.Fprintf(&b, "    [%q] = %s,\n", name, luaPosition(ps[0]))
+			continue
+		}
+		fmt.Fprintf(&b, "    [%q] = {\n", name)
+		for _, p := range ps {
+			fmt.Fprintf(&b, "        [%d] = %s,\n", p.N, luaPosition(p))
+		}
+		b.WriteString("    },\n")
+	}
+	b.WriteString("}\n")
+	b.WriteString(locationsGet)
+
+	b.WriteString("-- the first locations we mine. Map-specific\n")
+	b.WriteString("local resources = {\n")
+	resources := make([]string, 0, len(l.Resources))
+	for r := range l.Resources {
+		resources = append(resources, r)
+	}
+	sort.Strings(resources)
+	for _, r := range resources {
+		p := l.Resources[r]
+		fmt.Fprintf(&b, "    [%q] = {x = %g, y = %g},\n", r, p.X, p.Y)
+	}
+	b.WriteString("}\n\n")
+
+	b.WriteString(LocationsLuaFooter)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func luaPosition(p Placement) string {
+	if p.Direction != constants.DirectionNone {
+		return fmt.Sprintf("{x = %g, y = %g, dir = %s}", p.Position.X, p.Position.Y, p.Direction)
+	}
+	return fmt.Sprintf("{x = %g, y = %g}", p.Position.X, p.Position.Y)
+}
+
+const LocationsLuaHeader = `-- Automatically generated from the layout package. DO NOT EDIT
+math2d = require("math2d")
+
+`
+
+const locationsGet = `
+function locations.get(entity, n)
+    loc = locations[entity]
+    if loc.x then
+        return loc
+    end
+    return loc[n]
+end
+
+`
+
+const LocationsLuaFooter = `-- finds location to mine the provided resource. To avoid
+-- more lag than we need search is limited to 512 tiles away from
+-- the starting position
+function resources.find(p, name)
+
+    local radius, end_radius = 8, 512
+ 
+    start = resources[name]
+
+    res = p.surface.find_entities_filtered({
+        position = start,
+        radius = radius,
+        name = name
+    })
+    while res == nil or #res == 0 do
+        if radius > end_radius then
+            error("no "..name.." found on player surface")
+        end
+        res = p.surface.find_entities_filtered({
+            position = start,
+            radius = radius,
+            name = name
+        })
+        radius = radius * 2
+    end
+    
+    sort_func = function(i, j)
+        d1 = math2d.position.distance_squared(i.position, start)
+        d2 = math2d.position.distance_squared(j.position, start)
+        return d1 < d2
+    end
+
+    table.sort(res, sort_func)
+
+    -- Make this the new starting point of the search
+    resources[name] = res[1].position
+
+    return {
+        resource = name,
+        position = res[1].position,
+        amount = res[1].amount,
+        entity = res[1]
+    }
+end
+
+
+-- Where the buildings are
+local buildings = {}
+
+local function format_n(n)
+    if not n then
+        return ""
+    end
+    return string.format("%d", n)
+end
+
+function buildings.build(p, name, location, n)
+    local loc = math2d.position.ensure_xy(location)
+    local b = {
+        name = name,
+        location = loc,
+        n = n
+    }
+    if not n then
+        buildings[name] = b
+    else
+        buildings[name] = buildings[name] or {}
+        buildings[name][n] = b
+    end
+
+    return buildings.get(p, name, n)
+end
+
+function buildings.is_placed(p, name, n)
+    building = buildings.get(p, name, n)
+    return building ~= nil and building.is_placed
+end
+
+function buildings.mine(p, name, n)
+    if not n then
+        buildings[name] = nil
+    else
+        buildings[name][n] = nil
+    end
+end
+
+function buildings.get(p, name, n)
+
+    local building
+    if not n then
+        building = buildings[name]
+    elseif buildings[name] then
+        building = buildings[name][n]
+    end
+
+    if not building or not building.location then
+        return nil
+    end
+
+    building.entity = p.surface.find_entity(building.name, building.location)
+    building.is_placed = building.entity ~= nil
+
+    return building
+end
+
+return {
+    buildings = buildings,
+    locations = locations,
+    resources = resources,
+}
+`
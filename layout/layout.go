@@ -0,0 +1,157 @@
+// package layout describes where buildings go on the map. It's the source of locations.lua, so the
+// positions the mod uses can be checked for reach, overlaps and walking time before the run
+package layout
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/brettschalin/factorio-min-resources/constants"
+	"github.com/brettschalin/factorio-min-resources/data"
+	"github.com/brettschalin/factorio-min-resources/geo"
+	"github.com/brettschalin/factorio-min-resources/state"
+	"github.com/brettschalin/factorio-min-resources/tas"
+)
+
+// Placement is where one building goes
+type Placement struct {
+	Name string
+
+	// distinguishes buildings with the same name, the same as in tas.Build. 0 is the only one of its kind
+	N         int
+	Position  geo.Point
+	Direction constants.Direction
+
+	// set in blueprints. Only for machines that keep the same recipe the whole run
+	Recipe string
+}
+
+// CollisionBox returns the area the building covers once it's placed. Returns false if the building doesn't exist
+func (p Placement) CollisionBox() (geo.Rectangle, bool) {
+	box, ok := data.CollisionBox(p.Name)
+	if !ok {
+		return geo.Rectangle{}, false
+	}
+	return rotate(box, p.Direction).Add(p.Position), true
+}
+
+// rotate turns a box facing north to face d. Boxes are rotated clockwise around the origin
+func rotate(r geo.Rectangle, d constants.Direction) geo.Rectangle {
+	tl, br := r.TopLeft, r.BottomRight
+	switch d {
+	case constants.DirectionEast:
+		return geo.Rectangle{
+			TopLeft:     geo.Point{X: -br.Y, Y: tl.X},
+			BottomRight: geo.Point{X: -tl.Y, Y: br.X},
+		}
+	case constants.DirectionSouth:
+		return geo.Rectangle{
+			TopLeft:     geo.Point{X: -br.X, Y: -br.Y},
+			BottomRight: geo.Point{X: -tl.X, Y: -tl.Y},
+		}
+	case constants.DirectionWest:
+		return geo.Rectangle{
+			TopLeft:     geo.Point{X: tl.Y, Y: -br.X},
+			BottomRight: geo.Point{X: br.Y, Y: -tl.X},
+		}
+	}
+	return r
+}
+
+// Layout is where everything goes on the map
+type Layout struct {
+	Buildings []Placement
+
+	// where to start looking for each resource. The mod mines the closest patch it finds
+	Resources map[string]geo.Point
+}
+
+// Get returns the placement of the nth building with this name
+func (l *Layout) Get(name string, n int) (Placement, bool) {
+	for _, p := range l.Buildings {
+		if p.Name == name && p.N == n {
+			return p, true
+		}
+	}
+	return Placement{}, false
+}
+
+// Locate finds a building or resource patch. It can be passed to tas.Simulate as a tas.Locator
+func (l *Layout) Locate(name string, n int) (geo.Point, bool) {
+	if p, ok := l.Get(name, n); ok {
+		return p.Position, true
+	}
+	p, ok := l.Resources[name]
+	return p, ok
+}
+
+// the character is about this wide. Walking to the edge of a building plus this much keeps it from
+// getting stuck on the building itself
+const walkMargin = 0.5
+
+// Walk returns a task that walks to just below the nth building with this name, which is always in reach of it
+func (l *Layout) Walk(name string, n int) (tas.Task, error) {
+	p, ok := l.Get(name, n)
+	if !ok {
+		return nil, fmt.Errorf(`[layout] no placement for %q`, label(name, n))
+	}
+	box, ok := p.CollisionBox()
+	if !ok {
+		return nil, fmt.Errorf(`[layout] unknown entity %q`, name)
+	}
+	return tas.Walk(geo.Point{X: p.Position.X, Y: box.BottomRight.Y + walkMargin}), nil
+}
+
+// Check makes sure every building placed in s has a placement and none of them overlap. Some
+// placements share a spot, like furnaces that get replaced, so only what's on the map at once is checked
+func (l *Layout) Check(s *state.State) error {
+
+	type placed struct {
+		Placement
+		box geo.Rectangle
+	}
+	var all []placed
+
+	for _, id := range s.IDs() {
+		p, ok := l.Get(id.Name, id.N)
+		if !ok {
+			return fmt.Errorf(`[layout] no placement for %q`, label(id.Name, id.N))
+		}
+		box, ok := p.CollisionBox()
+		if !ok {
+			return fmt.Errorf(`[layout] unknown entity %q`, id.Name)
+		}
+		all = append(all, placed{p, box})
+	}
+
+	for i, a := range all {
+		for _, b := range all[i+1:] {
+			if a.box.Overlap(b.box) {
+				return fmt.Errorf(`[layout] %q overlaps %q`, label(a.Name, a.N), label(b.Name, b.N))
+			}
+		}
+	}
+	return nil
+}
+
+// names returns the names of every placed building, sorted
+func (l *Layout) names() []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, p := range l.Buildings {
+		if !seen[p.Name] {
+			seen[p.Name] = true
+			out = append(out, p.Name)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// label names a building in error messages. Indexed buildings get their index appended
+func label(entity string, n int) string {
+	if n != 0 {
+		return fmt.Sprintf("%s[%d]", entity, n)
+	}
+	return entity
+}
@@ -0,0 +1,187 @@
+package layout
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/brettschalin/factorio-min-resources/building"
+	"github.com/brettschalin/factorio-min-resources/constants"
+	"github.com/brettschalin/factorio-min-resources/data"
+	"github.com/brettschalin/factorio-min-resources/geo"
+	"github.com/brettschalin/factorio-min-resources/state"
+	"github.com/r3labs/diff/v3"
+)
+
+func TestMain(m *testing.M) {
+
+	err := data.Init(
+		"../data/data-raw-dump.json",
+	)
+
+	if err != nil {
+		log.Fatalf("could not load data: %v", err)
+	}
+
+	os.Exit(m.Run())
+}
+
+func TestCheck(t *testing.T) {
+
+	for _, test := range []struct {
+		name   string
+		placed []string
+		err    error
+	}{
+		{
+			name:   "power setup",
+			placed: []string{"offshore-pump", "boiler", "steam-engine", "lab"},
+		},
+		{
+			// they share a spot but are never on the map together
+			name:   "replaced furnace",
+			placed: []string{"steel-furnace"},
+		},
+		{
+			name:   "overlap",
+			placed: []string{"assembling-machine-2", "steam-engine"},
+			err:    fmt.Errorf(`[layout] %q overlaps %q`, "assembling-machine-2", "steam-engine"),
+		},
+		{
+			name:   "not in the layout",
+			placed: []string{"rocket-silo"},
+			err:    fmt.Errorf(`[layout] no placement for %q`, "rocket-silo"),
+		},
+	} {
+		t.Run(test.name, func(tt *testing.T) {
+			s := &state.State{}
+			for _, b := range test.placed {
+				s.ConstructBuilding(b, 0)
+			}
+			err := Default.Check(s)
+			if d, _ := diff.Diff(err, test.err); len(d) > 0 {
+				tt.Fatal(d)
+			}
+		})
+	}
+}
+
+func TestWalk(t *testing.T) {
+	task, err := Default.Walk("lab", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the lab is 2.4 tiles across, so this is half of that plus the margin
+	expected := geo.Point{X: 306.5, Y: 172.5 + 1.2 + walkMargin}
+	if !strings.Contains(string(task.Export()), fmt.Sprintf("x = %.2f, y = %.2f", expected.X, expected.Y)) {
+		t.Fatalf("expected a walk to %v, got %s", expected, task.Export())
+	}
+
+	if _, err := Default.Walk("rocket-silo", 0); err == nil {
+		t.Fatal("expected an error walking to an unplaced building")
+	}
+}
+
+func TestExport(t *testing.T) {
+	l := Layout{
+		Buildings: []Placement{
+			{Name: "boiler", Position: geo.Point{X: 1, Y: 2.5}},
+			{Name: "pipe", N: 1, Position: geo.Point{X: 0.5, Y: 0.5}},
+			{Name: "pipe", N: 2, Position: geo.Point{X: 0.5, Y: 1.5}},
+		},
+		Resources: map[string]geo.Point{"coal": {X: 3, Y: 4}},
+	}
+	var b strings.Builder
+	if err := l.Export(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{
+		`    ["boiler"] = {x = 1, y = 2.5},`,
+		`        [2] = {x = 0.5, y = 1.5},`,
+		`    ["coal"] = {x = 3, y = 4},`,
+	} {
+		if !strings.Contains(b.String(), line+"\n") {
+			t.Errorf("missing line %q", line)
+		}
+	}
+}
+
+func TestBlueprint(t *testing.T) {
+
+	s := &state.State{}
+	for _, b := range []string{"offshore-pump", "boiler", "steam-engine", "lab", "oil-refinery"} {
+		s.ConstructBuilding(b, 0)
+	}
+	s.ConstructBuilding("pipe", 1)
+	s.ConstructBuilding("pipe", 2)
+
+	bp, err := Default.Blueprint(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := ParseBlueprint(bp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// exported in the state's order
+	var expected []Placement
+	for _, id := range s.IDs() {
+		p, _ := Default.Get(id.Name, id.N)
+		expected = append(expected, p)
+	}
+	if d, _ := diff.Diff(l.Buildings, expected); len(d) > 0 {
+		t.Fatal(d)
+	}
+	if p, _ := l.Get("oil-refinery", 0); p.Recipe != "basic-oil-processing" || p.Direction != constants.DirectionNorth {
+		t.Fatalf("refinery lost its recipe or direction: %+v", p)
+	}
+	if err := l.Check(s); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Default.Blueprint(&state.State{Buildings: map[state.BuildingID]building.Building{{Name: "rocket-silo"}: nil}}); err == nil {
+		t.Fatal("expected an error exporting a building with no placement")
+	}
+}
+
+func TestParseBlueprint(t *testing.T) {
+
+	// what the game makes: no tags, north left out, centered on the origin
+	game := `{"blueprint":{"item":"blueprint","entities":[` +
+		`{"entity_number":1,"name":"pipe","position":{"x":-0.5,"y":-0.5}},` +
+		`{"entity_number":2,"name":"boiler","position":{"x":1,"y":0.5},"direction":2},` +
+		`{"entity_number":3,"name":"pipe","position":{"x":-0.5,"y":0.5}}` +
+		`],"version":281479278886912}}`
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write([]byte(game))
+	zw.Close()
+
+	l, err := ParseBlueprint("0" + base64.StdEncoding.EncodeToString(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Translate(geo.Point{X: 10, Y: 20})
+
+	expected := []Placement{
+		{Name: "pipe", N: 1, Position: geo.Point{X: 9.5, Y: 19.5}},
+		{Name: "boiler", Position: geo.Point{X: 11, Y: 20.5}, Direction: constants.DirectionEast},
+		{Name: "pipe", N: 2, Position: geo.Point{X: 9.5, Y: 20.5}},
+	}
+	if d, _ := diff.Diff(l.Buildings, expected); len(d) > 0 {
+		t.Fatal(d)
+	}
+
+	for _, bad := range []string{"", "1eNpLAgAAYgBi", "0not base64"} {
+		if _, err := ParseBlueprint(bad); err == nil {
+			t.Errorf("expected an error parsing %q", bad)
+		}
+	}
+}
@@ -1,15 +1,22 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 
-	"github.com/brettschalin/factorio-min-resources/building"
 	"github.com/brettschalin/factorio-min-resources/data"
+	"github.com/brettschalin/factorio-min-resources/geo"
+	"github.com/brettschalin/factorio-min-resources/layout"
 	"github.com/brettschalin/factorio-min-resources/state"
 	"github.com/brettschalin/factorio-min-resources/tas"
 )
 
+// where the character is after mining the crash site at the start of tasks.lua
+var crashSite = geo.Point{X: -37.8, Y: 1.5}
+
 func main() {
 
 	must(data.Init(
@@ -23,69 +30,49 @@ func main() {
 	t := tas.TAS{}
 
 	// this will take a while, might as well speed it up for us
-	t.Add(tas.Speed(100))
-
-	t.Add(makeTechTasks()...)
-
-	var state = state.New()
-	state.Assembler = building.NewAssembler(data.GetAssemblingMachine("assembling-machine-2"))
-	state.Furnace = building.NewFurnace(data.GetFurnace("stone-furnace"))
-	state.Lab = building.NewLab(data.GetLab("lab"))
-	state.Boiler = building.NewBoiler(data.GetBoiler("boiler"))
-	state.Refinery = building.NewAssembler(data.GetAssemblingMachine("oil-refinery"))
-	state.Chem = building.NewAssembler(data.GetAssemblingMachine("chemical-plant"))
-
-	tasks, f := makePowerSetup(state)
-	must(t.Add(tasks...))
-
-	tasks, f = researchRGTech("steel-processing", state, f)
-	must(t.Add(tasks...))
-
-	tasks, f = researchRGTech("logistic-science-pack", state, f)
-	must(t.Add(tasks...))
-
-	tasks, f = researchRGTech("automation", state, f)
-	must(t.Add(tasks...))
-
-	tasks, f = researchRGTech("electronics", state, f)
-	must(t.Add(tasks...))
+	must(t.Add(tas.Speed(100)))
 
-	tasks, f = researchRGTech("optics", state, f)
-	must(t.Add(tasks...))
+	// every segment plans around the state the TAS is verified to be in once the ones before it are done.
+	// Smelting needs a furnace, so that goes first
+	must(t.Add(tas.Build("stone-furnace", 0)))
 
-	tasks, f = buildSolarPanel(state, f)
-	must(t.Add(tasks...))
+	must(t.Add(makePowerSetup(t.State())...))
 
-	tasks, f = buildSteelFurnace(state, f)
-	must(t.Add(tasks...))
-
-	tasks, f = researchRGTech("automation-2", state, f)
-	must(t.Add(tasks...))
-
-	tasks, f = researchRGTech("engine", state, f)
-	must(t.Add(tasks...))
-
-	tasks, f = researchRGTech("fluid-handling", state, f)
-	must(t.Add(tasks...))
-
-	tasks, f = researchRGTech("oil-processing", state, f)
-	must(t.Add(tasks...))
-
-	tasks, f = researchModules(state, f)
-	must(t.Add(tasks...))
-
-	tasks, f = buildOilSetup(state, f)
-	must(t.Add(tasks...))
+	for _, tech := range techs {
+		must(t.Add(research(tech, t.State())...))
+	}
 
-	tasks, f = prodmod1(state, f)
-	must(t.Add(tasks...))
+	must(t.Add(buildOilSetup(t.State())...))
+	must(t.Add(prodmod1(t.State())...))
+	must(t.Add(buildElectricFurnace(t.State())...))
 
-	must(t.Add(buildElectricFurnace(state, f)...))
+	must(t.Add(tas.Speed(1)))
 
-	t.Add(tas.Speed(1))
+	walked, err := t.InsertWalks(layout.Default.Locate, crashSite)
+	must(err)
+	fmt.Fprintf(os.Stderr, "walking %.1f tiles\n", walked)
 
 	must(t.Export(out))
+	must(exportNextToOutput("tasks.json", t.ExportJSON))
+
+	must(exportNextToOutput("locations.lua", layout.Default.Export))
+
+	// what's on the map at the end, for checking the layout in game
+	if bp, err := layout.Default.Blueprint(t.State()); err != nil {
+		fmt.Fprintf(os.Stderr, "not writing blueprint: %v\n", err)
+	} else {
+		must(exportNextToOutput("blueprint.txt", func(w io.Writer) error {
+			_, err := io.WriteString(w, bp+"\n")
+			return err
+		}))
+	}
 
+	expected, err := t.ExpectedResources()
+	must(err)
+	fmt.Fprintf(os.Stderr, "expected resources: %v\n", expected)
+	must(exportNextToOutput("expected_resources.json", func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(expected)
+	}))
 }
 
 func must(e error) {
@@ -94,6 +81,19 @@ func must(e error) {
 	}
 }
 
+// exportNextToOutput writes a file in the same directory as the output file. Nothing is written when the tasks go to stdout
+func exportNextToOutput(name string, export func(io.Writer) error) error {
+	if len(os.Args) <= 1 {
+		return nil
+	}
+	f, err := os.Create(filepath.Join(filepath.Dir(os.Args[1]), name))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return export(f)
+}
+
 func getOutputFile() (file io.Writer, close func() error, err error) {
 	if len(os.Args) > 1 {
 		f, err := os.Create(os.Args[1])
@@ -106,7 +106,8 @@ func getOutputFile() (file io.Writer, close func() error, err error) {
 	return os.Stdout, func() error { return nil }, nil
 }
 
-// the technologies to research, in this specific order
+// Technologies are researched in this order, each by its own segment (see research). Each segment queues
+// its tech, so nothing's queued that the run doesn't finish
 var techs = []string{
 	"steel-processing",
 	"logistic-science-pack", // green science packs
@@ -152,21 +153,28 @@ var techs = []string{
 
 	// * this needs analysis. Are the two extra module slots an assembler 3 have
 	// worth the cost of researching the tech, seeing as it isn't required to build the silo?
+	// cmd/search can compare plans with and without it once the data has the techs
 
 }
 
+// research returns the segment that researches the tech. Most only make the science packs, but some
+// also build what the tech unlocks
+func research(tech string, state *state.State) tas.Tasks {
+	switch tech {
+	case "solar-energy":
+		return buildSolarPanel(state)
+	case "advanced-material-processing":
+		return buildSteelFurnace(state)
+	}
+	return researchRGTech(tech, state)
+}
+
 // exists for easier prerequisite definitions
 var techMap = map[string]tas.Task{}
 
-func makeTechTasks() tas.Tasks {
-
-	tasks := make(tas.Tasks, len(techs))
-
-	for i, t := range techs {
-		tech := tas.Tech(t)
-		tasks[i] = tech
-		techMap[t] = tech
-	}
-
-	return tasks
+// queueTech returns the task that queues the tech, and keeps it for later segments to depend on
+func queueTech(tech string) tas.Task {
+	t := tas.Tech(tech)
+	techMap[tech] = t
+	return t
 }
@@ -0,0 +1,160 @@
+package mapgen
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/brettschalin/factorio-min-resources/geo"
+)
+
+// Factorio writes everything little endian. Counts and string lengths are "space optimized": one byte,
+// or 0xFF followed by a uint32 if they don't fit. Positions are fixed point (1/256 of a tile), and are
+// written relative to the last one if that fits in two int16s
+
+// written instead of an x offset when the position is stored in full
+const fullPosition = 0x7FFF
+
+type reader struct {
+	b    []byte
+	off  int
+	err  error
+	last [2]int32
+}
+
+func (r *reader) read(n int) []byte {
+	if r.err != nil {
+		return make([]byte, n)
+	}
+	if r.off+n > len(r.b) {
+		r.err = fmt.Errorf(`[mapgen] unexpected end of data at byte %d: %w`, r.off, io.ErrUnexpectedEOF)
+		return make([]byte, n)
+	}
+	out := r.b[r.off : r.off+n]
+	r.off += n
+	return out
+}
+
+func (r *reader) u8() uint8 {
+	return r.read(1)[0]
+}
+
+func (r *reader) u16() uint16 {
+	return binary.LittleEndian.Uint16(r.read(2))
+}
+
+func (r *reader) u32() uint32 {
+	return binary.LittleEndian.Uint32(r.read(4))
+}
+
+func (r *reader) f32() float32 {
+	return math.Float32frombits(r.u32())
+}
+
+func (r *reader) bool() bool {
+	return r.u8() != 0
+}
+
+func (r *reader) count() int {
+	n := uint32(r.u8())
+	if n == 0xFF {
+		n = r.u32()
+	}
+	if r.err == nil && int(n) > len(r.b)-r.off {
+		// every entry is at least a byte, so there isn't room for them all
+		r.err = fmt.Errorf(`[mapgen] unexpected end of data at byte %d: count of %d: %w`, r.off, n, io.ErrUnexpectedEOF)
+	}
+	if r.err != nil {
+		return 0
+	}
+	return int(n)
+}
+
+func (r *reader) str() string {
+	return string(r.read(r.count()))
+}
+
+func (r *reader) position() geo.Point {
+	if dx := int16(r.u16()); dx == fullPosition {
+		r.last[0] = int32(r.u32())
+		r.last[1] = int32(r.u32())
+	} else {
+		r.last[0] += int32(dx)
+		r.last[1] += int32(int16(r.u16()))
+	}
+	return geo.Point{X: float64(r.last[0]) / 256, Y: float64(r.last[1]) / 256}
+}
+
+type writer struct {
+	bytes.Buffer
+	last [2]int32
+}
+
+func (w *writer) u8(v uint8) {
+	w.WriteByte(v)
+}
+
+func (w *writer) u16(v uint16) {
+	var b [2]byte
+	binary.LittleEndian.PutUint16(b[:], v)
+	w.Write(b[:])
+}
+
+func (w *writer) u32(v uint32) {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], v)
+	w.Write(b[:])
+}
+
+func (w *writer) f32(v float32) {
+	w.u32(math.Float32bits(v))
+}
+
+func (w *writer) bool(v bool) {
+	if v {
+		w.u8(1)
+	} else {
+		w.u8(0)
+	}
+}
+
+func (w *writer) count(n int) {
+	if n < 0xFF {
+		w.u8(uint8(n))
+		return
+	}
+	w.u8(0xFF)
+	w.u32(uint32(n))
+}
+
+func (w *writer) str(s string) {
+	w.count(len(s))
+	w.WriteString(s)
+}
+
+func (w *writer) position(p geo.Point) {
+	x, y := int32(math.Round(p.X*256)), int32(math.Round(p.Y*256))
+	dx, dy := int64(x)-int64(w.last[0]), int64(y)-int64(w.last[1])
+	if dx < math.MinInt16 || dx >= fullPosition || dy < math.MinInt16 || dy > math.MaxInt16 {
+		w.u16(fullPosition)
+		w.u32(uint32(x))
+		w.u32(uint32(y))
+	} else {
+		w.u16(uint16(int16(dx)))
+		w.u16(uint16(int16(dy)))
+	}
+	w.last = [2]int32{x, y}
+}
+
+// the game keeps its dictionaries sorted, so writing them the same way gives back the same bytes
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
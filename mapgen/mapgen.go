@@ -0,0 +1,293 @@
+// package mapgen decodes and encodes map exchange strings, the `>>>...<<<` strings the game uses to share
+// map settings. Only the map generation settings are decoded; the rest (pollution, evolution, pathfinding
+// and so on) is kept as-is so the string still round trips
+package mapgen
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/brettschalin/factorio-min-resources/geo"
+)
+
+// Default is the map from SETUP.md, which the plans and layout are built for
+const Default = `>>>eNpjZGBkiABiIGiwB2EOluT8xBwGhgMOMMyVnF9QkFqkm1+Ui
+izMmVxUmpKqm5+Jqjg1LzW3UjcpsTgVYiLEZI7Movw8dBNYi0vy8
+1BFSopSU4uRNXKXFiXmZZbmQvQixBkYl77/H9HQIscAwv/rGRT+/
+wdhIOsBUAEIMzA2QFQCxaCASTY5P6+kKD9Htzi1pCQzL90qNz+zu
+KS0KNUqKTOxmMNAz9QABHRxKksrSi0sTc1LrrTKLc0pySzIyUwtA
+mozNAMCc9bknMy0NAYGBUcgdgI7gYGxWmSd+8OqKfaMECfoOUAZH
+6AiB5JgIp4whp8DTikVGMMEyRxjMPiMxIBYWgK0AqqKwwHBgEi2g
+CQZGXvfbl3w/dgFO8Y/Kz9e8k1KsGc0dBV598FonR1Qkh3kBSY4M
+WsmCOyEeYUBZuYDe6jUTXvGs2dA4I09IytIhwiIcLAAEge8mRkYB
+fiArAU9QEJBhgHmNDuYMSIOjGlg8A3mk8cwxmV7dH8AA8IGZLgci
+DgBIsAWwl3GCGE69DswOsjDZCURSoD6jRiQ3ZCC8OFJmLWHkexHc
+whmRCD7A01ExQFLNHCBLEyBEy+Y4a4BhucFdhjPYb4DIzOIAVL1B
+SgG4YFkYEZBaAEHcHDDZKFpI/PitU4AtZ/FiQ==<<<`
+
+// Version is the game version that made the string
+type Version struct {
+	Major, Minor, Sub, Build uint16
+
+	// always 0 in 1.1
+	Extra uint8
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Sub)
+}
+
+// FrequencySizeRichness is one autoplace control, as set by the sliders on the map generation screen.
+// 1 is the default and a size of 0 turns it off
+type FrequencySizeRichness struct {
+	Frequency float32
+	Size      float32
+	Richness  float32
+}
+
+// AutoplaceSettings overrides autoplacement for one kind of thing ("entity", "tile" or "decorative")
+type AutoplaceSettings struct {
+	TreatMissingAsDefault bool
+	Settings              map[string]FrequencySizeRichness
+}
+
+// CliffSettings controls where cliffs go. A richness of 0 means no cliffs
+type CliffSettings struct {
+	Name              string
+	Elevation0        float32
+	ElevationInterval float32
+	Richness          float32
+}
+
+// Settings are the game's MapGenSettings
+type Settings struct {
+	TerrainSegmentation float32
+	Water               float32
+
+	// resources, trees and enemy bases
+	AutoplaceControls map[string]FrequencySizeRichness
+	AutoplaceSettings map[string]AutoplaceSettings
+
+	DefaultEnableAllAutoplaceControls bool
+
+	Seed          uint32
+	Width, Height uint32
+
+	// the area generated before the player is placed, and how the game stored the box's orientation.
+	// The orientation is kept as-is so the string round trips
+	StartArea            geo.Rectangle
+	startAreaOrientation [4]byte
+
+	StartingArea   float32
+	PeacefulMode   bool
+	StartingPoints []geo.Point
+
+	// noise expression overrides, e.g. "control-setting:moisture:bias"
+	PropertyExpressionNames map[string]string
+
+	Cliffs CliffSettings
+}
+
+// Exchange is a decoded map exchange string
+type Exchange struct {
+	Version Version
+	MapGen  Settings
+
+	// everything after the map generation settings. Not decoded
+	MapSettings []byte
+}
+
+// Decode reads a map exchange string. The `>>>` and `<<<` markers are optional and whitespace is ignored,
+// so strings copied from SETUP.md or the game's text box both work
+func Decode(s string) (*Exchange, error) {
+
+	s = strings.TrimSpace(s)
+	s = strings.TrimSuffix(strings.TrimPrefix(s, ">>>"), "<<<")
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+
+	compressed, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf(`[mapgen] %w`, err)
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf(`[mapgen] %w`, err)
+	}
+	b, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf(`[mapgen] %w`, err)
+	}
+
+	return unmarshal(b)
+}
+
+// Encode writes the exchange back out as a string the game can import
+func (e *Exchange) Encode() string {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(e.marshal())
+	zw.Close()
+	return ">>>" + base64.StdEncoding.EncodeToString(buf.Bytes()) + "<<<"
+}
+
+// Equal reports whether two exchanges describe the same map. Two strings for the same map can differ
+// in how they're compressed, so compare these instead of the strings
+func (e *Exchange) Equal(o *Exchange) bool {
+	return bytes.Equal(e.marshal(), o.marshal())
+}
+
+func unmarshal(b []byte) (*Exchange, error) {
+
+	// the last 4 bytes are a CRC32 of the rest
+	if len(b) < 4 {
+		return nil, fmt.Errorf(`[mapgen] data too short: %d bytes`, len(b))
+	}
+	data := b[:len(b)-4]
+	if want, got := binary.LittleEndian.Uint32(b[len(b)-4:]), crc32.ChecksumIEEE(data); want != got {
+		return nil, fmt.Errorf(`[mapgen] checksum mismatch: string says %08x, data is %08x`, want, got)
+	}
+
+	r := &reader{b: data}
+	e := &Exchange{}
+
+	e.Version = Version{Major: r.u16(), Minor: r.u16(), Sub: r.u16(), Build: r.u16(), Extra: r.u8()}
+
+	g := &e.MapGen
+	g.TerrainSegmentation = r.f32()
+	g.Water = r.f32()
+	g.AutoplaceControls = readControls(r)
+
+	n := r.count()
+	g.AutoplaceSettings = make(map[string]AutoplaceSettings, n)
+	for i := 0; i < n; i++ {
+		name := r.str()
+		g.AutoplaceSettings[name] = AutoplaceSettings{
+			TreatMissingAsDefault: r.bool(),
+			Settings:              readControls(r),
+		}
+	}
+
+	g.DefaultEnableAllAutoplaceControls = r.bool()
+	g.Seed = r.u32()
+	g.Width = r.u32()
+	g.Height = r.u32()
+	g.StartArea.TopLeft = r.position()
+	g.StartArea.BottomRight = r.position()
+	copy(g.startAreaOrientation[:], r.read(4))
+	g.StartingArea = r.f32()
+	g.PeacefulMode = r.bool()
+
+	n = r.count()
+	g.StartingPoints = make([]geo.Point, n)
+	for i := range g.StartingPoints {
+		g.StartingPoints[i] = r.position()
+	}
+
+	n = r.count()
+	g.PropertyExpressionNames = make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		k := r.str()
+		g.PropertyExpressionNames[k] = r.str()
+	}
+
+	g.Cliffs = CliffSettings{
+		Name:              r.str(),
+		Elevation0:        r.f32(),
+		ElevationInterval: r.f32(),
+		Richness:          r.f32(),
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	e.MapSettings = append([]byte{}, data[r.off:]...)
+	return e, nil
+}
+
+func readControls(r *reader) map[string]FrequencySizeRichness {
+	n := r.count()
+	out := make(map[string]FrequencySizeRichness, n)
+	for i := 0; i < n; i++ {
+		name := r.str()
+		out[name] = FrequencySizeRichness{Frequency: r.f32(), Size: r.f32(), Richness: r.f32()}
+	}
+	return out
+}
+
+// marshal writes the binary form, checksum included
+func (e *Exchange) marshal() []byte {
+
+	w := &writer{}
+
+	v := e.Version
+	w.u16(v.Major)
+	w.u16(v.Minor)
+	w.u16(v.Sub)
+	w.u16(v.Build)
+	w.u8(v.Extra)
+
+	g := &e.MapGen
+	w.f32(g.TerrainSegmentation)
+	w.f32(g.Water)
+	writeControls(w, g.AutoplaceControls)
+
+	w.count(len(g.AutoplaceSettings))
+	for _, name := range sortedKeys(g.AutoplaceSettings) {
+		w.str(name)
+		w.bool(g.AutoplaceSettings[name].TreatMissingAsDefault)
+		writeControls(w, g.AutoplaceSettings[name].Settings)
+	}
+
+	w.bool(g.DefaultEnableAllAutoplaceControls)
+	w.u32(g.Seed)
+	w.u32(g.Width)
+	w.u32(g.Height)
+	w.position(g.StartArea.TopLeft)
+	w.position(g.StartArea.BottomRight)
+	w.Write(g.startAreaOrientation[:])
+	w.f32(g.StartingArea)
+	w.bool(g.PeacefulMode)
+
+	w.count(len(g.StartingPoints))
+	for _, p := range g.StartingPoints {
+		w.position(p)
+	}
+
+	w.count(len(g.PropertyExpressionNames))
+	for _, k := range sortedKeys(g.PropertyExpressionNames) {
+		w.str(k)
+		w.str(g.PropertyExpressionNames[k])
+	}
+
+	w.str(g.Cliffs.Name)
+	w.f32(g.Cliffs.Elevation0)
+	w.f32(g.Cliffs.ElevationInterval)
+	w.f32(g.Cliffs.Richness)
+
+	w.Write(e.MapSettings)
+	w.u32(crc32.ChecksumIEEE(w.Bytes()))
+	return w.Bytes()
+}
+
+func writeControls(w *writer, controls map[string]FrequencySizeRichness) {
+	w.count(len(controls))
+	for _, name := range sortedKeys(controls) {
+		c := controls[name]
+		w.str(name)
+		w.f32(c.Frequency)
+		w.f32(c.Size)
+		w.f32(c.Richness)
+	}
+}
@@ -0,0 +1,173 @@
+package mapgen
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"hash/crc32"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/brettschalin/factorio-min-resources/geo"
+	"github.com/r3labs/diff/v3"
+)
+
+// the raw bytes of a string, to check re-encoding gives back exactly what the game wrote
+func inflate(t *testing.T, s string) []byte {
+	s = strings.Join(strings.Fields(strings.TrimSuffix(strings.TrimPrefix(s, ">>>"), "<<<")), "")
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestDecode(t *testing.T) {
+
+	e, err := Decode(Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v := e.Version.String(); v != "1.1.88" {
+		t.Errorf("version: expected 1.1.88, got %s", v)
+	}
+
+	g := e.MapGen
+	if g.Seed != 1493168037 {
+		t.Errorf("seed: expected 1493168037, got %d", g.Seed)
+	}
+	if g.Water != 1 {
+		t.Errorf("water: expected 1, got %v", g.Water)
+	}
+
+	ore := FrequencySizeRichness{Frequency: 6, Size: 6, Richness: 6}
+	off := FrequencySizeRichness{Frequency: 1, Size: 0, Richness: 1}
+	expected := map[string]FrequencySizeRichness{
+		"coal":        ore,
+		"copper-ore":  ore,
+		"crude-oil":   ore,
+		"iron-ore":    ore,
+		"stone":       ore,
+		"enemy-base":  off,
+		"trees":       off,
+		"uranium-ore": off,
+	}
+	if d, _ := diff.Diff(g.AutoplaceControls, expected); len(d) > 0 {
+		t.Errorf("autoplace controls: %v", d)
+	}
+
+	if d, _ := diff.Diff(g.Cliffs, CliffSettings{Name: "cliff", Elevation0: 10, ElevationInterval: 40}); len(d) > 0 {
+		t.Errorf("cliffs: %v", d)
+	}
+
+	start := geo.Rectangle{TopLeft: geo.Point{X: -224, Y: -224}, BottomRight: geo.Point{X: 224, Y: 224}}
+	if g.StartArea != start {
+		t.Errorf("start area: expected %v, got %v", start, g.StartArea)
+	}
+	if d, _ := diff.Diff(g.StartingPoints, []geo.Point{{}}); len(d) > 0 {
+		t.Errorf("starting points: %v", d)
+	}
+	if v := g.PropertyExpressionNames["control-setting:moisture:bias"]; v != "0.500000" {
+		t.Errorf("moisture bias: expected 0.500000, got %q", v)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+
+	e, err := Decode(Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(e.marshal(), inflate(t, Default)) {
+		t.Fatal("re-encoded data differs from the original")
+	}
+
+	// change some settings and make sure they come back, with everything else left alone
+	e.MapGen.Seed = 12345
+	e.MapGen.Water = 2
+	e.MapGen.AutoplaceControls["trees"] = FrequencySizeRichness{Frequency: 1, Size: 1, Richness: 1}
+	e.MapGen.StartingPoints = append(e.MapGen.StartingPoints, geo.Point{X: 10.5, Y: -3})
+
+	out, err := Decode(e.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !out.Equal(e) {
+		t.Fatal("decoded settings differ from what was encoded")
+	}
+	if d, _ := diff.Diff(out.MapGen, e.MapGen); len(d) > 0 {
+		t.Fatal(d)
+	}
+	if !bytes.Equal(out.MapSettings, e.MapSettings) {
+		t.Fatal("map settings changed")
+	}
+
+	orig, _ := Decode(Default)
+	if out.Equal(orig) {
+		t.Fatal("modified settings compare equal to the original")
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+
+	e, err := Decode(Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	corrupt := func(f func(b []byte) []byte) string {
+		b := f(e.marshal())
+		var buf bytes.Buffer
+		zw := zlib.NewWriter(&buf)
+		zw.Write(b)
+		zw.Close()
+		return base64.StdEncoding.EncodeToString(buf.Bytes())
+	}
+
+	for _, test := range []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{
+			name:  "not base64",
+			input: ">>>not a map string<<<",
+			err:   "[mapgen] illegal base64 data",
+		},
+		{
+			name: "bad checksum",
+			input: corrupt(func(b []byte) []byte {
+				b[len(b)-1] ^= 0xFF
+				return b
+			}),
+			err: "[mapgen] checksum mismatch",
+		},
+		{
+			name: "truncated",
+			input: corrupt(func(b []byte) []byte {
+				w := &writer{}
+				w.Write(b[:20])
+				w.u32(crc32.ChecksumIEEE(b[:20]))
+				return w.Bytes()
+			}),
+			err: "[mapgen] unexpected end of data",
+		},
+	} {
+		t.Run(test.name, func(tt *testing.T) {
+			_, err := Decode(test.input)
+			if err == nil || !strings.HasPrefix(err.Error(), test.err) {
+				tt.Fatalf("expected an error starting with %q, got %v", test.err, err)
+			}
+		})
+	}
+}
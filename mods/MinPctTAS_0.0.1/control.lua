@@ -22,8 +22,8 @@ end
 
 
 -- This keeps track of what we've mined (see the event hook at the bottom of this file)
--- and will be printed when the TAS is done. A more robust option in the future is to
--- grab the production stats for these resources directly but that's not implemented yet
+-- and will be printed when the TAS is done, along with the force's production stats for
+-- the same resources. cmd/resdiff compares them against what the Go code predicted
 local resources_used = {
 	["iron-ore"] = 0,
 	["copper-ore"] = 0,
@@ -46,7 +46,7 @@ local resources_used = {
 --   chems      - direction indicates the side where the fluids are input
 --   refineries - direction indicates the side where the fluids output
 --   pumps      - direction indicates the side where the fluid is input
-local function build(p, position, item, direction)
+local function build(p, position, item, direction, n)
 	-- Check if we have the item
 
 	local count = p.get_item_count(item) 
@@ -73,7 +73,7 @@ local function build(p, position, item, direction)
 	-- place the item
 	p.surface.create_entity{name = item, position = position, direction = direction, force = "player"}
 	p.remove_item({name = item, count = 1})
-	local b = buildings.build(p, item, position)
+	local b = buildings.build(p, item, position, n)
 	debug(p, string.format("(%d) placed building %s", game.tick, serpent.block(b)))
 
 	return true
@@ -346,7 +346,20 @@ script.on_event(defines.events.on_tick, function(event)
 		time_str = time_str .. string.format("%d seconds", seconds % 60)
 
 		p.print(string.format("(%.2f, %.2f) Complete after %s (%d ticks)", pos.x, pos.y, time_str, p.online_time))	
-		p.print("Resources used:"..game.table_to_json(resources_used))
+		local production = {}
+		for name, _ in pairs(resources_used) do
+			production[name] = p.force.item_production_statistics.get_input_count(name)
+		end
+		production["wood"] = p.force.item_production_statistics.get_input_count("wood")
+
+		local result = game.table_to_json({
+			ticks = p.online_time,
+			resources_used = resources_used,
+			production = production
+		})
+		p.print("Resources used:"..result)
+		log("Resources used:"..result)
+		game.write_file("MinPctTAS/resources.json", result, false)
 		dbg = 0
 		done = true
 		return
@@ -457,7 +470,7 @@ script.on_event(defines.events.on_tick, function(event)
 		destination = args.location
 	elseif task == "put" or task == "take" or
 		task == "recipe" then
-			building = buildings.get(p, args.entity)
+			building = buildings.get(p, args.entity, args.n)
 			destination = building.location
 			args.location = building.location
 	elseif task == "build" then
@@ -470,7 +483,7 @@ script.on_event(defines.events.on_tick, function(event)
 	elseif task == "mine" then
 		if args.location == nil then
 			if args.resource == nil then
-				building = buildings.get(p, args.entity)
+				building = buildings.get(p, args.entity, args.n)
 				destination = building.location
 				args.location = building.location
 			else
@@ -485,7 +498,7 @@ script.on_event(defines.events.on_tick, function(event)
 
 	-- now try to do the task
 	if task == "build" then
-		cr = build(p, args.location, args.entity, args.location.dir or defines.direction.north)
+		cr = build(p, args.location, args.entity, args.location.dir or defines.direction.north, args.n)
 	elseif task == "recipe" then
 		cr = recipe(p, args.location, args.recipe)
 	elseif task == "mine" then
@@ -18,7 +18,7 @@ function populate_tasks(queues)
 
 -- Mining / inventory transfers. Checks that some amount of
 -- item is in the machine
-local function has_inventory(inv, item, amount, exact, slot)
+local function has_inventory(inv, item, amount, exact, slot, n)
     return function(p)
         if type(inv) == "function" then
             local cnt = inv().get_item_count(item)
@@ -38,7 +38,7 @@ local function has_inventory(inv, item, amount, exact, slot)
         else
             -- it's the name of a building. This will throw an error if the building isn't placed,
             -- which is a problem with the task dependencies rather than this code
-            b = loc.buildings.get(p, inv).entity
+            b = loc.buildings.get(p, inv, n).entity
 
             if slot then
                 slots = {
@@ -85,9 +85,17 @@ local function has_inventory(inv, item, amount, exact, slot)
 end
 
 -- is the building placed on the map?
-local function is_built(entity)
+local function is_built(entity, n)
     return function(p)
-        return loc.buildings.is_placed(p, entity)
+        return loc.buildings.is_placed(p, entity, n)
+    end
+end
+
+-- has the building been mined? Checking the inventory instead would be done straight away if
+-- the player was already holding one
+local function is_mined(entity, n)
+    return function(p)
+        return not loc.buildings.is_placed(p, entity, n)
     end
 end
 
@@ -182,14 +190,13 @@ function add_task(task, prereqs, args)
         if args.location ~= nil then
             done = mined
         elseif args.entity ~= nil then
-            -- TODO: replace this with mined?
-            done = has_inventory("player", args.entity, args.amount or 1)
+            done = is_mined(args.entity, args.n)
         else
             done = has_inventory("player", args.resource, args.amount or 1)
         end
     elseif task == "build" then
         q = "character_action"
-        done = is_built(args.entity)
+        done = is_built(args.entity, args.n)
     elseif task == "wait" then
         q = "character_action"
     elseif task == "craft" then
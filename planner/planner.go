@@ -0,0 +1,426 @@
+// Package planner generates the tasks needed to reach a goal from a given state
+package planner
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/brettschalin/factorio-min-resources/building"
+	"github.com/brettschalin/factorio-min-resources/calc"
+	"github.com/brettschalin/factorio-min-resources/constants"
+	"github.com/brettschalin/factorio-min-resources/data"
+	"github.com/brettschalin/factorio-min-resources/shims"
+	"github.com/brettschalin/factorio-min-resources/state"
+	"github.com/brettschalin/factorio-min-resources/tas"
+)
+
+// Goal is what a plan should end with: technologies researched and items in the player's inventory
+type Goal struct {
+	Techs []string
+	Items map[string]uint
+}
+
+type planner struct {
+	s     *state.State
+	fuel  string
+	tasks tas.Tasks
+
+	// what we expect the player to be holding once the tasks so far have run
+	inv calc.Items[uint]
+
+	// fuel mined but not yet burned by the furnace, as returned by tas.MineFuelAndSmelt
+	extraFuel float64
+
+	// in a dry run no tasks are emitted; only the ore to smelt is counted, so that it can all be
+	// smelted up front instead of one small batch at a time
+	dry   bool
+	smelt map[string]uint
+
+	// the last handcraft of each item. The crafting queue doesn't hold items yet to be crafted,
+	// so crafts using them wait on the task instead of the inventory
+	crafted map[string]tas.Task
+}
+
+// Plan returns the tasks that reach the goal from `s`, using `constants.PreferredFuel` for every burner.
+// Smelting is done with tas.MineFuelAndSmelt in the first furnace, recipes that can't be handcrafted
+// with tas.MachineCraft in the first machine that can craft them, and research in every placed lab.
+// `s` is the planning state: like tas.MachineCraft, the machines in it are updated as tasks are planned,
+// and on success its inventory and researched technologies match the end of the plan
+func Plan(goal Goal, s *state.State) (tas.Tasks, error) {
+
+	techs, err := techOrder(goal.Techs, s.TechResearched)
+	if err != nil {
+		return nil, err
+	}
+
+	// everything the player needs to get their hands on, science packs included
+	items := map[string]uint{}
+	for item, n := range goal.Items {
+		items[item] += n
+	}
+	for _, tech := range techs {
+		for pack, n := range calc.TechCost(tech) {
+			items[pack] += uint(n)
+		}
+	}
+
+	names := make([]string, 0, len(items))
+	for item := range items {
+		names = append(names, item)
+	}
+	sort.Strings(names)
+
+	// find out how much needs smelting
+	dry := &planner{
+		s:     s,
+		fuel:  constants.PreferredFuel,
+		inv:   copyInventory(s.Inventory),
+		dry:   true,
+		smelt: map[string]uint{},
+	}
+	for _, item := range names {
+		if err := dry.need(item, items[item]); err != nil {
+			return nil, err
+		}
+	}
+
+	p := &planner{
+		s:       s,
+		fuel:    constants.PreferredFuel,
+		tasks:   tas.Tasks{},
+		inv:     copyInventory(s.Inventory),
+		crafted: map[string]tas.Task{},
+	}
+
+	// ores first, then anything smelted from their plates (steel)
+	ores := make([]string, 0, len(dry.smelt))
+	for ore := range dry.smelt {
+		ores = append(ores, ore)
+	}
+	sort.Slice(ores, func(i, j int) bool {
+		mi, mj := calc.MinableResources[ores[i]], calc.MinableResources[ores[j]]
+		if mi != mj {
+			return mi
+		}
+		return ores[i] < ores[j]
+	})
+	for _, ore := range ores {
+		if err := p.smeltOre(ore, dry.smelt[ore]); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, item := range names {
+		if err := p.need(item, items[item]); err != nil {
+			return nil, err
+		}
+	}
+
+	// the goal items stay with the player
+	for item, n := range goal.Items {
+		p.inv[item] += n
+	}
+
+	for _, tech := range techs {
+		if err := p.research(tech); err != nil {
+			return nil, err
+		}
+	}
+
+	s.Inventory = p.inv
+	for _, tech := range techs {
+		s.MarkResearched(tech)
+	}
+
+	return p.tasks, nil
+}
+
+// techOrder returns the techs and all their unresearched prerequisites, with every tech after its prerequisites
+func techOrder(techs []string, researched map[string]bool) ([]string, error) {
+	var (
+		order []string
+		seen  = map[string]bool{}
+		visit func(name string) error
+	)
+
+	visit = func(name string) error {
+		if researched[name] || seen[name] {
+			return nil
+		}
+		seen[name] = true
+		tech := data.GetTech(name)
+		if tech == nil {
+			return fmt.Errorf(`[planner] unknown technology %q`, name)
+		}
+		for _, p := range tech.Prerequisites {
+			if err := visit(p); err != nil {
+				return err
+			}
+		}
+		order = append(order, name)
+		return nil
+	}
+
+	for _, t := range techs {
+		if err := visit(t); err != nil {
+			return nil, err
+		}
+	}
+
+	return order, nil
+}
+
+func copyInventory(inv map[string]uint) calc.Items[uint] {
+	out := make(calc.Items[uint], len(inv))
+	out.Merge(inv)
+	return out
+}
+
+// need makes sure `amount` of `item` will be in the player's inventory, then sets them aside
+func (p *planner) need(item string, amount uint) error {
+	if have := p.inv[item]; have < amount {
+		if err := p.produce(item, amount-have); err != nil {
+			return err
+		}
+	}
+	p.inv[item] -= amount
+	return nil
+}
+
+// produce adds at least `amount` of `item` to the player's inventory
+func (p *planner) produce(item string, amount uint) error {
+
+	if calc.MinableResources[item] {
+		if !p.dry {
+			p.tasks.Add(tas.MineResource(item, amount))
+		}
+		p.inv[item] += amount
+		return nil
+	}
+
+	rec := data.GetRecipe(item)
+	if rec == nil {
+		return fmt.Errorf(`[planner] %q can't be mined or crafted`, item)
+	}
+
+	switch {
+	case rec.Category == "smelting":
+		ore := rec.Ingredients[0]
+		nOre := uint(math.Ceil(float64(amount)/float64(rec.ProductCount(item)))) * uint(ore.Amount)
+		if p.dry {
+			p.smelt[ore.Name] += nOre
+			if !calc.MinableResources[ore.Name] {
+				if err := p.need(ore.Name, nOre); err != nil {
+					return err
+				}
+			}
+			p.inv[item] += nOre * uint(rec.ProductCount(item)) / uint(ore.Amount)
+			return nil
+		}
+		return p.smeltOre(ore.Name, nOre)
+	case rec.CanHandcraft():
+		return p.handcraft(rec, amount)
+	default:
+		return p.machineCraft(rec, amount)
+	}
+}
+
+// smeltOre mines (if it can be mined) and smelts `nOre` of `ore` in the first furnace
+func (p *planner) smeltOre(ore string, nOre uint) error {
+	furnace := p.s.Furnace()
+	if furnace == nil {
+		return fmt.Errorf(`[planner] no furnace to smelt %q`, ore)
+	}
+	rec := data.GetSmeltingRecipe(ore)
+	if rec == nil {
+		return fmt.Errorf(`[planner] %q can't be smelted`, ore)
+	}
+
+	if !calc.MinableResources[ore] {
+		// tas.MineFuelAndSmelt takes these from the inventory
+		if err := p.need(ore, nOre); err != nil {
+			return err
+		}
+	}
+
+	var tasks tas.Tasks
+	tasks, p.extraFuel = tas.MineFuelAndSmelt(ore, p.fuel, furnace, nOre, p.extraFuel)
+	p.tasks.Add(tasks...)
+
+	plate := rec.GetResults()[0]
+	p.inv[plate.Name] += nOre * uint(plate.Amount) / uint(rec.Ingredients.Amount(ore))
+	return nil
+}
+
+func (p *planner) handcraft(rec *data.Recipe, amount uint) error {
+
+	nCrafts := uint(math.Ceil(float64(amount) / float64(rec.ProductCount(rec.Name))))
+
+	for _, ing := range rec.Ingredients {
+		if err := p.need(ing.Name, nCrafts*uint(ing.Amount)); err != nil {
+			return err
+		}
+	}
+
+	for _, prod := range rec.GetResults() {
+		p.inv[prod.Name] += nCrafts * uint(prod.Amount)
+	}
+
+	if p.dry {
+		return nil
+	}
+
+	t := tas.Craft(rec.Name, nCrafts)
+	for _, ing := range rec.Ingredients {
+		if c, ok := p.crafted[ing.Name]; ok {
+			t.Prerequisites().Add(c)
+		} else {
+			t.Prerequisites().Add(playerHasItem(ing.Name, nCrafts*uint(ing.Amount)))
+		}
+	}
+	p.tasks.Add(t)
+	p.crafted[rec.Name] = t
+
+	return nil
+}
+
+func (p *planner) machineCraft(rec *data.Recipe, amount uint) error {
+
+	machine := p.machineFor(rec)
+	if machine == nil {
+		return fmt.Errorf(`[planner] no machine can craft %q`, rec.Name)
+	}
+
+	var (
+		bonus   = 1 + machine.ProductivityBonus(rec.Name)
+		nCrafts = uint(math.Ceil(float64(amount) / (float64(rec.ProductCount(rec.Name)) * bonus)))
+	)
+
+	for _, ing := range rec.Ingredients {
+		// fluids are piped in, not carried
+		if ing.IsFluid {
+			continue
+		}
+		if err := p.need(ing.Name, nCrafts*uint(ing.Amount)); err != nil {
+			return err
+		}
+	}
+
+	var fuel uint
+	if machine.EnergySource().FuelCategory == constants.FuelCategoryChemical {
+		fuel = machineFuel(machine, rec, nCrafts, p.fuel)
+		if err := p.need(p.fuel, fuel); err != nil {
+			return err
+		}
+	}
+
+	for _, prod := range rec.GetResults() {
+		if !prod.IsFluid {
+			p.inv[prod.Name] += uint(float64(nCrafts*uint(prod.Amount)) * bonus)
+		}
+	}
+
+	if p.dry {
+		return nil
+	}
+
+	tasks := tas.MachineCraft(rec.Name, machine, nCrafts, p.fuel)
+	for _, ing := range rec.Ingredients {
+		if !ing.IsFluid {
+			tasks[0].Prerequisites().Add(playerHasItem(ing.Name, nCrafts*uint(ing.Amount)))
+		}
+	}
+	if fuel > 0 {
+		tasks[0].Prerequisites().Add(playerHasItem(p.fuel, fuel))
+	}
+	p.tasks.Add(tasks...)
+
+	return nil
+}
+
+// machineFor returns the first placed machine that can craft the recipe
+func (p *planner) machineFor(rec *data.Recipe) building.CraftingBuilding {
+	for _, id := range p.s.IDs() {
+		if a, ok := p.s.Buildings[id].(*building.Assembler); ok && a.Entity.CanCraft(rec) {
+			return a
+		}
+	}
+	return nil
+}
+
+// machineFuel returns how much fuel tas.MachineCraft will put into the machine to craft
+// the recipe `nCrafts` times. It tops up fuel one batch at a time, so this mirrors that
+func machineFuel(machine building.CraftingBuilding, rec *data.Recipe, nCrafts uint, fuel string) uint {
+	var (
+		total     uint
+		batchSize = calc.OneStackRecipe(rec)
+		energy    = machine.Energy() / float64(data.GetItem(fuel).FuelValue)
+	)
+	for nCrafts > 0 {
+		amt := shims.Min(nCrafts, batchSize)
+		used := calc.FuelFromRecipes(machine, rec, int(amt), fuel)
+		if need := used - energy; need > 0 {
+			n := uint(math.Ceil(need))
+			total += n
+			energy += float64(n)
+		}
+		energy -= used
+		nCrafts -= amt
+	}
+	return total
+}
+
+// research queues the tech and loads its science packs into the labs, one stack at a time,
+// waiting for each lab to empty before it's given more
+func (p *planner) research(tech string) error {
+
+	labs := p.s.Labs()
+	if len(labs) == 0 {
+		return fmt.Errorf(`[planner] no lab to research %q`, tech)
+	}
+
+	p.tasks.Add(tas.Tech(tech))
+
+	if boiler := p.s.Boiler(); boiler != nil {
+		boilerCoal := uint(math.Ceil(calc.ResearchFuelCost(p.s, p.fuel, tech)))
+		p.tasks.Add(tas.FuelMachine(p.fuel, boiler.Name(), boiler.Index(), boilerCoal)...)
+	}
+
+	packs := calc.TechCost(tech)
+	names := make([]string, 0, len(packs))
+	for pack := range packs {
+		names = append(names, pack)
+	}
+	sort.Strings(names)
+
+	next := 0
+	for _, pack := range names {
+		var (
+			left = uint(packs[pack])
+			// science packs are tools, not items
+			stackSize = uint(data.GetTool(pack).StackSize)
+		)
+		for left > 0 {
+			lab := labs[next%len(labs)]
+			next++
+
+			amt := shims.Min(left, stackSize)
+
+			t := tas.Transfer(lab.Name(), lab.Index(), pack, constants.InventoryLabInput, amt, false)
+			t.Prerequisites().Add(
+				playerHasItem(pack, amt),
+				tas.PrereqWait(lab.Name(), lab.Index(), pack, lab.Slots().Input, 0, true),
+			)
+			p.tasks.Add(t)
+
+			left -= amt
+		}
+	}
+
+	return nil
+}
+
+func playerHasItem(item string, amount uint) tas.Task {
+	return tas.PrereqWait("player", 0, item, constants.InventoryCharacterMain, amount, false)
+}
@@ -0,0 +1,73 @@
+package planner
+
+import (
+	"log"
+	"os"
+	"testing"
+
+	"github.com/brettschalin/factorio-min-resources/data"
+	"github.com/brettschalin/factorio-min-resources/state"
+	"github.com/brettschalin/factorio-min-resources/tas"
+)
+
+func TestMain(m *testing.M) {
+
+	err := data.Init(
+		"../data/data-raw-dump.json",
+	)
+
+	if err != nil {
+		log.Fatalf("could not load data: %v", err)
+	}
+
+	os.Exit(m.Run())
+}
+
+func TestPlan(t *testing.T) {
+
+	var (
+		s   = state.New()
+		run tas.TAS
+	)
+
+	s.ConstructBuilding("stone-furnace", 0)
+	if err := run.Add(tas.Build("stone-furnace", 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	// craft a lab, place it, then research automation with it
+	tasks, err := Plan(Goal{Items: map[string]uint{"lab": 1}}, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = run.Add(tasks...); err != nil {
+		t.Fatal(err)
+	}
+	if s.Inventory["lab"] != 1 {
+		t.Fatalf("expected 1 lab in the inventory, got %d", s.Inventory["lab"])
+	}
+
+	s.ConstructBuilding("lab", 0)
+	s.Inventory["lab"]--
+	if err = run.Add(tas.Build("lab", 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	tasks, err = Plan(Goal{Techs: []string{"automation"}}, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = run.Add(tasks...); err != nil {
+		t.Fatal(err)
+	}
+	if !s.TechResearched["automation"] {
+		t.Fatal("expected automation to be researched")
+	}
+	if s.Inventory["automation-science-pack"] != 0 {
+		t.Fatalf("expected science packs to be used up, got %d", s.Inventory["automation-science-pack"])
+	}
+
+	if _, err = Plan(Goal{Techs: []string{"not-a-tech"}}, s); err == nil {
+		t.Fatal("expected an error for an unknown technology")
+	}
+}
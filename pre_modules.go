@@ -3,7 +3,6 @@ package main
 import (
 	"math"
 
-	"github.com/brettschalin/factorio-min-resources/building"
 	"github.com/brettschalin/factorio-min-resources/calc"
 	"github.com/brettschalin/factorio-min-resources/constants"
 	"github.com/brettschalin/factorio-min-resources/data"
@@ -18,15 +17,19 @@ Segments are split into multiple functions to make it easier to work with them
 */
 
 func playerHasItem(item string, amount uint) tas.Task {
-	return tas.PrereqWait("player", item, constants.InventoryCharacterMain, amount, false)
+	return tas.PrereqWait("player", 0, item, constants.InventoryCharacterMain, amount, false)
 }
 
-// mines, smelts, crafts, and builds the initial power setup, and returns the amount of "leftover" fuel remaining
-func makePowerSetup(state *state.State) (tas.Tasks, float64) {
+// furnaceFuel returns how much fuel, in items, is left in the furnace from earlier segments. Smelting uses
+// that up before more is mined
+func furnaceFuel(state *state.State) float64 {
+	return state.Furnace().Energy() / float64(data.GetItem(constants.PreferredFuel).FuelValue)
+}
 
-	tasks := tas.Tasks{
-		tas.Build("stone-furnace", 0),
-	}
+// mines, smelts, crafts, and builds the initial power setup. The stone furnace has to be placed already
+func makePowerSetup(state *state.State) tas.Tasks {
+
+	tasks := tas.Tasks{}
 
 	// craft these whenever we get the needed materials
 	craftTasks := tas.Tasks{
@@ -37,7 +40,7 @@ func makePowerSetup(state *state.State) (tas.Tasks, float64) {
 		tas.Craft("small-electric-pole", 1),
 	}
 
-	t, extraFuel := tas.MineFuelAndSmelt("iron-ore", constants.PreferredFuel, state.Furnace, 68, 0)
+	t, extraFuel := tas.MineFuelAndSmelt("iron-ore", constants.PreferredFuel, state.Furnace(), 68, furnaceFuel(state))
 	tasks.Add(t...)
 
 	s := tas.MineResource("stone", 5)
@@ -49,7 +52,7 @@ func makePowerSetup(state *state.State) (tas.Tasks, float64) {
 	craftTasks[3].Prerequisites().Add(playerHasItem("iron-plate", 36), playerHasItem("copper-plate", 15))
 	craftTasks[4].Prerequisites().Add(playerHasItem("copper-plate", 1)) // we start with one wood piece
 
-	t, extraFuel = tas.MineFuelAndSmelt("copper-ore", constants.PreferredFuel, state.Furnace, 19, extraFuel)
+	t, _ = tas.MineFuelAndSmelt("copper-ore", constants.PreferredFuel, state.Furnace(), 19, extraFuel)
 	tasks.Add(t...)
 
 	tasks.Add(craftTasks...)
@@ -69,15 +72,16 @@ func makePowerSetup(state *state.State) (tas.Tasks, float64) {
 
 	tasks.Add(buildTasks...)
 
-	return tasks, extraFuel
+	return tasks
 }
 
 // researchRGTech mines and crafts the science packs required to research the
 // given technology. Only works with red and green science technologies that take
 // <= 200 science packs
-func researchRGTech(tech string, state *state.State, extraFuel float64) (tas.Tasks, float64) {
+func researchRGTech(tech string, state *state.State) tas.Tasks {
 
-	tasks := tas.Tasks{}
+	tasks := tas.Tasks{queueTech(tech)}
+	lab, boiler := state.Lab(), state.Boiler()
 
 	// calculate how much mining we'll need to do
 	packs := calc.TechCost(tech)
@@ -89,11 +93,10 @@ func researchRGTech(tech string, state *state.State, extraFuel float64) (tas.Tas
 		}
 	}
 
-	var st tas.Tasks
-	st, extraFuel = tas.MineFuelAndSmelt("iron-ore", constants.PreferredFuel, state.Furnace, uint(baseCost["iron-ore"]), extraFuel)
+	st, extraFuel := tas.MineFuelAndSmelt("iron-ore", constants.PreferredFuel, state.Furnace(), uint(baseCost["iron-ore"]), furnaceFuel(state))
 	tasks.Add(st...)
 
-	st, extraFuel = tas.MineFuelAndSmelt("copper-ore", constants.PreferredFuel, state.Furnace, uint(baseCost["copper-ore"]), extraFuel)
+	st, _ = tas.MineFuelAndSmelt("copper-ore", constants.PreferredFuel, state.Furnace(), uint(baseCost["copper-ore"]), extraFuel)
 	tasks.Add(st...)
 
 	// craft the science packs. This at least starts crafting when the iron is available
@@ -105,46 +108,46 @@ func researchRGTech(tech string, state *state.State, extraFuel float64) (tas.Tas
 	tasks.Add(t)
 
 	lTasks := tas.Tasks{
-		tas.Transfer(state.Lab.Name(), "automation-science-pack", constants.InventoryLabInput, uint(uint(packs["automation-science-pack"])), false),
+		tas.Transfer(lab.Name(), lab.Index(), "automation-science-pack", constants.InventoryLabInput, uint(uint(packs["automation-science-pack"])), false),
 	}
-	lTasks[0].Prerequisites().Add(tasks[len(tasks)-1], tas.PrereqWait(state.Lab.Name(), "automation-science-pack", state.Lab.Slots().Input, 0, true))
-
-	if state.Boiler != nil {
-		// we need to fuel the boiler
-		boilerCoal := uint(math.Ceil(calc.BoilerFuelCost(state.Boiler, constants.PreferredFuel, calc.TechEnergyCost(state.Lab, tech))))
+	lTasks[0].Prerequisites().Add(tasks[len(tasks)-1], tas.PrereqWait(lab.Name(), lab.Index(), "automation-science-pack", lab.Slots().Input, 0, true))
 
-		tasks.Add(tas.FuelMachine(constants.PreferredFuel, state.Boiler.Name(), boilerCoal)...)
+	// we need to fuel the boiler, unless the solar panel's taken over
+	if boilerCoal := uint(math.Ceil(calc.ResearchFuelCost(state, constants.PreferredFuel, tech))); boilerCoal > 0 {
+		tasks.Add(tas.FuelMachine(constants.PreferredFuel, boiler.Name(), boiler.Index(), boilerCoal)...)
 	}
 
 	if packs["logistic-science-pack"] > 0 {
-		tasks.Add(tas.Craft("logistic-science-pack", uint(packs["logistic-science-pack"])))
-		t := tas.Transfer(state.Lab.Name(), "logistic-science-pack", constants.InventoryLabInput, uint(uint(packs["logistic-science-pack"])), false)
-		t.Prerequisites().Add(tasks[len(tasks)-1], tas.PrereqWait(state.Lab.Name(), "logistic-science-pack", state.Lab.Slots().Input, 0, true))
+		c := tas.Craft("logistic-science-pack", uint(packs["logistic-science-pack"]))
+		c.Prerequisites().Add(techMap["logistic-science-pack"])
+		tasks.Add(c)
+		t := tas.Transfer(lab.Name(), lab.Index(), "logistic-science-pack", constants.InventoryLabInput, uint(uint(packs["logistic-science-pack"])), false)
+		t.Prerequisites().Add(tasks[len(tasks)-1], tas.PrereqWait(lab.Name(), lab.Index(), "logistic-science-pack", lab.Slots().Input, 0, true))
 
 		lTasks.Add(t)
 	}
 
 	tasks.Add(lTasks...)
 
-	return tasks, extraFuel
+	return tasks
 }
 
 // outputs the tasks needed to research solar-energy and build a solar-panel.
 // Assumes logistic-science-packs and steel smelting have been unlocked. This is separate from
 // the rest of the red/green tech tasks because `solar-energy` requires 250 science packs and researchRGTech can't handle batching transfers
-func buildSolarPanel(state *state.State, extraFuel float64) (tas.Tasks, float64) {
+func buildSolarPanel(state *state.State) tas.Tasks {
 
-	tasks := tas.Tasks{}
+	tasks := tas.Tasks{queueTech("solar-energy")}
+	lab, boiler := state.Lab(), state.Boiler()
 
 	// this hopefully fixes a rounding error. Yay for floating point math...
-	extraFuel = shims.Max(0, extraFuel-0.0001)
+	extraFuel := shims.Max(0, furnaceFuel(state)-0.0001)
 
 	// smelting
-	var st tas.Tasks
-	st, extraFuel = tas.MineFuelAndSmelt("iron-ore", constants.PreferredFuel, state.Furnace, 1875, extraFuel)
+	st, extraFuel := tas.MineFuelAndSmelt("iron-ore", constants.PreferredFuel, state.Furnace(), 1875, extraFuel)
 	tasks.Add(st...)
 
-	st, extraFuel = tas.MineFuelAndSmelt("copper-ore", constants.PreferredFuel, state.Furnace, 625, extraFuel)
+	st, extraFuel = tas.MineFuelAndSmelt("copper-ore", constants.PreferredFuel, state.Furnace(), 625, extraFuel)
 	tasks.Add(st...)
 
 	c := tas.Craft("iron-gear-wheel", 625)
@@ -164,47 +167,55 @@ func buildSolarPanel(state *state.State, extraFuel float64) (tas.Tasks, float64)
 	c = tas.Craft("logistic-science-pack", 50)
 	c.Prerequisites().Add(playerHasItem("transport-belt", 250))
 	c.Prerequisites().Add(playerHasItem("copper-plate", 375))
+	c.Prerequisites().Add(techMap["logistic-science-pack"])
 	tasks.Add(c)
 	tasks.Add(tas.Craft("logistic-science-pack", 200))
 
-	// do the research. This requires a careful balancing of boiler fuel and science packs
+	// do the research. This requires a careful balancing of boiler fuel and science packs.
+	// Research requires 112.5 coal worth of energy, but all of the previous research was done with researchRGTech which rounds
+	// up any fractional requirements, so what's left in the boiler from that means we can mine less here
+	fuel := data.GetItem(constants.PreferredFuel)
+	inBoiler := uint(boiler.Inventory(constants.InventoryFuel).Count(constants.PreferredFuel))
+	coal := uint(math.Ceil(shims.Max(0, calc.ResearchFuelCost(state, constants.PreferredFuel, "solar-energy")-boiler.Energy()/float64(fuel.FuelValue))))
+
+	// the boiler only holds one stack, so it's topped up first and the rest goes in as it burns through that
+	first := shims.Min(coal, uint(fuel.StackSize)-inBoiler)
 	tasks.Add(
-		// Research requires 112.5 coal worth of energy, but all of the previous research was done with researchRGTech which rounds
-		// up any fractional requirements, so that means we can mine less here
-		tas.MineResource(constants.PreferredFuel, 111),
-		tas.WaitInventory(state.Boiler.Name(), constants.PreferredFuel, constants.InventoryFuel, 0, true),
-		tas.Transfer(state.Boiler.Name(), constants.PreferredFuel, constants.InventoryFuel, 50, false),
-
-		tas.WaitInventory("player", "automation-science-pack", constants.InventoryCharacterMain, 50, false),
-		tas.Transfer(state.Lab.Name(), "automation-science-pack", constants.InventoryLabInput, 50, false),
-		tas.WaitInventory("player", "logistic-science-pack", constants.InventoryCharacterMain, 50, false),
-		tas.Transfer(state.Lab.Name(), "logistic-science-pack", constants.InventoryLabInput, 50, false),
-
-		tas.WaitInventory("player", "automation-science-pack", constants.InventoryCharacterMain, 200, false),
-		tas.WaitInventory("lab", "automation-science-pack", constants.InventoryLabInput, 0, true),
-		tas.Transfer(state.Lab.Name(), "automation-science-pack", constants.InventoryLabInput, 200, false),
-		tas.WaitInventory("player", "logistic-science-pack", constants.InventoryCharacterMain, 200, false),
-		tas.WaitInventory("lab", "logistic-science-pack", constants.InventoryLabInput, 0, true),
-		tas.Transfer(state.Lab.Name(), "logistic-science-pack", constants.InventoryLabInput, 200, false),
-
-		tas.WaitInventory(state.Boiler.Name(), constants.PreferredFuel, constants.InventoryFuel, 0, true),
-		tas.Transfer(state.Boiler.Name(), constants.PreferredFuel, constants.InventoryFuel, 50, false),
-
-		tas.WaitInventory(state.Boiler.Name(), constants.PreferredFuel, constants.InventoryFuel, 0, true),
-		tas.Transfer(state.Boiler.Name(), constants.PreferredFuel, constants.InventoryFuel, 11, false),
+		tas.MineResource(constants.PreferredFuel, coal),
+		tas.Transfer(boiler.Name(), boiler.Index(), constants.PreferredFuel, constants.InventoryFuel, first, false),
+
+		tas.WaitInventory("player", 0, "automation-science-pack", constants.InventoryCharacterMain, 50, false),
+		tas.Transfer(lab.Name(), lab.Index(), "automation-science-pack", constants.InventoryLabInput, 50, false),
+		tas.WaitInventory("player", 0, "logistic-science-pack", constants.InventoryCharacterMain, 50, false),
+		tas.Transfer(lab.Name(), lab.Index(), "logistic-science-pack", constants.InventoryLabInput, 50, false),
+
+		tas.WaitInventory("player", 0, "automation-science-pack", constants.InventoryCharacterMain, 200, false),
+		tas.WaitInventory("lab", 0, "automation-science-pack", constants.InventoryLabInput, 0, true),
+		tas.Transfer(lab.Name(), lab.Index(), "automation-science-pack", constants.InventoryLabInput, 200, false),
+		tas.WaitInventory("player", 0, "logistic-science-pack", constants.InventoryCharacterMain, 200, false),
+		tas.WaitInventory("lab", 0, "logistic-science-pack", constants.InventoryLabInput, 0, true),
+		tas.Transfer(lab.Name(), lab.Index(), "logistic-science-pack", constants.InventoryLabInput, 200, false),
 	)
+	for left := coal - first; left > 0; {
+		n := shims.Min(left, uint(fuel.StackSize))
+		tasks.Add(
+			tas.WaitInventory(boiler.Name(), boiler.Index(), constants.PreferredFuel, constants.InventoryFuel, 0, true),
+			tas.Transfer(boiler.Name(), boiler.Index(), constants.PreferredFuel, constants.InventoryFuel, n, false),
+		)
+		left -= n
+	}
 
-	t, extraFuel := tas.MineFuelAndSmelt("iron-ore", constants.PreferredFuel, state.Furnace, 40, extraFuel)
+	t, extraFuel := tas.MineFuelAndSmelt("iron-ore", constants.PreferredFuel, state.Furnace(), 40, extraFuel)
 	tasks.Add(t...)
 
-	t, extraFuel = tas.MineFuelAndSmelt("copper-ore", constants.PreferredFuel, state.Furnace, 28, extraFuel)
+	t, extraFuel = tas.MineFuelAndSmelt("copper-ore", constants.PreferredFuel, state.Furnace(), 28, extraFuel)
 	tasks.Add(t...)
 
 	c = tas.Craft("electronic-circuit", 15)
 	c.Prerequisites().Add(tasks[len(tasks)-1])
 	tasks.Add(c)
 
-	t, extraFuel = tas.MineFuelAndSmelt("iron-plate", constants.PreferredFuel, state.Furnace, 25, extraFuel)
+	t, _ = tas.MineFuelAndSmelt("iron-plate", constants.PreferredFuel, state.Furnace(), 25, extraFuel)
 
 	tasks.Add(t...)
 
@@ -218,23 +229,22 @@ func buildSolarPanel(state *state.State, extraFuel float64) (tas.Tasks, float64)
 	tasks[len(tasks)-2].Prerequisites().Add(techMap["solar-energy"])
 	tasks[len(tasks)-1].Prerequisites().Add(tasks[len(tasks)-3])
 
-	state.Boiler = nil
-
-	return tasks, extraFuel
+	return tasks
 }
 
 // outputs the tasks needed to research advanced-material-processing and build a steel-furnace.
 // Assumes logistic-science-packs and steel smelting have been unlocked
-func buildSteelFurnace(state *state.State, extraFuel float64) (tas.Tasks, float64) {
+func buildSteelFurnace(state *state.State) tas.Tasks {
 
-	tasks := tas.Tasks{}
+	tasks := tas.Tasks{queueTech("advanced-material-processing")}
 
 	var c tas.Task
 
-	tasks, extraFuel = tas.MineFuelAndSmelt("iron-ore", constants.PreferredFuel, state.Furnace, 564, extraFuel)
+	st, extraFuel := tas.MineFuelAndSmelt("iron-ore", constants.PreferredFuel, state.Furnace(), 564, furnaceFuel(state))
+	tasks.Add(st...)
 
 	// we need 188 but the extra copper-cable left over from the solar panel is enough
-	st, extraFuel := tas.MineFuelAndSmelt("copper-ore", constants.PreferredFuel, state.Furnace, 187, extraFuel)
+	st, extraFuel = tas.MineFuelAndSmelt("copper-ore", constants.PreferredFuel, state.Furnace(), 187, extraFuel)
 	tasks.Add(st...)
 
 	c = tas.Craft("transport-belt", 38)
@@ -242,8 +252,8 @@ func buildSteelFurnace(state *state.State, extraFuel float64) (tas.Tasks, float6
 	tasks.Add(c)
 
 	t := tas.Tasks{
-		tas.Transfer("lab", "automation-science-pack", constants.InventoryLabInput, 75, false),
-		tas.Transfer("lab", "logistic-science-pack", constants.InventoryLabInput, 75, false),
+		tas.Transfer("lab", 0, "automation-science-pack", constants.InventoryLabInput, 75, false),
+		tas.Transfer("lab", 0, "logistic-science-pack", constants.InventoryLabInput, 75, false),
 	}
 	c = tas.Craft("automation-science-pack", 75)
 	c.Prerequisites().Add(playerHasItem("iron-plate", 150))
@@ -264,21 +274,22 @@ func buildSteelFurnace(state *state.State, extraFuel float64) (tas.Tasks, float6
 	c.Prerequisites().Add(techMap["logistic-science-pack"])
 	tasks.Add(c)
 
-	if state.Boiler != nil {
+	// the boiler only makes power while there's a steam engine to use it
+	if state.IsPlaced("steam-engine", 0) {
 		tasks.Add(
-			tas.FuelMachine(constants.PreferredFuel, "boiler", 34)...,
+			tas.FuelMachine(constants.PreferredFuel, "boiler", 0, 34)...,
 		)
 	}
 
 	tasks.Add(t...)
 
-	st, extraFuel = tas.MineFuelAndSmelt("iron-ore", constants.PreferredFuel, state.Furnace, 30, extraFuel)
+	st, extraFuel = tas.MineFuelAndSmelt("iron-ore", constants.PreferredFuel, state.Furnace(), 30, extraFuel)
 	tasks.Add(st...)
 
-	st, extraFuel = tas.MineFuelAndSmelt("stone", constants.PreferredFuel, state.Furnace, 20, extraFuel)
+	st, extraFuel = tas.MineFuelAndSmelt("stone", constants.PreferredFuel, state.Furnace(), 20, extraFuel)
 	tasks.Add(st...)
 
-	st, _ = tas.MineFuelAndSmelt("iron-plate", constants.PreferredFuel, state.Furnace, 30, extraFuel)
+	st, _ = tas.MineFuelAndSmelt("iron-plate", constants.PreferredFuel, state.Furnace(), 30, extraFuel)
 	tasks.Add(st...)
 
 	c = tas.Craft("steel-furnace", 1)
@@ -299,21 +310,18 @@ func buildSteelFurnace(state *state.State, extraFuel float64) (tas.Tasks, float6
 	c.Prerequisites().Add(playerHasItem("steel-furnace", 1))
 	tasks.Add(c)
 
-	state.Furnace = building.NewFurnace(data.GetFurnace("steel-furnace"))
-
-	// furnace is replaced and the extra fuel is reset
-	return tasks, 0
+	return tasks
 }
 
-func buildOilSetup(state *state.State, extraFuel float64) (tas.Tasks, float64) {
+func buildOilSetup(state *state.State) tas.Tasks {
 	tasks := tas.Tasks{}
 
 	// how many pipes to build in the map. See locations.lua for where they go
 	const nPipes = 42
 
-	t, extraFuel := tas.MineFuelAndSmelt("iron-ore", constants.PreferredFuel, state.Furnace, 220+nPipes, extraFuel)
+	t, extraFuel := tas.MineFuelAndSmelt("iron-ore", constants.PreferredFuel, state.Furnace(), 220+nPipes, furnaceFuel(state))
 	tasks.Add(t...)
-	t, extraFuel = tas.MineFuelAndSmelt("copper-ore", constants.PreferredFuel, state.Furnace, 30, extraFuel)
+	t, extraFuel = tas.MineFuelAndSmelt("copper-ore", constants.PreferredFuel, state.Furnace(), 30, extraFuel)
 	tasks.Add(t...)
 
 	task := tas.Craft("electronic-circuit", 20)
@@ -324,10 +332,10 @@ func buildOilSetup(state *state.State, extraFuel float64) (tas.Tasks, float64) {
 		tas.Craft("pipe", 25+nPipes),
 	)
 
-	t, extraFuel = tas.MineFuelAndSmelt("stone", constants.PreferredFuel, state.Furnace, 20, extraFuel)
+	t, extraFuel = tas.MineFuelAndSmelt("stone", constants.PreferredFuel, state.Furnace(), 20, extraFuel)
 	tasks.Add(t...)
 
-	t, extraFuel = tas.MineFuelAndSmelt("iron-plate", constants.PreferredFuel, state.Furnace, 125, extraFuel)
+	t, _ = tas.MineFuelAndSmelt("iron-plate", constants.PreferredFuel, state.Furnace(), 125, extraFuel)
 	tasks.Add(t...)
 
 	tasks.Add(
@@ -348,27 +356,9 @@ func buildOilSetup(state *state.State, extraFuel float64) (tas.Tasks, float64) {
 		bTasks.Add(tas.Build("pipe", i))
 	}
 
-	bTasks.Add(tas.Recipe("oil-refinery", "basic-oil-processing"))
+	bTasks.Add(tas.Recipe("oil-refinery", 0, "basic-oil-processing"))
 
 	tasks.Add(bTasks...)
 
-	return tasks, extraFuel
-}
-
-func researchModules(state *state.State, extraFuel float64) (tas.Tasks, float64) {
-	tasks := tas.Tasks{}
-
-	t, extraFuel := researchRGTech("plastics", state, extraFuel)
-	tasks.Add(t...)
-
-	t, extraFuel = researchRGTech("advanced-electronics", state, extraFuel)
-	tasks.Add(t...)
-
-	t, extraFuel = researchRGTech("modules", state, extraFuel)
-	tasks.Add(t...)
-
-	t, extraFuel = researchRGTech("productivity-module", state, extraFuel)
-	tasks.Add(t...)
-
-	return tasks, extraFuel
+	return tasks
 }
@@ -12,18 +12,18 @@ import (
 )
 
 // prodmod1 returns the tasks required to craft productivity-modules and place them in the relevant machines
-func prodmod1(state *state.State, extraFuel float64) (tas.Tasks, float64) {
-	tasks, extraFuel := tas.MineFuelAndSmelt("iron-ore", constants.PreferredFuel, state.Furnace, 150, extraFuel)
+func prodmod1(state *state.State) tas.Tasks {
+	tasks, extraFuel := tas.MineFuelAndSmelt("iron-ore", constants.PreferredFuel, state.Furnace(), 150, furnaceFuel(state))
 
-	t, extraFuel := tas.MineFuelAndSmelt("copper-ore", constants.PreferredFuel, state.Furnace, 237, extraFuel)
+	t, extraFuel := tas.MineFuelAndSmelt("copper-ore", constants.PreferredFuel, state.Furnace(), 237, extraFuel)
 	tasks.Add(t...)
 
-	t, extraFuel = tas.MineFuelAndSmelt("iron-plate", constants.PreferredFuel, state.Furnace, 10, extraFuel)
+	t, _ = tas.MineFuelAndSmelt("iron-plate", constants.PreferredFuel, state.Furnace(), 10, extraFuel)
 	tasks.Add(t...)
 
 	tasks.Add(tas.MineResource("coal", 35))
 
-	t = tas.MachineCraft("plastic-bar", state.Chem, 35, constants.PreferredFuel)
+	t = tas.MachineCraft("plastic-bar", state.Chem(), 35, constants.PreferredFuel)
 	tasks.Add(t...)
 
 	craftTasks := tas.Tasks{
@@ -36,23 +36,19 @@ func prodmod1(state *state.State, extraFuel float64) (tas.Tasks, float64) {
 
 	t = tas.Tasks{
 		tas.Build("assembling-machine-2", 0),
-		tas.Transfer(state.Assembler.Name(), "productivity-module", state.Assembler.Slots().Modules, 2, false),
-		tas.Transfer(state.Lab.Name(), "productivity-module", state.Lab.Slots().Modules, 2, false),
-		tas.Transfer(state.Chem.Name(), "productivity-module", state.Chem.Slots().Modules, 3, false),
+		// the assembler isn't placed yet, so it's not in `state`
+		tas.Transfer("assembling-machine-2", 0, "productivity-module", constants.InventoryAssemblingMachineModules, 2, false),
+		tas.Transfer(state.Lab().Name(), state.Lab().Index(), "productivity-module", state.Lab().Slots().Modules, 2, false),
+		tas.Transfer(state.Chem().Name(), state.Chem().Index(), "productivity-module", state.Chem().Slots().Modules, 3, false),
 	}
 	t[0].Prerequisites().Add(tasks[len(tasks)-1])
 	tasks.Add(t...)
 
-	mod := "productivity-module"
-	state.Assembler.PutModules([]string{mod, mod})
-	state.Lab.PutModules([]string{mod, mod})
-	state.Chem.PutModules([]string{mod, mod, mod})
-
-	return tasks, extraFuel
+	return tasks
 }
 
 // buildElectricFurnace returns the tasks required to research and build the electric-furnace
-func buildElectricFurnace(state *state.State, extraFuel float64) tas.Tasks {
+func buildElectricFurnace(state *state.State) tas.Tasks {
 	tasks := tas.Tasks{}
 
 	toCraft := map[*data.Recipe]int{}
@@ -65,7 +61,7 @@ func buildElectricFurnace(state *state.State, extraFuel float64) tas.Tasks {
 	// apply lab bonuses to the research and convert it to the number of recipes to craft instead of packs needed
 	for r, amount := range toCraft {
 		p := r.ProductCount(r.Name)
-		b := 1 + state.Lab.ProductivityBonus("")
+		b := 1 + state.Lab().ProductivityBonus("")
 
 		amt := int(math.Ceil(float64(amount) / (float64(p) * b)))
 
@@ -84,23 +80,23 @@ func buildElectricFurnace(state *state.State, extraFuel float64) tas.Tasks {
 		fmt.Printf("\t%s: %d\n", ing.Name, ing.Amount)
 	}
 
-	t, extraFuel := tas.MineFuelAndSmelt("iron-ore", constants.PreferredFuel, state.Furnace, uint(ings.Amount("iron-ore")), extraFuel)
+	t, extraFuel := tas.MineFuelAndSmelt("iron-ore", constants.PreferredFuel, state.Furnace(), uint(ings.Amount("iron-ore")), furnaceFuel(state))
 	tasks.Add(t...)
 
-	t, extraFuel = tas.MineFuelAndSmelt("copper-ore", constants.PreferredFuel, state.Furnace, uint(ings.Amount("copper-ore")), extraFuel)
+	t, extraFuel = tas.MineFuelAndSmelt("copper-ore", constants.PreferredFuel, state.Furnace(), uint(ings.Amount("copper-ore")), extraFuel)
 	tasks.Add(t...)
 
-	t, extraFuel = tas.MineFuelAndSmelt("stone", constants.PreferredFuel, state.Furnace, uint(ings.Amount("stone")), extraFuel)
+	t, extraFuel = tas.MineFuelAndSmelt("stone", constants.PreferredFuel, state.Furnace(), uint(ings.Amount("stone")), extraFuel)
 	tasks.Add(t...)
 
-	t, _ = tas.MineFuelAndSmelt("iron-plate", constants.PreferredFuel, state.Furnace, uint(ings.Amount("steel-plate")*5), extraFuel)
+	t, _ = tas.MineFuelAndSmelt("iron-plate", constants.PreferredFuel, state.Furnace(), uint(ings.Amount("steel-plate")*5), extraFuel)
 	tasks.Add(t...)
 
 	tasks.Add(tas.MineResource("coal", uint(ings.Amount("coal"))))
 
 	// TODO: the rest of the crafts
 
-	// state.Furnace = building.NewFurnace(data.GetFurnace("electric-furnace"))
+	// then tas.Build("electric-furnace", 0). Once it's placed state.Furnace() returns it ahead of the steel furnace
 
 	return tasks
 }
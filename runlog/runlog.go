@@ -0,0 +1,119 @@
+// package runlog reads what the mod reports at the end of a run, so it can be compared against what the
+// Go code predicted
+package runlog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// the mod prints its results after this, both to the console and the log
+const marker = "Resources used:"
+
+// Result is what the mod reports when the TAS is done. It's written to script-output/MinPctTAS/resources.json
+type Result struct {
+	Ticks int `json:"ticks"`
+
+	// counted from on_player_mined_entity events
+	ResourcesUsed map[string]uint `json:"resources_used"`
+
+	// from the force's item production statistics. Older versions of the mod don't report these
+	Production map[string]uint `json:"production"`
+}
+
+// Mined returns how much of each resource was mined, preferring the production statistics
+func (r *Result) Mined() map[string]uint {
+	if len(r.Production) > 0 {
+		return r.Production
+	}
+	return r.ResourcesUsed
+}
+
+// Parse reads the mod's results. `r` can be the JSON file from script-output or a log with the line the mod
+// prints at the end; if there's more than one run in the log the last one is used
+func Parse(r io.Reader) (*Result, error) {
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if trimmed := bytes.TrimSpace(b); len(trimmed) > 0 && trimmed[0] == '{' {
+		return parseJSON(trimmed)
+	}
+
+	var last string
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		if i := strings.Index(sc.Text(), marker); i >= 0 {
+			last = sc.Text()[i+len(marker):]
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if last == "" {
+		return nil, fmt.Errorf("runlog: no %q line found", marker)
+	}
+	return parseJSON([]byte(strings.TrimSpace(last)))
+}
+
+func parseJSON(b []byte) (*Result, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, fmt.Errorf("runlog: %w", err)
+	}
+
+	res := &Result{}
+	_, hasUsed := fields["resources_used"]
+	_, hasProduction := fields["production"]
+
+	// older versions of the mod only print the resources_used table
+	if !hasUsed && !hasProduction {
+		if err := json.Unmarshal(b, &res.ResourcesUsed); err != nil {
+			return nil, fmt.Errorf("runlog: %w", err)
+		}
+		return res, nil
+	}
+
+	if err := json.Unmarshal(b, res); err != nil {
+		return nil, fmt.Errorf("runlog: %w", err)
+	}
+	return res, nil
+}
+
+// ResourceDiff compares the predicted and actual amount of one resource
+type ResourceDiff struct {
+	Resource string
+	Expected uint
+	Actual   uint
+}
+
+// Delta returns how many more were mined than expected. Negative if fewer were
+func (d ResourceDiff) Delta() int {
+	return int(d.Actual) - int(d.Expected)
+}
+
+// Compare returns the difference for every resource in either map, sorted by name
+func Compare(expected, actual map[string]uint) []ResourceDiff {
+	names := map[string]bool{}
+	for r := range expected {
+		names[r] = true
+	}
+	for r := range actual {
+		names[r] = true
+	}
+
+	out := make([]ResourceDiff, 0, len(names))
+	for r := range names {
+		out = append(out, ResourceDiff{Resource: r, Expected: expected[r], Actual: actual[r]})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Resource < out[j].Resource })
+	return out
+}
@@ -0,0 +1,78 @@
+package runlog
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/r3labs/diff/v3"
+)
+
+func TestParse(t *testing.T) {
+
+	for _, test := range []struct {
+		name     string
+		input    string
+		expected *Result
+	}{
+		{
+			name:  "script output",
+			input: `{"ticks":1200,"resources_used":{"coal":5,"iron-ore":20},"production":{"coal":5,"iron-ore":20,"wood":4}}`,
+			expected: &Result{
+				Ticks:         1200,
+				ResourcesUsed: map[string]uint{"coal": 5, "iron-ore": 20},
+				Production:    map[string]uint{"coal": 5, "iron-ore": 20, "wood": 4},
+			},
+		},
+		{
+			name: "log uses the last run",
+			input: strings.Join([]string{
+				`   1.234 Script @__MinPctTAS__/control.lua:360: Resources used:{"ticks":10,"resources_used":{"coal":1}}`,
+				`   2.345 Some other line`,
+				`   3.456 Script @__MinPctTAS__/control.lua:360: Resources used:{"ticks":20,"resources_used":{"coal":2}}`,
+			}, "\n"),
+			expected: &Result{
+				Ticks:         20,
+				ResourcesUsed: map[string]uint{"coal": 2},
+			},
+		},
+		{
+			name:  "old format",
+			input: `Resources used:{"coal":3,"stone":7}`,
+			expected: &Result{
+				ResourcesUsed: map[string]uint{"coal": 3, "stone": 7},
+			},
+		},
+	} {
+		t.Run(test.name, func(tt *testing.T) {
+			res, err := Parse(strings.NewReader(test.input))
+			if err != nil {
+				tt.Fatal(err)
+			}
+			if d, _ := diff.Diff(res, test.expected); len(d) > 0 {
+				tt.Fatalf("unexpected result: %v", d)
+			}
+		})
+	}
+
+	if _, err := Parse(strings.NewReader("nothing to see here")); err == nil {
+		t.Fatal("expected an error without a result line")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	got := Compare(
+		map[string]uint{"coal": 5, "iron-ore": 20},
+		map[string]uint{"coal": 6, "iron-ore": 20, "wood": 4},
+	)
+	expected := []ResourceDiff{
+		{Resource: "coal", Expected: 5, Actual: 6},
+		{Resource: "iron-ore", Expected: 20, Actual: 20},
+		{Resource: "wood", Expected: 0, Actual: 4},
+	}
+	if d, _ := diff.Diff(got, expected); len(d) > 0 {
+		t.Fatalf("unexpected diff: %v", d)
+	}
+	if got[0].Delta() != 1 || got[2].Delta() != 4 {
+		t.Fatalf("wrong deltas: %d %d", got[0].Delta(), got[2].Delta())
+	}
+}
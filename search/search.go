@@ -0,0 +1,306 @@
+// Package search tries different ways of doing a run and finds the ones that mine the least. Candidates are
+// verified and simulated the same as any other TAS, several at a time
+package search
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/brettschalin/factorio-min-resources/constants"
+	"github.com/brettschalin/factorio-min-resources/data"
+	"github.com/brettschalin/factorio-min-resources/planner"
+	"github.com/brettschalin/factorio-min-resources/state"
+	"github.com/brettschalin/factorio-min-resources/tas"
+)
+
+// Candidate is one way of doing a run
+type Candidate struct {
+	Name string
+
+	// Plan makes the candidate's tasks. Candidates are planned at the same time in different goroutines,
+	// so it can't share anything it changes (like a state.State) with other candidates
+	Plan func() (tas.Tasks, error)
+}
+
+// Result is how a candidate did
+type Result struct {
+	Candidate Candidate
+
+	// how much of each resource the run mines, and the total of all of them
+	Mined map[string]uint
+	Total uint
+
+	// how long the run takes, as predicted by TAS.Simulate
+	Ticks int
+
+	// why the candidate couldn't be scored. The other fields aren't set if this is
+	Err error
+}
+
+// Evaluate plans, verifies and simulates the candidate
+func Evaluate(c Candidate) Result {
+
+	res := Result{Candidate: c}
+
+	tasks, err := c.Plan()
+	if err != nil {
+		res.Err = err
+		return res
+	}
+
+	t := &tas.TAS{}
+	if err = t.Add(tasks...); err != nil {
+		res.Err = err
+		return res
+	}
+	tl, err := t.Simulate(nil)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+
+	res.Mined = tl.Mined
+	for _, n := range tl.Mined {
+		res.Total += n
+	}
+	res.Ticks = tl.Ticks
+	return res
+}
+
+// Search evaluates the candidates with `workers` goroutines and returns the best `n` of them: the fewest
+// resources mined first, then the fastest. Candidates that can't be done are left out, and if none of
+// them can the first one's error is returned
+func Search(candidates []Candidate, workers, n int) ([]Result, error) {
+
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf(`[search] no candidates`)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	var (
+		results = make([]Result, len(candidates))
+		next    = make(chan int)
+		wg      sync.WaitGroup
+	)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i] = Evaluate(candidates[i])
+			}
+		}()
+	}
+	for i := range candidates {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	ok := make([]Result, 0, len(results))
+	for _, r := range results {
+		if r.Err == nil {
+			ok = append(ok, r)
+		}
+	}
+	if len(ok) == 0 {
+		return nil, fmt.Errorf(`[search] no candidate worked. %q failed with: %v`, results[0].Candidate.Name, results[0].Err)
+	}
+
+	// stable, so ties go to whichever candidate was listed first
+	sort.SliceStable(ok, func(i, j int) bool {
+		if ok[i].Total != ok[j].Total {
+			return ok[i].Total < ok[j].Total
+		}
+		return ok[i].Ticks < ok[j].Ticks
+	})
+
+	if n > 0 && n < len(ok) {
+		ok = ok[:n]
+	}
+	return ok, nil
+}
+
+// Planned makes a candidate that researches `techs` in order using the planner. Each of the `buildings` is
+// crafted and built as soon as the tech that unlocks it is researched, or at the start if it doesn't need
+// one. The planner needs a furnace to smelt and a lab to research, so those should be first. Labs don't work
+// without power, so they have to come after something to make it (an offshore pump, boiler and steam engine)
+func Planned(buildings, techs []string) Candidate {
+
+	name := fmt.Sprintf("build %s; research %s", strings.Join(buildings, ", "), strings.Join(techs, " > "))
+
+	return Candidate{
+		Name: name,
+		Plan: func() (tas.Tasks, error) {
+
+			var (
+				s       = state.New()
+				tasks   = tas.Tasks{}
+				pending = append([]string{}, buildings...)
+				built   = map[string]int{}
+
+				// the last tech queued. Research only finishes some time after the packs go in, so crafts
+				// that need a tech wait for this, and with it every tech queued before it
+				lastTech tas.Task
+			)
+
+			waitForTech := func(t tas.Tasks) {
+				if lastTech == nil {
+					return
+				}
+				for _, task := range t {
+					// the rest of the crafts are queued behind this one
+					if task.Type() == tas.TaskCraft {
+						task.Prerequisites().Add(lastTech)
+						return
+					}
+				}
+			}
+
+			build := func() error {
+				left := pending[:0]
+				for _, b := range pending {
+					tech := data.UnlockedBy(b)
+					if tech != "" && !s.TechResearched[tech] {
+						left = append(left, b)
+						continue
+					}
+					t, err := planner.Plan(planner.Goal{Items: map[string]uint{b: 1}}, s)
+					if err != nil {
+						return err
+					}
+					if tech != "" {
+						waitForTech(t)
+					}
+					n := built[b]
+					built[b]++
+					// crafting happens alongside everything else, so wait for it to finish
+					place := tas.Build(b, n)
+					place.Prerequisites().Add(tas.PrereqWait("player", 0, b, constants.InventoryCharacterMain, 1))
+					tasks.Add(t...)
+					tasks.Add(place)
+					s.ConstructBuilding(b, n)
+					s.Inventory[b]--
+				}
+				pending = left
+				return nil
+			}
+
+			if err := build(); err != nil {
+				return nil, err
+			}
+			for _, tech := range techs {
+				t, err := planner.Plan(planner.Goal{Techs: []string{tech}}, s)
+				if err != nil {
+					return nil, err
+				}
+				// the packs might need an earlier tech too, like logistic-science-pack
+				waitForTech(t)
+				for _, task := range t {
+					if task.Type() == tas.TaskTech {
+						lastTech = task
+					}
+				}
+				tasks.Add(t...)
+				if err := build(); err != nil {
+					return nil, err
+				}
+			}
+
+			if len(pending) > 0 {
+				return nil, fmt.Errorf(`[search] %q is never unlocked`, pending[0])
+			}
+			return tasks, nil
+		},
+	}
+}
+
+// TechOrders returns up to `limit` orders the techs can be researched in. A tech always comes after any of
+// the others it needs, directly or not. Orders are returned starting from the one they're given in
+func TechOrders(techs []string, limit int) [][]string {
+
+	// needs[i][j] is whether tech i needs tech j researched first
+	needs := make([][]bool, len(techs))
+	for i, t := range techs {
+		needs[i] = make([]bool, len(techs))
+		pre := prerequisites(t)
+		for j, o := range techs {
+			needs[i][j] = pre[o]
+		}
+	}
+
+	var (
+		out   [][]string
+		order = make([]string, 0, len(techs))
+		used  = make([]bool, len(techs))
+		visit func()
+	)
+	visit = func() {
+		if limit > 0 && len(out) >= limit {
+			return
+		}
+		if len(order) == len(techs) {
+			out = append(out, append([]string{}, order...))
+			return
+		}
+		for i := range techs {
+			if used[i] {
+				continue
+			}
+			ready := true
+			for j := range techs {
+				if needs[i][j] && !used[j] {
+					ready = false
+					break
+				}
+			}
+			if !ready {
+				continue
+			}
+			used[i] = true
+			order = append(order, techs[i])
+			visit()
+			order = order[:len(order)-1]
+			used[i] = false
+		}
+	}
+	visit()
+	return out
+}
+
+// prerequisites returns every tech that has to be researched before this one
+func prerequisites(tech string) map[string]bool {
+	out := map[string]bool{}
+	var visit func(name string)
+	visit = func(name string) {
+		t := data.GetTech(name)
+		if t == nil {
+			return
+		}
+		for _, p := range t.Prerequisites {
+			if !out[p] {
+				out[p] = true
+				visit(p)
+			}
+		}
+	}
+	visit(tech)
+	return out
+}
+
+// Subsets returns `base` with every combination of the optional items added to it, in order
+func Subsets(base, optional []string) [][]string {
+	out := [][]string{append([]string{}, base...)}
+	for _, o := range optional {
+		n := len(out)
+		for _, prev := range out[:n] {
+			out = append(out, append(append([]string{}, prev...), o))
+		}
+	}
+	return out
+}
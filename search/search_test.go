@@ -0,0 +1,104 @@
+package search
+
+import (
+	"log"
+	"os"
+	"testing"
+
+	"github.com/brettschalin/factorio-min-resources/data"
+	"github.com/r3labs/diff/v3"
+)
+
+func TestMain(m *testing.M) {
+
+	err := data.Init(
+		"../data/data-raw-dump.json",
+	)
+
+	if err != nil {
+		log.Fatalf("could not load data: %v", err)
+	}
+
+	os.Exit(m.Run())
+}
+
+func TestTechOrders(t *testing.T) {
+
+	// electronics needs automation
+	orders := TechOrders([]string{"electronics", "automation", "optics"}, 0)
+	expected := [][]string{
+		{"automation", "electronics", "optics"},
+		{"automation", "optics", "electronics"},
+		{"optics", "automation", "electronics"},
+	}
+	if !equal(orders, expected) {
+		t.Fatalf("wrong orders: %v", orders)
+	}
+
+	if orders := TechOrders([]string{"electronics", "automation", "optics"}, 2); len(orders) != 2 {
+		t.Fatalf("expected the limit to be respected, got %d orders", len(orders))
+	}
+}
+
+func TestSubsets(t *testing.T) {
+	expected := [][]string{
+		{"a"},
+		{"a", "b"},
+		{"a", "c"},
+		{"a", "b", "c"},
+	}
+	if got := Subsets([]string{"a"}, []string{"b", "c"}); !equal(got, expected) {
+		t.Fatalf("wrong subsets: %v", got)
+	}
+}
+
+func equal(a, b [][]string) bool {
+	d, _ := diff.Diff(a, b)
+	return len(d) == 0
+}
+
+func TestSearch(t *testing.T) {
+
+	var (
+		power     = []string{"offshore-pump", "boiler", "steam-engine"}
+		base      = Planned(append([]string{"stone-furnace"}, append(power, "lab")...), []string{"automation"})
+		assembler = Planned(append([]string{"stone-furnace"}, append(power, "lab", "assembling-machine-1")...), []string{"automation"})
+		noFurnace = Planned(append(power, "lab"), []string{"automation"})
+		noPower   = Planned([]string{"stone-furnace", "lab"}, []string{"automation"})
+	)
+
+	results, err := Search([]Candidate{assembler, noFurnace, noPower, base}, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if results[0].Candidate.Name != base.Name || results[1].Candidate.Name != assembler.Name {
+		t.Fatalf("wrong order: %q then %q", results[0].Candidate.Name, results[1].Candidate.Name)
+	}
+	if results[0].Total >= results[1].Total {
+		t.Fatalf("expected the extra assembler to cost more (%d vs %d)", results[0].Total, results[1].Total)
+	}
+
+	var total uint
+	for _, n := range results[0].Mined {
+		total += n
+	}
+	if total != results[0].Total {
+		t.Fatalf("total %d doesn't match what was mined (%v)", results[0].Total, results[0].Mined)
+	}
+
+	if _, err := Search([]Candidate{noFurnace}, 1, 1); err == nil {
+		t.Fatal("expected an error when no candidate works")
+	}
+	if r := Evaluate(noPower); r.Err == nil {
+		t.Fatal("expected the lab to need power")
+	}
+
+	// advanced-material-processing's logistic packs can only be crafted once logistic-science-pack is done
+	logistic := Planned(append([]string{"stone-furnace"}, append(power, "lab")...), []string{"steel-processing", "logistic-science-pack", "automation", "advanced-material-processing"})
+	if r := Evaluate(logistic); r.Err != nil {
+		t.Fatal(r.Err)
+	}
+}
@@ -0,0 +1,149 @@
+package state
+
+import (
+	"math"
+	"sort"
+
+	"github.com/brettschalin/factorio-min-resources/building"
+	"github.com/brettschalin/factorio-min-resources/shims"
+)
+
+// The fluid network is kept simple: every building that makes a fluid is assumed to be piped to
+// every building that uses it, which is what the pipes in locations.lua are for. Flow rates through
+// the pipes themselves aren't limited
+
+// fluidInputs returns the fluids the building's recipe needs and how much per craft
+func fluidInputs(b building.Building) map[string]float64 {
+	m, ok := b.(building.CraftingBuilding)
+	if !ok || m.Recipe() == nil {
+		if _, ok := b.(*building.Boiler); ok {
+			return map[string]float64{"water": 1}
+		}
+		return nil
+	}
+	out := map[string]float64{}
+	for _, ing := range m.Recipe().Ingredients {
+		if ing.IsFluid {
+			out[ing.Name] += float64(ing.Amount)
+		}
+	}
+	return out
+}
+
+// hasSource returns whether a pump for the fluid is placed. Pumps never run dry
+func (s *State) hasSource(fluid string) bool {
+	for _, id := range s.IDs() {
+		if src, ok := s.Buildings[id].(building.FluidSource); ok && src.Produces() == fluid {
+			return true
+		}
+	}
+	return false
+}
+
+// producers returns the machines whose recipe makes the fluid, other than `except`
+func (s *State) producers(fluid string, except building.Building) []*building.Assembler {
+	out := []*building.Assembler{}
+	for _, id := range s.IDs() {
+		a, ok := s.Buildings[id].(*building.Assembler)
+		if !ok || a == except || a.Recipe() == nil {
+			continue
+		}
+		for _, p := range a.Recipe().GetResults() {
+			if p.IsFluid && p.Name == fluid {
+				out = append(out, a)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// SupplyFluids fills the fluid boxes of `b` with what its recipe needs, straight from a pump or by
+// running the machines that make the fluid, which draw power for each craft like any other. Returns the fluids
+// that couldn't be supplied in full
+func (s *State) SupplyFluids(b building.Building) []string {
+	return s.supplyFluids(b, map[building.Building]bool{})
+}
+
+func (s *State) supplyFluids(b building.Building, visiting map[building.Building]bool) []string {
+	fb, ok := b.(building.FluidBuilding)
+	if !ok || visiting[b] {
+		return nil
+	}
+	visiting[b] = true
+	defer delete(visiting, b)
+
+	var (
+		missing []string
+		inputs  = fluidInputs(b)
+		fluids  = make([]string, 0, len(inputs))
+	)
+	for fluid := range inputs {
+		fluids = append(fluids, fluid)
+	}
+	sort.Strings(fluids)
+
+	for _, fluid := range fluids {
+		perCraft := inputs[fluid]
+
+		if s.hasSource(fluid) {
+			fb.PutFluid(fluid, math.Inf(1))
+			continue
+		}
+
+		for _, p := range s.producers(fluid, b) {
+			for {
+				need := perCraft - fb.Fluid(fluid)
+				if need <= 1e-9 {
+					break
+				}
+				fb.PutFluid(fluid, p.TakeFluid(fluid, need))
+				if fb.Fluid(fluid) >= perCraft-1e-9 {
+					break
+				}
+				s.supplyFluids(p, visiting)
+				if p.DoCraft() != building.CraftStatusRunning {
+					fb.PutFluid(fluid, p.TakeFluid(fluid, need))
+					break
+				}
+				s.UsePower(p, p.Recipe().CraftingTime()/p.CraftingSpeed())
+			}
+		}
+
+		if fb.Fluid(fluid) < perCraft-1e-9 {
+			missing = append(missing, fluid)
+		}
+	}
+	return missing
+}
+
+// FluidRate returns how much of the fluid, in units per second, the placed buildings can supply.
+// Machines making it are assumed to run as fast as their own fluid inputs allow
+func (s *State) FluidRate(fluid string) float64 {
+	return s.fluidRate(fluid, map[string]bool{})
+}
+
+func (s *State) fluidRate(fluid string, visiting map[string]bool) float64 {
+	if visiting[fluid] {
+		return 0
+	}
+	visiting[fluid] = true
+	defer delete(visiting, fluid)
+
+	var rate float64
+	for _, id := range s.IDs() {
+		if src, ok := s.Buildings[id].(building.FluidSource); ok && src.Produces() == fluid {
+			rate += src.Rate()
+		}
+	}
+
+	for _, p := range s.producers(fluid, nil) {
+		rec := p.Recipe()
+		crafts := p.CraftingSpeed() / rec.CraftingTime()
+		for in, amount := range fluidInputs(p) {
+			crafts = shims.Min(crafts, s.fluidRate(in, visiting)/amount)
+		}
+		rate += crafts * float64(rec.GetResults().Amount(fluid)) * (1 + p.ProductivityBonus(rec.Name))
+	}
+	return rate
+}
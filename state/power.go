@@ -0,0 +1,109 @@
+package state
+
+import (
+	"github.com/brettschalin/factorio-min-resources/building"
+	"github.com/brettschalin/factorio-min-resources/constants"
+	"github.com/brettschalin/factorio-min-resources/shims"
+)
+
+// There's one electric network and everything is connected to it, which is what the poles in
+// locations.lua are for. With nothing generating power on it, electric buildings don't work at all
+
+// powerSupply returns what the solar panels make and how much steam power the boilers and engines can
+// make, both in watts. Boilers without fuel or water don't count
+func (s *State) powerSupply() (solar, steam float64) {
+	var boilers, engines float64
+	for _, id := range s.IDs() {
+		switch b := s.Buildings[id].(type) {
+		case *building.SolarPanel:
+			solar += b.MaxPower()
+		case *building.SteamEngine:
+			engines += b.MaxPower()
+		case *building.Boiler:
+			if b.Energy() > 0 && (s.hasSource("water") || b.Fluid("water") > 0) {
+				boilers += b.MaxPower()
+			}
+		}
+	}
+	return solar, shims.Min(boilers, engines)
+}
+
+// PowerSupply returns the most power, in watts, the network can make right now
+func (s *State) PowerSupply() float64 {
+	solar, steam := s.powerSupply()
+	return solar + steam
+}
+
+// PowerDemand returns how much power, in watts, the electric buildings draw. Only the ones that are
+// working count (see building.IsWorking), not ones that are waiting for ingredients
+func (s *State) PowerDemand() float64 {
+	var demand float64
+	for _, id := range s.IDs() {
+		if b := s.Buildings[id]; building.IsWorking(b) {
+			demand += building.PowerUsage(b)
+		}
+	}
+	return demand
+}
+
+// PowerSatisfaction returns the fraction of the demand the network can meet, which is how fast electric
+// buildings work. It's 0 when nothing generates power
+func (s *State) PowerSatisfaction() float64 {
+	demand := s.PowerDemand()
+	if demand == 0 {
+		return 1
+	}
+	return shims.Min(1, s.PowerSupply()/demand)
+}
+
+// UsePower takes the energy b uses working for `seconds` from the network. The solar panels' output is
+// shared by everything drawing power (see PowerDemand), so they only cover b's part of it and the boilers
+// burn fuel for the rest. Whatever the network doesn't have is owed, and burned as soon as the boilers get
+// more fuel (see PayPower). A run that still owes power at the end doesn't verify
+func (s *State) UsePower(b building.Building, seconds float64) {
+	usage := building.PowerUsage(b)
+	if usage == 0 {
+		return
+	}
+	solar, _ := s.powerSupply()
+	// with nothing else to make up the difference, solar panels that fall short only slow everything
+	// down (see PowerSatisfaction)
+	if solar > 0 && s.first(constants.SteamEngines) == nil {
+		return
+	}
+	share := solar * usage / shims.Max(usage, s.PowerDemand())
+	s.powerOwed += shims.Max(0, (usage-share)*seconds)
+	s.PayPower()
+}
+
+// PayPower burns boiler fuel for any energy the electric buildings used while the network was short, and
+// returns whether it's all been paid for
+func (s *State) PayPower() bool {
+	if _, steam := s.powerSupply(); steam == 0 {
+		return s.powerOwed <= 1e-6
+	}
+	for _, id := range s.IDs() {
+		boiler, ok := s.Buildings[id].(*building.Boiler)
+		if !ok {
+			continue
+		}
+		for s.powerOwed > 1e-6 {
+			s.SupplyFluids(boiler)
+			e := boiler.Generate(s.powerOwed)
+			if e <= 0 {
+				break
+			}
+			s.powerOwed -= e
+		}
+	}
+	if s.powerOwed <= 1e-6 {
+		s.powerOwed = 0
+		return true
+	}
+	return false
+}
+
+// PowerOwed returns the energy, in joules, electric buildings have used that the boilers haven't burned fuel for
+func (s *State) PowerOwed() float64 {
+	return s.powerOwed
+}
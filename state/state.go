@@ -1,34 +1,45 @@
 package state
 
 import (
+	"sort"
+
 	"github.com/brettschalin/factorio-min-resources/building"
 	"github.com/brettschalin/factorio-min-resources/constants"
 	"github.com/brettschalin/factorio-min-resources/data"
 	"github.com/brettschalin/factorio-min-resources/shims/slices"
 )
 
+// BuildingID identifies a placed building. N distinguishes buildings with the same name
+// and is the same index given to tas.Build and used in locations.lua
+type BuildingID struct {
+	Name string
+	N    int
+}
+
 type State struct {
 	// character main inventory
 	Inventory map[string]uint
 
 	TechResearched map[string]bool
 
-	// What's been built?
-	Buildings map[string]bool
+	// recipes unlocked by research. Ones that are enabled from the start aren't in here, see RecipeUnlocked
+	Unlocked map[string]bool
+
+	// queued research, in order. The first one is what the labs are working on
+	Research []string
 
-	// which machines we have access to
-	Furnace   *building.Furnace
-	Assembler *building.Assembler
-	Chem      *building.Assembler
-	Refinery  *building.Assembler
-	Boiler    *building.Boiler
-	Lab       *building.Lab
+	// What's been built? Buildings that aren't modeled (poles, pipes, etc) are nil
+	Buildings map[BuildingID]building.Building
+
+	// energy, in joules, that electric buildings used while the network was short. See UsePower
+	powerOwed float64
 }
 
 func New() *State {
 	s := &State{
 		TechResearched: make(map[string]bool),
-		Buildings:      make(map[string]bool),
+		Unlocked:       make(map[string]bool),
+		Buildings:      make(map[BuildingID]building.Building),
 	}
 
 	// Starting inventory
@@ -49,186 +60,230 @@ func copyMap[K comparable, V any](m map[K]V) map[K]V {
 
 func (s *State) Copy() *State {
 
-	var (
-		f       building.Furnace
-		a, c, r building.Assembler
-		b       building.Boiler
-		l       building.Lab
-		ret     = &State{
-			Inventory:      copyMap(s.Inventory),
-			TechResearched: copyMap(s.TechResearched),
-			Buildings:      copyMap(s.Buildings),
-		}
-	)
-
-	if s.Furnace != nil {
-		f = *s.Furnace
-		ret.Furnace = &f
-	}
-
-	if s.Assembler != nil {
-		a = *s.Assembler
-		ret.Assembler = &a
+	ret := &State{
+		Inventory:      copyMap(s.Inventory),
+		TechResearched: copyMap(s.TechResearched),
+		Unlocked:       copyMap(s.Unlocked),
+		Research:       append([]string(nil), s.Research...),
+		Buildings:      make(map[BuildingID]building.Building, len(s.Buildings)),
+		powerOwed:      s.powerOwed,
 	}
 
-	if s.Chem != nil {
-		c = *s.Chem
-		ret.Chem = &c
+	for id, b := range s.Buildings {
+		ret.Buildings[id] = building.Copy(b)
 	}
 
-	if s.Refinery != nil {
-		r = *s.Refinery
-		ret.Refinery = &r
-	}
+	return ret
+}
 
-	if s.Boiler != nil {
-		b = *s.Boiler
-		ret.Boiler = &b
+// IDs returns the IDs of every placed building, sorted by name then index
+func (s *State) IDs() []BuildingID {
+	ids := make([]BuildingID, 0, len(s.Buildings))
+	for id := range s.Buildings {
+		ids = append(ids, id)
 	}
+	sort.Slice(ids, func(i, j int) bool {
+		if ids[i].Name != ids[j].Name {
+			return ids[i].Name < ids[j].Name
+		}
+		return ids[i].N < ids[j].N
+	})
+	return ids
+}
 
-	if s.Lab != nil {
-		l = *s.Lab
-		ret.Lab = &l
+// first returns the first placed building whose name is in `names`
+func (s *State) first(names []string) building.Building {
+	for _, id := range s.IDs() {
+		if b := s.Buildings[id]; b != nil && slices.Contains(names, id.Name) {
+			return b
+		}
 	}
+	return nil
+}
 
-	return ret
+// RecipeUnlocked returns whether the recipe is enabled from the start or unlocked by a tech that's been researched
+func (s *State) RecipeUnlocked(recipe *data.Recipe) bool {
+	return recipe.IsEnabled() || s.Unlocked[recipe.Name]
 }
 
-func (s *State) GetProductivityBonus(recipe *data.Recipe) float64 {
-	if s.Furnace != nil && s.Furnace.Entity.CanCraft(recipe) {
-		return s.Furnace.ProductivityBonus(recipe.Name)
-	}
-	if s.Assembler != nil && s.Assembler.Entity.CanCraft(recipe) {
-		return s.Assembler.ProductivityBonus(recipe.Name)
+// MarkResearched marks the tech researched and unlocks the recipes it gives
+func (s *State) MarkResearched(tech string) {
+	s.TechResearched[tech] = true
+	if s.Unlocked == nil {
+		s.Unlocked = map[string]bool{}
 	}
-	if s.Chem != nil && s.Chem.Entity.CanCraft(recipe) {
-		return s.Chem.ProductivityBonus(recipe.Name)
-	}
-	if s.Refinery != nil && s.Refinery.Entity.CanCraft(recipe) {
-		return s.Refinery.ProductivityBonus(recipe.Name)
+	if t := data.GetTech(tech); t != nil {
+		for _, e := range t.Effects {
+			if e.Type == "unlock-recipe" {
+				s.Unlocked[e.Recipe] = true
+			}
+		}
 	}
-	return 0
 }
 
-// Construct a building. Returns whether it could be placed
-func (s *State) ConstructBuilding(name string) bool {
+// Furnace returns the first placed furnace, or nil if there are none.
+// Assembler, Chem, Refinery, Boiler, Silo and Lab do the same for their building types
+func (s *State) Furnace() *building.Furnace {
+	b, _ := s.first(constants.Furnaces).(*building.Furnace)
+	return b
+}
 
-	ok := true
+func (s *State) Assembler() *building.Assembler {
+	b, _ := s.first(constants.AssemblingMachines).(*building.Assembler)
+	return b
+}
 
-	switch {
-	case slices.Contains(constants.Furnaces, name):
-		if s.Furnace != nil {
-			return false
-		}
-		s.Furnace = building.NewFurnace(data.GetFurnace(name))
-		ok = s.Furnace != nil
+func (s *State) Chem() *building.Assembler {
+	b, _ := s.first(constants.ChemicalPlants).(*building.Assembler)
+	return b
+}
 
-	case slices.Contains(constants.AssemblingMachines, name):
-		if s.Assembler != nil {
-			return false
-		}
-		s.Assembler = building.NewAssembler(data.GetAssemblingMachine(name))
-		ok = s.Assembler != nil
+func (s *State) Refinery() *building.Assembler {
+	b, _ := s.first(constants.Refineries).(*building.Assembler)
+	return b
+}
 
-	case slices.Contains(constants.ChemicalPlants, name):
-		if s.Chem != nil {
-			return false
-		}
-		s.Chem = building.NewAssembler(data.GetAssemblingMachine(name))
-		ok = s.Chem != nil
+func (s *State) Boiler() *building.Boiler {
+	b, _ := s.first(constants.Boilers).(*building.Boiler)
+	return b
+}
 
-	case slices.Contains(constants.Refineries, name):
-		if s.Refinery != nil {
-			return false
-		}
-		s.Refinery = building.NewAssembler(data.GetAssemblingMachine(name))
-		ok = s.Refinery != nil
+func (s *State) Silo() *building.RocketSilo {
+	b, _ := s.first(constants.RocketSilos).(*building.RocketSilo)
+	return b
+}
 
-	case slices.Contains(constants.Labs, name):
-		if s.Lab != nil {
-			return false
-		}
-		s.Lab = building.NewLab(data.GetLab(name))
-		ok = s.Lab != nil
+func (s *State) Lab() *building.Lab {
+	b, _ := s.first(constants.Labs).(*building.Lab)
+	return b
+}
 
-	case slices.Contains(constants.Boilers, name):
-		if s.Boiler != nil {
-			return false
+// Labs returns every placed lab
+func (s *State) Labs() []*building.Lab {
+	labs := []*building.Lab{}
+	for _, id := range s.IDs() {
+		if l, ok := s.Buildings[id].(*building.Lab); ok {
+			labs = append(labs, l)
 		}
-		s.Boiler = building.NewBoiler(data.GetBoiler(name))
-		ok = s.Boiler != nil
 	}
-	return ok
+	return labs
 }
 
-// Mine a building. Returns whether it could be mined
-func (s *State) MineBuilding(name string) bool {
-	if slices.Contains(constants.Furnaces, name) {
-		if s.Furnace == nil {
-			return false
+// GetProductivityBonus returns the bonus of the first placed building that can craft the recipe
+func (s *State) GetProductivityBonus(recipe *data.Recipe) float64 {
+	for _, id := range s.IDs() {
+		switch b := s.Buildings[id].(type) {
+		case *building.Furnace:
+			if b.Entity.CanCraft(recipe) {
+				return b.ProductivityBonus(recipe.Name)
+			}
+		case *building.Assembler:
+			if b.Entity.CanCraft(recipe) {
+				return b.ProductivityBonus(recipe.Name)
+			}
+		case *building.RocketSilo:
+			if r := b.Recipe(); r != nil && r.Name == recipe.Name {
+				return b.ProductivityBonus(recipe.Name)
+			}
 		}
-		s.Furnace = nil
-		return true
 	}
+	return 0
+}
 
-	if slices.Contains(constants.AssemblingMachines, name) {
-		if s.Assembler == nil {
-			return false
-		}
-		s.Assembler = nil
-		return true
-	}
+// QueueResearch adds a technology to the end of the research queue
+func (s *State) QueueResearch(tech string) {
+	s.Research = append(s.Research, tech)
+}
 
-	if slices.Contains(constants.ChemicalPlants, name) {
-		if s.Chem == nil {
-			return false
+// ResearchProgress returns how many units of the current research are done across all labs
+func (s *State) ResearchProgress() float64 {
+	if len(s.Research) == 0 {
+		return 0
+	}
+	var progress float64
+	for _, l := range s.Labs() {
+		if r := l.Research(); r != nil && r.Name == s.Research[0] {
+			progress += l.Progress()
 		}
-		s.Chem = nil
-		return true
 	}
+	return progress
+}
 
-	if slices.Contains(constants.Refineries, name) {
-		if s.Refinery == nil {
-			return false
-		}
-		s.Refinery = nil
-		return true
+// ResearchDone returns whether enough units have been processed to finish the current research
+func (s *State) ResearchDone() bool {
+	if len(s.Research) == 0 {
+		return false
 	}
+	// tolerance for floating point errors in the productivity bonus
+	return s.ResearchProgress() >= float64(data.GetTech(s.Research[0]).Unit.Count)-1e-6
+}
 
-	if slices.Contains(constants.Labs, name) {
-		if s.Lab == nil {
-			return false
-		}
-		s.Lab = nil
-		return true
+// FinishResearch marks the current research as done and moves on to the next one
+func (s *State) FinishResearch() {
+	s.MarkResearched(s.Research[0])
+	for _, l := range s.Labs() {
+		l.SetResearch(nil)
 	}
+	s.Research = s.Research[1:]
+	if len(s.Research) == 0 {
+		s.Research = nil
+	}
+}
 
-	if slices.Contains(constants.Boilers, name) {
-		if s.Boiler == nil {
-			return false
+// DoResearch has the labs use up as many science packs as they can on the queued research,
+// marking technologies researched as they finish
+func (s *State) DoResearch() {
+	for len(s.Research) > 0 {
+		tech := data.GetTech(s.Research[0])
+		labs := s.Labs()
+		for _, l := range labs {
+			if r := l.Research(); r == nil || r.Name != tech.Name {
+				l.SetResearch(tech)
+			}
+			for !s.ResearchDone() && l.DoResearch() == building.CraftStatusRunning {
+				s.UsePower(l, l.UnitTime())
+			}
 		}
-		s.Boiler = nil
-		return true
+		if !s.ResearchDone() {
+			return
+		}
+		s.FinishResearch()
 	}
+}
 
+// IsPlaced returns whether the nth building with this name has been built
+func (s *State) IsPlaced(name string, n int) bool {
+	_, ok := s.Buildings[BuildingID{name, n}]
+	return ok
+}
+
+// Construct a building. Returns whether it could be placed
+func (s *State) ConstructBuilding(name string, n int) bool {
+	if s.IsPlaced(name, n) {
+		return false
+	}
+	if s.Buildings == nil {
+		s.Buildings = make(map[BuildingID]building.Building)
+	}
+	s.Buildings[BuildingID{name, n}] = building.New(name, n)
 	return true
 }
 
-func (s *State) GetBuilding(name string) building.Building {
-	switch {
-	case s.Assembler != nil && s.Assembler.Name() == name:
-		return s.Assembler
-	case s.Chem != nil && s.Chem.Name() == name:
-		return s.Chem
-	case s.Furnace != nil && s.Furnace.Name() == name:
-		return s.Furnace
-	case s.Refinery != nil && s.Refinery.Name() == name:
-		return s.Refinery
-	case s.Lab != nil && s.Lab.Name() == name:
-		return s.Lab
-	case s.Boiler != nil && s.Boiler.Name() == name:
-		return s.Boiler
+// Mine a building, returning the contents of its inventories to the player. Returns whether it could be mined
+func (s *State) MineBuilding(name string, n int) bool {
+	if !s.IsPlaced(name, n) {
+		return false
+	}
+	if b := s.GetBuilding(name, n); b != nil {
+		for item, count := range building.Contents(b) {
+			s.Inventory[item] += uint(count)
+		}
 	}
+	delete(s.Buildings, BuildingID{name, n})
+	return true
+}
 
-	return nil
+// GetBuilding returns the nth building with this name, or nil if it isn't placed
+func (s *State) GetBuilding(name string, n int) building.Building {
+	return s.Buildings[BuildingID{name, n}]
 }
@@ -1,11 +1,28 @@
 package tas
 
-import "io"
+import (
+	"io"
+)
 
 func (tas *TAS) Export(w io.Writer) error {
 
 	var err error
 
+	if err = tas.check(); err != nil {
+		return err
+	}
+	if err = tas.verifyQueues(); err != nil {
+		return err
+	}
+
+	// research left unfinished is fine while tasks are still being added, but not in the final output
+	if err = verifyResearch(tas.state); err != nil {
+		return err
+	}
+	if err = verifyPower(tas.state); err != nil {
+		return err
+	}
+
 	if _, err = w.Write([]byte(TasksLuaHeader)); err != nil {
 		return err
 	}
@@ -40,7 +57,7 @@ function populate_tasks(queues)
 
 -- Mining / inventory transfers. Checks that some amount of
 -- item is in the machine
-local function has_inventory(inv, item, amount, exact, slot)
+local function has_inventory(inv, item, amount, exact, slot, n)
     return function(p)
         if type(inv) == "function" then
             local cnt = inv().get_item_count(item)
@@ -60,7 +77,7 @@ local function has_inventory(inv, item, amount, exact, slot)
         else
             -- it's the name of a building. This will throw an error if the building isn't placed,
             -- which is a problem with the task dependencies rather than this code
-            b = loc.buildings.get(p, inv).entity
+            b = loc.buildings.get(p, inv, n).entity
 
             if slot then
                 slots = {
@@ -107,9 +124,17 @@ local function has_inventory(inv, item, amount, exact, slot)
 end
 
 -- is the building placed on the map?
-local function is_built(entity)
+local function is_built(entity, n)
+    return function(p)
+        return loc.buildings.is_placed(p, entity, n)
+    end
+end
+
+-- has the building been mined? Checking the inventory instead would be done straight away if
+-- the player was already holding one
+local function is_mined(entity, n)
     return function(p)
-        return loc.buildings.is_placed(p, entity)
+        return not loc.buildings.is_placed(p, entity, n)
     end
 end
 
@@ -204,14 +229,13 @@ function add_task(task, prereqs, args)
         if args.location ~= nil then
             done = mined
         elseif args.entity ~= nil then
-            -- TODO: replace this with mined?
-            done = has_inventory("player", args.entity, args.amount or 1)
+            done = is_mined(args.entity, args.n)
         else
             done = has_inventory("player", args.resource, args.amount or 1)
         end
     elseif task == "build" then
         q = "character_action"
-        done = is_built(args.entity)
+        done = is_built(args.entity, args.n)
     elseif task == "wait" then
         q = "character_action"
     elseif task == "craft" then
@@ -0,0 +1,279 @@
+package tas
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/brettschalin/factorio-min-resources/constants"
+	"github.com/brettschalin/factorio-min-resources/data"
+	"github.com/brettschalin/factorio-min-resources/geo"
+)
+
+// The JSON format is a list of tasks in the order they're run. Every task has an ID, which is also its
+// variable name in tasks.lua (the TAS generates one if it's left out), and its prerequisites either reference
+// an earlier task by ID or give a condition to wait for (see PrereqWait). Example:
+//
+//	{"tasks": [
+//	    {"id": "mine_iron_ore", "type": "mine", "args": {"resource": "iron-ore", "amount": 10}},
+//	    {"id": "craft_iron_gear_wheel", "type": "craft", "args": {"recipe": "iron-gear-wheel", "amount": 5},
+//	     "prerequisites": [{"id": "mine_iron_ore"}, {"wait": {"entity": "player", "item": "iron-plate", "inventory": "character_main", "amount": 10}}]}
+//	]}
+
+type taskList struct {
+	Tasks []taskJSON `json:"tasks"`
+}
+
+type taskJSON struct {
+	ID            string       `json:"id"`
+	Type          TaskType     `json:"type"`
+	Args          taskArgs     `json:"args"`
+	Prerequisites []prereqJSON `json:"prerequisites,omitempty"`
+}
+
+// prereqJSON is either a reference to another task or a condition to wait for
+type prereqJSON struct {
+	ID   string    `json:"id,omitempty"`
+	Wait *taskArgs `json:"wait,omitempty"`
+}
+
+// taskArgs has every task's arguments. Only the ones used by the task's type are set
+type taskArgs struct {
+	Entity    string               `json:"entity,omitempty"`
+	N         int                  `json:"n,omitempty"`
+	Resource  string               `json:"resource,omitempty"`
+	Recipe    string               `json:"recipe,omitempty"`
+	Tech      string               `json:"tech,omitempty"`
+	Item      string               `json:"item,omitempty"`
+	Inventory *constants.Inventory `json:"inventory,omitempty"`
+	Amount    uint                 `json:"amount,omitempty"`
+	Exact     bool                 `json:"exact,omitempty"`
+	Location  *geo.Point           `json:"location,omitempty"`
+	Speed     float64              `json:"speed,omitempty"`
+}
+
+// ExportJSON writes the tasks in the JSON format. Unlike Export, the run doesn't have to be complete
+func (tas *TAS) ExportJSON(w io.Writer) error {
+
+	if err := tas.check(); err != nil {
+		return err
+	}
+
+	list := taskList{Tasks: make([]taskJSON, len(tas.tasks))}
+	for i, task := range tas.tasks {
+		tj, err := toJSON(task)
+		if err != nil {
+			return err
+		}
+		list.Tasks[i] = tj
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(list)
+}
+
+// ImportJSON reads tasks written by ExportJSON (or by hand) and verifies them the same as TAS.Add
+func ImportJSON(r io.Reader) (*TAS, error) {
+
+	tasks, err := parseJSON(r)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &TAS{}
+	if err := t.Add(tasks...); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// parseJSON reads the tasks without verifying them
+func parseJSON(r io.Reader) (Tasks, error) {
+
+	var list taskList
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&list); err != nil {
+		return nil, fmt.Errorf(`[json] %v`, err)
+	}
+
+	tasks, err := list.build()
+	if err != nil {
+		return nil, fmt.Errorf(`[json] %v`, err)
+	}
+	return tasks, nil
+}
+
+// build makes the tasks and links their prerequisites. Tasks without an ID get one when they're added to a TAS
+func (list taskList) build() (Tasks, error) {
+
+	byID := map[string]Task{}
+	tasks := make(Tasks, 0, len(list.Tasks))
+
+	for i, tj := range list.Tasks {
+		if tj.ID != "" && !validID.MatchString(tj.ID) {
+			return nil, fmt.Errorf(`task %d has invalid ID %q`, i, tj.ID)
+		}
+		if _, ok := byID[tj.ID]; ok {
+			return nil, fmt.Errorf(`duplicate task ID %q`, tj.ID)
+		}
+		task, err := fromJSON(tj.Type, tj.Args)
+		if err != nil {
+			return nil, fmt.Errorf(`task %s: %v`, describe(i, tj.ID), err)
+		}
+		if tj.ID != "" {
+			Named(task, tj.ID)
+			byID[tj.ID] = task
+		}
+		tasks.Add(task)
+	}
+
+	// prerequisites can only be linked once every task exists. Ordering is checked by Verify
+	for i, tj := range list.Tasks {
+		for _, p := range tj.Prerequisites {
+			switch {
+			case p.ID != "" && p.Wait != nil:
+				return nil, fmt.Errorf(`task %s: a prerequisite can't have both an ID and a wait`, describe(i, tj.ID))
+			case p.Wait != nil:
+				w := p.Wait
+				if w.Inventory == nil {
+					return nil, fmt.Errorf(`task %s: wait prerequisite needs an inventory`, describe(i, tj.ID))
+				}
+				if !knownItem(w.Item) {
+					return nil, fmt.Errorf(`task %s: unknown item %q`, describe(i, tj.ID), w.Item)
+				}
+				tasks[i].Prerequisites().Add(PrereqWait(w.Entity, w.N, w.Item, *w.Inventory, w.Amount, w.Exact))
+			default:
+				pre, ok := byID[p.ID]
+				if !ok {
+					return nil, fmt.Errorf(`task %s references unknown prerequisite %q`, describe(i, tj.ID), p.ID)
+				}
+				tasks[i].Prerequisites().Add(pre)
+			}
+		}
+	}
+	return tasks, nil
+}
+
+// knownItem returns whether the item is in the data. Science packs are tools and modules have their own
+// type, so they aren't with the rest of the items
+func knownItem(name string) bool {
+	return data.GetItem(name) != nil || data.GetTool(name) != nil || data.GetModule(name) != nil
+}
+
+// describe names a task in error messages by its ID, or its position if it doesn't have one
+func describe(i int, id string) string {
+	if id == "" {
+		return strconv.Itoa(i)
+	}
+	return strconv.Quote(id)
+}
+
+func toJSON(task Task) (taskJSON, error) {
+
+	tj := taskJSON{ID: task.ID(), Type: task.Type()}
+
+	switch t := task.(type) {
+	case *taskCraft:
+		tj.Args = taskArgs{Recipe: t.Recipe, Amount: t.Amount}
+	case *taskWalk:
+		loc := t.Location
+		tj.Args = taskArgs{Location: &loc}
+	case *taskWait:
+		tj.Args = inventoryArgs(t.Entity, t.N, t.Item, t.Slot, t.Amount, t.Exact)
+	case *taskMine:
+		tj.Args = taskArgs{Resource: t.Resource, Amount: t.Amount, Entity: t.Entity, N: t.N}
+	case *taskBuild:
+		tj.Args = taskArgs{Entity: t.Entity, N: t.N}
+	case *taskTake:
+		tj.Args = inventoryArgs(t.Entity, t.N, t.Item, t.Slot, t.Amount, false)
+	case *taskPut:
+		tj.Args = inventoryArgs(t.Entity, t.N, t.Item, t.Slot, t.Amount, false)
+	case *taskRecipe:
+		tj.Args = taskArgs{Entity: t.Entity, N: t.N, Recipe: t.Recipe}
+	case *taskTech:
+		tj.Args = taskArgs{Tech: t.Tech}
+	case *taskSpeed:
+		tj.Args = taskArgs{Speed: t.Speed}
+	case *taskLaunch:
+	default:
+		return tj, fmt.Errorf(`[json] cannot export task %q of type %T`, task.ID(), task)
+	}
+
+	for _, p := range *task.Prerequisites() {
+		if w, ok := p.(*taskPrereqWait); ok {
+			args := inventoryArgs(w.Entity, w.N, w.Item, w.Slot, w.Amount, w.Exact)
+			tj.Prerequisites = append(tj.Prerequisites, prereqJSON{Wait: &args})
+		} else {
+			tj.Prerequisites = append(tj.Prerequisites, prereqJSON{ID: p.ID()})
+		}
+	}
+	return tj, nil
+}
+
+func inventoryArgs(entity string, n int, item string, slot constants.Inventory, amount uint, exact bool) taskArgs {
+	return taskArgs{Entity: entity, N: n, Item: item, Inventory: &slot, Amount: amount, Exact: exact}
+}
+
+func fromJSON(typ TaskType, a taskArgs) (Task, error) {
+
+	// everything that moves items needs to know which inventory
+	slot := func() (constants.Inventory, error) {
+		if a.Inventory == nil {
+			return 0, fmt.Errorf(`%s needs an inventory`, typ)
+		}
+		if !knownItem(a.Item) {
+			return 0, fmt.Errorf(`unknown item %q`, a.Item)
+		}
+		return *a.Inventory, nil
+	}
+
+	switch typ {
+	case TaskCraft:
+		if data.GetRecipe(a.Recipe) == nil {
+			return nil, fmt.Errorf(`unknown recipe %q`, a.Recipe)
+		}
+		return Craft(a.Recipe, a.Amount), nil
+	case TaskWalk:
+		if a.Location == nil {
+			return nil, fmt.Errorf(`walk needs a location`)
+		}
+		return Walk(*a.Location), nil
+	case TaskWait:
+		s, err := slot()
+		if err != nil {
+			return nil, err
+		}
+		return WaitInventory(a.Entity, a.N, a.Item, s, a.Amount, a.Exact), nil
+	case TaskMine:
+		if a.Resource != "" {
+			return MineResource(a.Resource, a.Amount), nil
+		}
+		return MineEntity(a.Entity, a.N), nil
+	case TaskBuild:
+		return Build(a.Entity, a.N), nil
+	case TaskTake, TaskPut:
+		s, err := slot()
+		if err != nil {
+			return nil, err
+		}
+		return Transfer(a.Entity, a.N, a.Item, s, a.Amount, typ == TaskTake), nil
+	case TaskRecipe:
+		if data.GetRecipe(a.Recipe) == nil {
+			return nil, fmt.Errorf(`unknown recipe %q`, a.Recipe)
+		}
+		return Recipe(a.Entity, a.N, a.Recipe), nil
+	case TaskTech:
+		if data.GetTech(a.Tech) == nil {
+			return nil, fmt.Errorf(`unknown tech %q`, a.Tech)
+		}
+		return Tech(a.Tech), nil
+	case TaskSpeed:
+		return Speed(a.Speed), nil
+	case TaskLaunch:
+		return Launch(), nil
+	}
+	return nil, fmt.Errorf(`missing task type`)
+}
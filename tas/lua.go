@@ -0,0 +1,563 @@
+package tas
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/brettschalin/factorio-min-resources/constants"
+	"github.com/brettschalin/factorio-min-resources/geo"
+)
+
+// ImportLua reads the add_task calls from a tasks.lua file, like the ones Export writes, and verifies them
+// the same as TAS.Add. This is so hand edits to tasks.lua can be checked and copied back into the Go code.
+// Only what Export can write is understood: arguments have to be literals, and the only function that can
+// be called is has_inventory. The crash site is mined before any of the tasks start, so those calls in the
+// header are skipped
+func ImportLua(r io.Reader) (*TAS, error) {
+
+	tasks, err := parseLua(r)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &TAS{}
+	if err := t.Add(tasks...); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// parseLua reads the tasks without verifying them
+func parseLua(r io.Reader) (Tasks, error) {
+
+	stmts, err := addTaskCalls(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var list taskList
+	for _, st := range stmts {
+		tj, skip, err := st.task()
+		if err != nil {
+			return nil, fmt.Errorf(`[lua] line %d: %v`, st.line, err)
+		}
+		if !skip {
+			list.Tasks = append(list.Tasks, tj)
+		}
+	}
+
+	tasks, err := list.build()
+	if err != nil {
+		return nil, fmt.Errorf(`[lua] %v`, err)
+	}
+	return tasks, nil
+}
+
+// matches the start of a statement that adds a task, with or without assigning it to a variable
+var addTaskCall = regexp.MustCompile(`^\s*(?:([A-Za-z_][A-Za-z0-9_]*)\s*=\s*)?add_task\s*\(`)
+
+// luaCall is one add_task call
+type luaCall struct {
+	line int
+	id   string
+	args []luaValue
+}
+
+// addTaskCalls finds every add_task call. They can be split over several lines
+func addTaskCalls(r io.Reader) ([]luaCall, error) {
+
+	var (
+		out   []luaCall
+		sc    = bufio.NewScanner(r)
+		lines []string
+	)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < len(lines); i++ {
+		m := addTaskCall.FindStringSubmatchIndex(lines[i])
+		if m == nil {
+			continue
+		}
+
+		// keep adding lines until the call's closed
+		start := i
+		src := lines[i][m[1]:]
+		var (
+			args []luaValue
+			err  error
+		)
+		for {
+			var tokens []luaToken
+			if tokens, err = luaTokens(src); err == nil {
+				args, err = (&luaParser{tokens: tokens}).callArgs()
+			}
+			if err != errLuaEOF || i+1 == len(lines) {
+				break
+			}
+			i++
+			src += "\n" + lines[i]
+		}
+		if err != nil {
+			return nil, fmt.Errorf(`[lua] line %d: %v`, start+1, err)
+		}
+
+		id := ""
+		if m[2] >= 0 {
+			id = lines[start][m[2]:m[3]]
+		}
+		out = append(out, luaCall{line: start + 1, id: id, args: args})
+	}
+	return out, nil
+}
+
+// task converts the call's arguments. Returns true if it's mining the crash site, which the Go code doesn't model
+func (c luaCall) task() (taskJSON, bool, error) {
+
+	tj := taskJSON{ID: c.id}
+
+	if len(c.args) != 3 {
+		return tj, false, fmt.Errorf(`add_task takes 3 arguments, got %d`, len(c.args))
+	}
+	name, ok := c.args[0].(string)
+	if !ok {
+		return tj, false, fmt.Errorf(`task type must be a string`)
+	}
+	typ, ok := ParseTaskType(name)
+	if !ok {
+		return tj, false, fmt.Errorf(`unknown task type %q`, name)
+	}
+	tj.Type = typ
+
+	args, ok := c.args[2].(luaTable)
+	if !ok {
+		return tj, false, fmt.Errorf(`task arguments must be a table`)
+	}
+	if _, ok := args["location"]; ok && typ == TaskMine {
+		return tj, true, nil
+	}
+
+	var err error
+	if tj.Args, err = args.taskArgs(typ); err != nil {
+		return tj, false, err
+	}
+
+	switch prereqs := c.args[1].(type) {
+	case nil:
+	case luaTable:
+		if len(prereqs) > 0 {
+			return tj, false, fmt.Errorf(`prerequisites must be nil or a list`)
+		}
+	case luaList:
+		for _, p := range prereqs {
+			switch p := p.(type) {
+			case luaIdent:
+				tj.Prerequisites = append(tj.Prerequisites, prereqJSON{ID: string(p)})
+			case luaCallValue:
+				w, err := p.hasInventory()
+				if err != nil {
+					return tj, false, err
+				}
+				tj.Prerequisites = append(tj.Prerequisites, prereqJSON{Wait: &w})
+			default:
+				return tj, false, fmt.Errorf(`prerequisites must be task variables or has_inventory calls`)
+			}
+		}
+	default:
+		return tj, false, fmt.Errorf(`prerequisites must be nil or a list`)
+	}
+	return tj, false, nil
+}
+
+// taskArgs reads a task's argument table. `n` is the game speed for speed tasks and the building's index otherwise
+func (t luaTable) taskArgs(typ TaskType) (taskArgs, error) {
+
+	keys := make([]string, 0, len(t))
+	for key := range t {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var a taskArgs
+	for _, key := range keys {
+		var (
+			v   = t[key]
+			err error
+		)
+		switch key {
+		case "entity":
+			a.Entity, err = luaString(key, v)
+		case "resource":
+			a.Resource, err = luaString(key, v)
+		case "recipe":
+			a.Recipe, err = luaString(key, v)
+		case "item":
+			if typ == TaskCraft {
+				a.Recipe, err = luaString(key, v)
+			} else {
+				a.Item, err = luaString(key, v)
+			}
+		case "tech":
+			a.Tech, err = luaString(key, v)
+		case "amount":
+			a.Amount, err = luaUint(key, v)
+		case "n":
+			if typ == TaskSpeed {
+				a.Speed, err = luaNumber(key, v)
+			} else {
+				var n uint
+				n, err = luaUint(key, v)
+				a.N = int(n)
+			}
+		case "inventory":
+			var inv constants.Inventory
+			inv, err = luaInventory(v)
+			a.Inventory = &inv
+		case "location":
+			loc, ok := v.(luaTable)
+			if !ok {
+				return a, fmt.Errorf(`location must be a table`)
+			}
+			var p geo.Point
+			if p.X, err = luaNumber("x", loc["x"]); err != nil {
+				return a, err
+			}
+			p.Y, err = luaNumber("y", loc["y"])
+			a.Location = &p
+		case "done":
+			call, ok := v.(luaCallValue)
+			if !ok || typ != TaskWait {
+				return a, fmt.Errorf(`only wait tasks can set done, and only to a has_inventory call`)
+			}
+			a, err = call.hasInventory()
+			if err != nil {
+				return a, err
+			}
+		default:
+			return a, fmt.Errorf(`unknown argument %q`, key)
+		}
+		if err != nil {
+			return a, err
+		}
+	}
+	return a, nil
+}
+
+// hasInventory reads the arguments of has_inventory(entity, item, amount, exact, slot, n)
+func (c luaCallValue) hasInventory() (taskArgs, error) {
+
+	var a taskArgs
+	if c.name != "has_inventory" {
+		return a, fmt.Errorf(`unsupported function %q`, c.name)
+	}
+	if len(c.args) < 5 || len(c.args) > 6 {
+		return a, fmt.Errorf(`has_inventory takes 5 or 6 arguments, got %d`, len(c.args))
+	}
+
+	var err error
+	if a.Entity, err = luaString("entity", c.args[0]); err != nil {
+		return a, err
+	}
+	if a.Item, err = luaString("item", c.args[1]); err != nil {
+		return a, err
+	}
+	if a.Amount, err = luaUint("amount", c.args[2]); err != nil {
+		return a, err
+	}
+	exact, ok := c.args[3].(bool)
+	if !ok {
+		return a, fmt.Errorf(`exact must be a boolean`)
+	}
+	a.Exact = exact
+	inv, err := luaInventory(c.args[4])
+	if err != nil {
+		return a, err
+	}
+	a.Inventory = &inv
+	if len(c.args) == 6 {
+		n, err := luaUint("n", c.args[5])
+		if err != nil {
+			return a, err
+		}
+		a.N = int(n)
+	}
+	return a, nil
+}
+
+func luaString(key string, v luaValue) (string, error) {
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf(`%s must be a string`, key)
+	}
+	return s, nil
+}
+
+func luaNumber(key string, v luaValue) (float64, error) {
+	f, ok := v.(float64)
+	if !ok {
+		return 0, fmt.Errorf(`%s must be a number`, key)
+	}
+	return f, nil
+}
+
+func luaUint(key string, v luaValue) (uint, error) {
+	f, err := luaNumber(key, v)
+	if err != nil {
+		return 0, err
+	}
+	if f < 0 || f != float64(uint(f)) {
+		return 0, fmt.Errorf(`%s must be a whole number, got %v`, key, f)
+	}
+	return uint(f), nil
+}
+
+func luaInventory(v luaValue) (constants.Inventory, error) {
+	name, ok := v.(luaIdent)
+	if !ok || !strings.HasPrefix(string(name), "defines.inventory.") {
+		return 0, fmt.Errorf(`inventory must be one of defines.inventory`)
+	}
+	inv, ok := constants.ParseInventory(string(name))
+	if !ok {
+		return 0, fmt.Errorf(`unknown inventory %q`, name)
+	}
+	return inv, nil
+}
+
+/**** PARSING ****/
+
+// luaValue is one of: nil, bool, float64, string, luaIdent, luaTable, luaList or luaCallValue
+type luaValue interface{}
+
+// a variable, possibly with dots in it like defines.inventory.fuel
+type luaIdent string
+
+// a table with keys, like {x = 1, y = 2}
+type luaTable map[string]luaValue
+
+// a table without keys, like {a, b}
+type luaList []luaValue
+
+type luaCallValue struct {
+	name string
+	args []luaValue
+}
+
+type luaToken struct {
+	kind byte // one of the punctuation characters, or 's' for strings, 'n' for numbers and 'i' for identifiers
+	text string
+}
+
+var errLuaEOF = fmt.Errorf(`unexpected end of input`)
+
+// luaTokens splits src into tokens, skipping whitespace and comments
+func luaTokens(src string) ([]luaToken, error) {
+
+	var out []luaToken
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case strings.HasPrefix(src[i:], "--"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.IndexByte("{}(),=", c) >= 0:
+			out = append(out, luaToken{kind: c, text: string(c)})
+			i++
+		case c == '"':
+			j := i + 1
+			for j < len(src) && src[j] != '"' {
+				if src[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(src) {
+				return nil, errLuaEOF
+			}
+			s, err := strconv.Unquote(src[i : j+1])
+			if err != nil {
+				return nil, fmt.Errorf(`bad string %s`, src[i:j+1])
+			}
+			out = append(out, luaToken{kind: 's', text: s})
+			i = j + 1
+		case c == '-' || c == '.' || (c >= '0' && c <= '9'):
+			j := i + 1
+			for j < len(src) && strings.IndexByte("0123456789.eE+-", src[j]) >= 0 && !strings.HasPrefix(src[j:], "--") {
+				j++
+			}
+			out = append(out, luaToken{kind: 'n', text: src[i:j]})
+			i = j
+		case c == '_' || (c|0x20 >= 'a' && c|0x20 <= 'z'):
+			j := i + 1
+			for j < len(src) && (src[j] == '_' || src[j] == '.' || (src[j]|0x20 >= 'a' && src[j]|0x20 <= 'z') || (src[j] >= '0' && src[j] <= '9')) {
+				j++
+			}
+			out = append(out, luaToken{kind: 'i', text: src[i:j]})
+			i = j
+		default:
+			return nil, fmt.Errorf(`unexpected character %q`, c)
+		}
+	}
+	return out, nil
+}
+
+type luaParser struct {
+	tokens []luaToken
+	pos    int
+}
+
+func (p *luaParser) next() (luaToken, error) {
+	if p.pos >= len(p.tokens) {
+		return luaToken{}, errLuaEOF
+	}
+	p.pos++
+	return p.tokens[p.pos-1], nil
+}
+
+func (p *luaParser) peek() (luaToken, error) {
+	if p.pos >= len(p.tokens) {
+		return luaToken{}, errLuaEOF
+	}
+	return p.tokens[p.pos], nil
+}
+
+func (p *luaParser) expect(kind byte) error {
+	t, err := p.next()
+	if err != nil {
+		return err
+	}
+	if t.kind != kind {
+		return fmt.Errorf(`expected %q but got %q`, kind, t.text)
+	}
+	return nil
+}
+
+// callArgs reads a function's arguments, after the opening parenthesis, up to and including the closing one
+func (p *luaParser) callArgs() ([]luaValue, error) {
+	var args []luaValue
+	for {
+		t, err := p.peek()
+		if err != nil {
+			return nil, err
+		}
+		if t.kind == ')' {
+			p.pos++
+			return args, nil
+		}
+		if len(args) > 0 {
+			if err := p.expect(','); err != nil {
+				return nil, err
+			}
+		}
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, v)
+	}
+}
+
+func (p *luaParser) value() (luaValue, error) {
+	t, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	switch t.kind {
+	case 's':
+		return t.text, nil
+	case 'n':
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf(`bad number %q`, t.text)
+		}
+		return f, nil
+	case 'i':
+		switch t.text {
+		case "nil":
+			return nil, nil
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+		if next, err := p.peek(); err == nil && next.kind == '(' {
+			p.pos++
+			args, err := p.callArgs()
+			if err != nil {
+				return nil, err
+			}
+			return luaCallValue{name: t.text, args: args}, nil
+		}
+		return luaIdent(t.text), nil
+	case '{':
+		return p.table()
+	}
+	return nil, fmt.Errorf(`unexpected %q`, t.text)
+}
+
+// table reads a table after the opening brace. Tables have to either have all keys or none
+func (p *luaParser) table() (luaValue, error) {
+	var (
+		keyed = luaTable{}
+		list  = luaList{}
+	)
+	for {
+		t, err := p.peek()
+		if err != nil {
+			return nil, err
+		}
+		if t.kind == '}' {
+			p.pos++
+			break
+		}
+		if len(keyed)+len(list) > 0 {
+			if err := p.expect(','); err != nil {
+				return nil, err
+			}
+			// trailing commas are allowed
+			if t, err := p.peek(); err == nil && t.kind == '}' {
+				continue
+			}
+		}
+
+		// a key is an identifier followed by =
+		if t, err := p.peek(); err == nil && t.kind == 'i' && p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].kind == '=' {
+			p.pos += 2
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := keyed[t.text]; ok {
+				return nil, fmt.Errorf(`duplicate key %q`, t.text)
+			}
+			keyed[t.text] = v
+			continue
+		}
+
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+
+	switch {
+	case len(keyed) > 0 && len(list) > 0:
+		return nil, fmt.Errorf(`tables can't mix keys and values`)
+	case len(list) > 0:
+		return list, nil
+	}
+	return keyed, nil
+}
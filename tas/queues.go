@@ -0,0 +1,236 @@
+package tas
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/brettschalin/factorio-min-resources/constants"
+	"github.com/brettschalin/factorio-min-resources/shims"
+	"github.com/brettschalin/factorio-min-resources/state"
+)
+
+// The mod only ever looks at the task at the front of each queue, so a task that can't start holds up
+// everything behind it. That's fine as long as whatever it's waiting on is in a queue that can keep going,
+// but if two queues are each waiting on something stuck behind the other, nothing ever moves again.
+//
+// verifyQueues finds these by building a graph of what each task waits on: the task in front of it, its
+// prerequisites, and for wait conditions the first task after which the condition holds. Conditions are only
+// checked from when the task could first start, once the task in front of it and its prerequisites are done,
+// since one that held before then but doesn't anymore won't let it go. A cycle in the graph is a deadlock
+
+// waitEdge is something a task waits on before it can start (or for techs, finish)
+type waitEdge struct {
+	to     int
+	reason string
+}
+
+// condition is a wait that's satisfied by the state rather than a task
+type condition struct {
+	task   int
+	reason string
+	holds  func(s *state.State) bool
+
+	// the last task that has to be done before this one can start, or -1 if it can start right away
+	ready int
+
+	// whether the task can make it hold by itself, like a tech whose packs are already in the lab
+	self bool
+}
+
+func (tas *TAS) verifyQueues() error {
+
+	var (
+		n     = len(tas.tasks)
+		edges = make([][]waitEdge, n)
+		index = make(map[Task]int, n)
+		last  = map[string]int{}
+		conds []condition
+	)
+
+	for i, task := range tas.tasks {
+		index[task] = i
+
+		ready := -1
+		if j, ok := last[task.Type().Queue()]; ok {
+			edges[i] = append(edges[i], waitEdge{to: j, reason: "is queued behind"})
+			ready = j
+		}
+		last[task.Type().Queue()] = i
+
+		for _, p := range *task.Prerequisites() {
+			if j, ok := index[p]; ok {
+				edges[i] = append(edges[i], waitEdge{to: j, reason: "needs"})
+				ready = shims.Max(ready, j)
+			}
+		}
+
+		for _, p := range *task.Prerequisites() {
+			if w, ok := p.(*taskPrereqWait); ok {
+				conds = append(conds, condition{
+					task:   i,
+					reason: fmt.Sprintf("waits for %s from", w.ID()),
+					holds:  inventoryHolds(w.Entity, w.N, w.Item, w.Slot, w.Amount, w.Exact),
+					ready:  ready,
+				})
+			}
+		}
+
+		switch t := task.(type) {
+		case *taskWait:
+			conds = append(conds, condition{
+				task:   i,
+				reason: fmt.Sprintf("waits for %s from", t.ID()),
+				holds:  inventoryHolds(t.Entity, t.N, t.Item, t.Slot, t.Amount, t.Exact),
+				ready:  ready,
+			})
+		case *taskTech:
+			// a tech is only done once its research is, which the lab queue waits for
+			tech := t.Tech
+			conds = append(conds, condition{
+				task:   i,
+				reason: "is researched by",
+				holds:  func(s *state.State) bool { return s.TechResearched[tech] },
+				ready:  ready,
+				self:   true,
+			})
+		}
+	}
+
+	// play the tasks back and see when each condition first holds once its task could start. One that
+	// already holds by then doesn't wait on anything else
+	s := state.New()
+	resolve := func(k int) {
+		left := conds[:0]
+		for _, c := range conds {
+			if k < c.ready || !c.holds(s) {
+				left = append(left, c)
+			} else if k != c.ready && !(c.self && k == c.task) {
+				edges[c.task] = append(edges[c.task], waitEdge{to: k, reason: c.reason})
+			}
+		}
+		conds = left
+	}
+	resolve(-1)
+	for k, task := range tas.tasks {
+		if err := verifyTask(s, task); err != nil {
+			return err
+		}
+		resolve(k)
+	}
+
+	// techs that are never researched are only a problem if something waits on them
+	never := map[int]string{}
+	for _, c := range conds {
+		if _, ok := tas.tasks[c.task].(*taskTech); ok {
+			never[c.task] = "which is never researched"
+			continue
+		}
+		return fmt.Errorf(`[deadlock] %s %s, which never happens`, tas.describeTask(c.task), strings.TrimSuffix(c.reason, " from"))
+	}
+	for i := range edges {
+		for _, e := range edges[i] {
+			if why, ok := never[e.to]; ok {
+				return fmt.Errorf(`[deadlock] %s %s %s, %s`, tas.describeTask(i), e.reason, tas.describeTask(e.to), why)
+			}
+		}
+	}
+
+	if start, cycle := findCycle(edges); cycle != nil {
+		msg := tas.describeTask(start)
+		for j, e := range cycle {
+			if j > 0 {
+				msg += ", which"
+			}
+			msg += fmt.Sprintf(` %s %s`, e.reason, tas.describeTask(e.to))
+		}
+		return fmt.Errorf(`[deadlock] %s`, msg)
+	}
+
+	return nil
+}
+
+// describeTask names a task in deadlock errors
+func (tas *TAS) describeTask(i int) string {
+	t := tas.tasks[i]
+	return fmt.Sprintf(`%s (%s)`, t.ID(), t.Type().Queue())
+}
+
+// findCycle returns a task that's in a cycle and the edges that lead from it back to itself, or nil if there isn't one
+func findCycle(edges [][]waitEdge) (int, []waitEdge) {
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	var (
+		color = make([]int, len(edges))
+
+		// the tasks being visited, and the edges between them
+		stack []int
+		via   []waitEdge
+
+		start int
+		cycle []waitEdge
+		visit func(i int) bool
+	)
+
+	visit = func(i int) bool {
+		color[i] = visiting
+		stack = append(stack, i)
+		for _, e := range edges[i] {
+			switch color[e.to] {
+			case visiting:
+				for p := range stack {
+					if stack[p] == e.to {
+						start = e.to
+						cycle = append(append([]waitEdge{}, via[p:]...), e)
+						return true
+					}
+				}
+			case unvisited:
+				via = append(via, e)
+				if visit(e.to) {
+					return true
+				}
+				via = via[:len(via)-1]
+			}
+		}
+		stack = stack[:len(stack)-1]
+		color[i] = visited
+		return false
+	}
+
+	for i := range edges {
+		if color[i] == unvisited && visit(i) {
+			return start, cycle
+		}
+	}
+	return 0, nil
+}
+
+// inventoryHolds checks a has_inventory condition against the verifier's state. Buildings that aren't
+// placed don't have anything
+func inventoryHolds(entity string, n int, item string, slot constants.Inventory, amount uint, exact bool) func(s *state.State) bool {
+	return func(s *state.State) bool {
+		var count uint
+		if entity == "player" {
+			count = s.Inventory[item]
+		} else {
+			b := s.GetBuilding(entity, n)
+			if b == nil {
+				return false
+			}
+			inv := b.Inventory(slot)
+			if inv == nil {
+				return false
+			}
+			count = uint(inv.Count(item))
+		}
+		if exact {
+			return count == amount
+		}
+		return count >= amount
+	}
+}
@@ -0,0 +1,29 @@
+package tas
+
+// ResourcesUsed are the resources the mod reports at the end of a run. They're all that has to be
+// mined by hand, so they're what the run is scored on
+var ResourcesUsed = []string{
+	"coal",
+	"copper-ore",
+	"iron-ore",
+	"stone",
+	"wood",
+}
+
+// ExpectedResources verifies the TAS, then simulates it and returns how much of each of the ResourcesUsed
+// the character will mine. Resources that aren't mined at all are included with a count of 0
+func (t *TAS) ExpectedResources() (map[string]uint, error) {
+	if err := t.Verify(); err != nil {
+		return nil, err
+	}
+	tl, err := t.Simulate(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]uint, len(ResourcesUsed))
+	for _, r := range ResourcesUsed {
+		out[r] = tl.Mined[r]
+	}
+	return out, nil
+}
@@ -0,0 +1,881 @@
+package tas
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/brettschalin/factorio-min-resources/building"
+	"github.com/brettschalin/factorio-min-resources/calc"
+	"github.com/brettschalin/factorio-min-resources/constants"
+	"github.com/brettschalin/factorio-min-resources/data"
+	"github.com/brettschalin/factorio-min-resources/geo"
+	"github.com/brettschalin/factorio-min-resources/shims"
+	"github.com/brettschalin/factorio-min-resources/state"
+)
+
+// Names of the queues tasks are placed in. These match the ones in the mod
+const (
+	QueueCraft  = "character_craft"
+	QueueLab    = "lab"
+	QueueAction = "character_action"
+)
+
+// the order the mod checks the queues in on every tick
+var queueOrder = []string{QueueCraft, QueueLab, QueueAction}
+
+// Queue returns the name of the queue the mod puts this type of task in
+func (t TaskType) Queue() string {
+	switch t {
+	case TaskCraft:
+		return QueueCraft
+	case TaskTech:
+		return QueueLab
+	default:
+		return QueueAction
+	}
+}
+
+// Locator returns the position of a building or resource patch. `n` is the building's
+// index as given to Build and is 0 for resources. Returning false means the location is
+// unknown and the character is assumed to already be within reach of it
+type Locator func(name string, n int) (geo.Point, bool)
+
+// TaskTiming is when a task is predicted to start and finish, in ticks since the start of the TAS
+type TaskTiming struct {
+	Task  Task
+	Queue string
+	Start int
+	End   int
+}
+
+// Timeline is the result of simulating a TAS
+type Timeline struct {
+	// one entry per task, in the same order they were added to the TAS
+	Tasks []TaskTiming
+
+	// when the last task finishes
+	Ticks int
+
+	// how much of each resource the character mined by hand
+	Mined map[string]uint
+}
+
+// Simulate replays the tasks the way the mod does: every tick the character_craft, lab
+// and character_action queues are checked in that order, at most one craft or research is
+// started per tick, and nothing is started before its prerequisites are done. Crafting,
+// smelting, research, mining and walking take as long as they would in game.
+//
+// loc is used to find buildings and resource patches so walking time can be accounted for.
+// It may be nil, in which case everything is assumed to be within reach
+func (t *TAS) Simulate(loc Locator) (*Timeline, error) {
+	sim := newSimulation(t.tasks, loc)
+	if err := sim.run(); err != nil {
+		return nil, err
+	}
+	return sim.timeline, nil
+}
+
+type simEvent struct {
+	tick int
+	seq  int
+	f    func()
+}
+
+type simEvents []*simEvent
+
+func (e simEvents) Len() int { return len(e) }
+func (e simEvents) Less(i, j int) bool {
+	if e[i].tick == e[j].tick {
+		return e[i].seq < e[j].seq
+	}
+	return e[i].tick < e[j].tick
+}
+func (e simEvents) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+func (e *simEvents) Push(x any)   { *e = append(*e, x.(*simEvent)) }
+func (e *simEvents) Pop() any {
+	old := *e
+	n := len(old)
+	x := old[n-1]
+	*e = old[:n-1]
+	return x
+}
+
+// simTask is a task that's at the front of its queue
+type simTask struct {
+	idx     int
+	task    Task
+	started bool
+
+	// for actions: whether the character has started walking to the target,
+	// when they'll be in reach, and whether the action itself has been performed
+	moving  bool
+	readyAt int
+	acted   bool
+}
+
+// simMachine tracks the crafting progress of a building
+type simMachine struct {
+	b building.Building
+
+	// products of the craft in progress. They're already in the building's output
+	// inventory but can't be seen or taken until the craft finishes
+	pending map[string]int
+	recipe  *data.Recipe
+	busy    bool
+
+	// incremented whenever the machine's crafting is interrupted, so outdated events can be ignored
+	gen int
+}
+
+type simulation struct {
+	tick   int
+	seq    int
+	events simEvents
+
+	tasks    Tasks
+	timeline *Timeline
+	loc      Locator
+	char     *data.Character
+
+	s        *state.State
+	pos      geo.Point
+	machines map[state.BuildingID]*simMachine
+
+	done    map[Task]bool
+	queues  map[string][]int
+	current map[string]*simTask
+
+	// when the handcrafting queue will be empty
+	craftingUntil int
+
+	// research in progress. The lab keeps track of how many units are done
+	research string
+
+	// incremented to stop mining
+	miningGen int
+}
+
+func newSimulation(tasks Tasks, loc Locator) *simulation {
+	sim := &simulation{
+		tasks: tasks,
+		timeline: &Timeline{
+			Tasks: make([]TaskTiming, len(tasks)),
+			Mined: map[string]uint{},
+		},
+		loc:      loc,
+		char:     data.GetCharacter(),
+		s:        state.New(),
+		machines: map[state.BuildingID]*simMachine{},
+		done:     map[Task]bool{},
+		queues:   map[string][]int{},
+		current:  map[string]*simTask{},
+	}
+
+	for i, task := range tasks {
+		q := task.Type().Queue()
+		sim.queues[q] = append(sim.queues[q], i)
+		sim.timeline.Tasks[i] = TaskTiming{Task: task, Queue: q, Start: -1, End: -1}
+	}
+
+	for _, q := range queueOrder {
+		sim.pop(q)
+	}
+
+	return sim
+}
+
+// schedule runs f after the given number of ticks
+func (sim *simulation) schedule(ticks int, f func()) {
+	sim.seq++
+	heap.Push(&sim.events, &simEvent{
+		tick: sim.tick + shims.Max(ticks, 0),
+		seq:  sim.seq,
+		f:    f,
+	})
+}
+
+// wake makes sure the simulation checks the queues again after the given number of ticks
+func (sim *simulation) wake(ticks int) {
+	sim.schedule(ticks, func() {})
+}
+
+func toTicks(seconds float64) int {
+	// tolerance keeps floating point errors from adding an extra tick
+	return int(math.Ceil(seconds*constants.TicksPerSecond - 1e-9))
+}
+
+func (sim *simulation) run() error {
+	for {
+		for len(sim.events) > 0 && sim.events[0].tick <= sim.tick {
+			heap.Pop(&sim.events).(*simEvent).f()
+		}
+
+		progressed, err := sim.step()
+		if err != nil {
+			return err
+		}
+
+		if sim.finished() {
+			sim.timeline.Ticks = sim.tick
+			return verifyPower(sim.s)
+		}
+
+		if progressed {
+			sim.tick++
+			continue
+		}
+
+		// nothing changes until the next event
+		if len(sim.events) == 0 {
+			return sim.stuck()
+		}
+		sim.tick = shims.Max(sim.events[0].tick, sim.tick)
+	}
+}
+
+func (sim *simulation) finished() bool {
+	for _, q := range queueOrder {
+		if sim.current[q] != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (sim *simulation) stuck() error {
+	if c := sim.current[QueueAction]; c != nil {
+		if t, ok := c.task.(*taskWait); ok {
+			if m := sim.machines[state.BuildingID{Name: t.Entity, N: t.N}]; m != nil {
+				if n, ok := stalled(m.b); ok {
+					return fmt.Errorf(`[wait] tick %d: %q stalls with %d recipes left (out of fuel)`, sim.tick, label(t.Entity, t.N), n)
+				}
+			}
+		}
+	}
+
+	waiting := []string{}
+	for _, q := range queueOrder {
+		if c := sim.current[q]; c != nil {
+			waiting = append(waiting, fmt.Sprintf("%s (%s)", c.task.ID(), q))
+		}
+	}
+	return fmt.Errorf(`[simulate] stuck at tick %d; nothing can progress. Waiting on %s`, sim.tick, strings.Join(waiting, ", "))
+}
+
+func (sim *simulation) pop(q string) {
+	if len(sim.queues[q]) == 0 {
+		sim.current[q] = nil
+		return
+	}
+	idx := sim.queues[q][0]
+	sim.queues[q] = sim.queues[q][1:]
+	sim.current[q] = &simTask{idx: idx, task: sim.tasks[idx]}
+}
+
+func (sim *simulation) start(c *simTask) {
+	c.started = true
+	sim.timeline.Tasks[c.idx].Start = sim.tick
+}
+
+func (sim *simulation) finish(q string) {
+	c := sim.current[q]
+	sim.done[c.task] = true
+	sim.timeline.Tasks[c.idx].End = sim.tick
+	sim.pop(q)
+}
+
+// step does everything the mod's on_tick handler does
+func (sim *simulation) step() (progressed bool, err error) {
+
+	// Handcrafting
+
+	c := sim.current[QueueCraft]
+	if c != nil && c.started && sim.tick >= sim.craftingUntil {
+		sim.finish(QueueCraft)
+		c = sim.current[QueueCraft]
+		progressed = true
+	}
+
+	if c != nil && !c.started {
+		ok, err := sim.prereqsDone(c.task)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			sim.start(c)
+			return true, sim.startCraft(c.task.(*taskCraft))
+		}
+	}
+
+	// Research
+
+	r := sim.current[QueueLab]
+	if r != nil && sim.s.TechResearched[r.task.(*taskTech).Tech] {
+		sim.finish(QueueLab)
+		r = sim.current[QueueLab]
+		progressed = true
+	}
+
+	if r != nil && !r.started {
+		ok, err := sim.prereqsDone(r.task)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			sim.start(r)
+			sim.startResearch(r.task.(*taskTech).Tech)
+			return true, nil
+		}
+	}
+
+	// Actions
+
+	a := sim.current[QueueAction]
+	if a == nil {
+		return progressed, nil
+	}
+
+	ok, err := sim.prereqsDone(a.task)
+	if err != nil || !ok {
+		return progressed, err
+	}
+
+	if !a.started {
+		sim.start(a)
+		progressed = true
+	}
+
+	done, err := sim.doAction(a)
+	if err != nil {
+		return false, err
+	}
+
+	if done {
+		sim.finish(QueueAction)
+		progressed = true
+	}
+
+	return progressed, nil
+}
+
+func (sim *simulation) prereqsDone(task Task) (bool, error) {
+	for _, p := range *task.Prerequisites() {
+		if w, ok := p.(*taskPrereqWait); ok {
+			ok, err := sim.hasInventory(w.Entity, w.N, w.Item, w.Slot, w.Amount, w.Exact)
+			if err != nil || !ok {
+				return false, err
+			}
+			continue
+		}
+		if !sim.done[p] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// same logic as has_inventory in tasks.lua
+func (sim *simulation) hasInventory(entity string, n int, item string, slot constants.Inventory, amount uint, exact bool) (bool, error) {
+	var count uint
+	if entity == "player" {
+		count = sim.s.Inventory[item]
+	} else {
+		m := sim.machines[state.BuildingID{Name: entity, N: n}]
+		if m == nil {
+			return false, fmt.Errorf(`[simulate] tick %d: building %q not placed`, sim.tick, label(entity, n))
+		}
+		count = uint(sim.visibleCount(m, slot, item))
+	}
+
+	if exact {
+		return count == amount, nil
+	}
+	return count >= amount, nil
+}
+
+func (sim *simulation) visibleCount(m *simMachine, slot constants.Inventory, item string) int {
+	inv := m.b.Inventory(slot)
+	if inv == nil {
+		return 0
+	}
+	n := inv.Count(item)
+	if slot == m.b.Slots().Output {
+		n -= m.pending[item]
+	}
+	return n
+}
+
+func (sim *simulation) startCraft(t *taskCraft) error {
+	rec := data.GetRecipe(t.Recipe)
+
+	// begin_crafting won't start recipes that aren't enabled, and the mod errors when it can't start them all
+	if err := locked(sim.s, rec); err != nil {
+		return fmt.Errorf(`[craft] tick %d: %v`, sim.tick, err)
+	}
+
+	newInv, crafts, err := calc.HandcraftQueue(sim.s.Inventory, rec, t.items(rec))
+	if err != nil {
+		return fmt.Errorf(`[craft] tick %d: cannot handcraft %q: %v`, sim.tick, t.Recipe, err)
+	}
+	for _, r := range sortRecipes(crafts) {
+		if err := locked(sim.s, r); err != nil {
+			return fmt.Errorf(`[craft] tick %d: cannot handcraft %q: %v`, sim.tick, t.Recipe, err)
+		}
+	}
+
+	// the game crafts intermediates first, then the item that was asked for
+	var intermediates float64
+	for r, n := range crafts {
+		if r != rec {
+			intermediates += r.CraftingTime() * float64(n)
+		}
+	}
+
+	products := map[string]bool{}
+	for _, p := range rec.GetResults() {
+		products[p.Name] = true
+	}
+
+	// ingredients are removed as soon as the craft is queued. Products are added as they're finished
+	for item, n := range newInv {
+		old := sim.s.Inventory[item]
+		switch {
+		case n < old:
+			sim.s.Inventory[item] = n
+		case n > old && !products[item]:
+			item, extra := item, n-old
+			sim.schedule(toTicks(intermediates), func() {
+				sim.s.Inventory[item] += extra
+			})
+		}
+	}
+
+	for i := uint(1); i <= crafts[rec]; i++ {
+		sim.schedule(toTicks(intermediates+float64(i)*rec.CraftingTime()), func() {
+			for _, p := range rec.GetResults() {
+				sim.s.Inventory[p.Name] += uint(p.Amount)
+			}
+		})
+	}
+
+	sim.craftingUntil = sim.tick + toTicks(intermediates+float64(crafts[rec])*rec.CraftingTime())
+	sim.wake(sim.craftingUntil - sim.tick)
+	return nil
+}
+
+func (sim *simulation) startResearch(tech string) {
+	sim.research = tech
+	sim.s.QueueResearch(tech)
+	for _, m := range sim.machines {
+		sim.poke(m)
+	}
+}
+
+// poke starts the next craft or research unit if the machine is idle
+func (sim *simulation) poke(m *simMachine) {
+	if m.busy {
+		return
+	}
+
+	switch b := m.b.(type) {
+	case *building.Lab:
+		sim.pokeLab(m, b)
+	case building.CraftingBuilding:
+		sim.pokeCrafter(m, b)
+	}
+}
+
+func (sim *simulation) pokeLab(m *simMachine, lab *building.Lab) {
+	if sim.research == "" {
+		return
+	}
+
+	tech := data.GetTech(sim.research)
+	if r := lab.Research(); r == nil || r.Name != tech.Name {
+		lab.SetResearch(tech)
+	}
+	// labs don't start without power. Once one has, it runs as fast as the network can keep up with
+	// everything that's working, itself included
+	if sim.s.PowerSupply() == 0 || lab.DoResearch() != building.CraftStatusRunning {
+		return
+	}
+	speed := sim.s.PowerSatisfaction()
+	sim.s.UsePower(lab, lab.UnitTime())
+
+	m.busy = true
+	gen := m.gen
+	sim.schedule(toTicks(lab.UnitTime()/speed), func() {
+		if gen != m.gen {
+			return
+		}
+		m.busy = false
+		if sim.research != tech.Name {
+			return
+		}
+		if sim.s.ResearchDone() {
+			sim.s.FinishResearch()
+			sim.research = ""
+		}
+		sim.poke(m)
+	})
+}
+
+func (sim *simulation) pokeCrafter(m *simMachine, b building.CraftingBuilding) {
+
+	outSlot := b.Slots().Output
+	before := map[string]int{}
+	if rec := b.Recipe(); rec != nil {
+		for _, p := range rec.GetResults() {
+			before[p.Name] = b.Inventory(outSlot).Count(p.Name)
+		}
+	}
+
+	electric := building.IsElectric(b)
+	if electric && sim.s.PowerSupply() == 0 {
+		return
+	}
+	sim.s.SupplyFluids(b)
+	if b.DoCraft() != building.CraftStatusRunning {
+		return
+	}
+	speed := 1.0
+	if electric {
+		speed = sim.s.PowerSatisfaction()
+	}
+
+	rec := b.Recipe()
+	sim.s.UsePower(b, rec.CraftingTime()/b.CraftingSpeed())
+	m.recipe = rec
+	m.pending = map[string]int{}
+	for _, p := range rec.GetResults() {
+		if n := b.Inventory(outSlot).Count(p.Name) - before[p.Name]; n > 0 {
+			m.pending[p.Name] = n
+		}
+	}
+
+	m.busy = true
+	gen := m.gen
+	sim.schedule(toTicks(sim.craftTime(b, rec)/speed), func() {
+		if gen != m.gen {
+			return
+		}
+		m.busy = false
+		m.pending = nil
+		m.recipe = nil
+		sim.poke(m)
+	})
+}
+
+// craftTime returns how long, in seconds, one craft takes. Fluid recipes can't go faster
+// than their fluids arrive
+func (sim *simulation) craftTime(b building.CraftingBuilding, rec *data.Recipe) float64 {
+	t := rec.CraftingTime() / b.CraftingSpeed()
+	for _, ing := range rec.Ingredients {
+		if !ing.IsFluid {
+			continue
+		}
+		if rate := sim.s.FluidRate(ing.Name); rate > 0 {
+			t = shims.Max(t, float64(ing.Amount)/rate)
+		}
+	}
+	return t
+}
+
+// reach returns how close the character needs to be to interact with the task's target
+func (sim *simulation) reach(task Task) float64 {
+	return reach(sim.char, task)
+}
+
+func reach(char *data.Character, task Task) float64 {
+	switch t := task.(type) {
+	case *taskBuild:
+		return char.BuildDistance + constants.BuildDistanceBonus
+	case *taskMine:
+		if t.Resource != "" {
+			return char.ReachResourceDistance + constants.ResourceReachDistanceBonus
+		}
+	}
+	return char.ReachDistance + constants.ReachDistanceBonus
+}
+
+// approach starts the character walking towards the target, if needed, and returns
+// whether they're close enough to reach it
+func (sim *simulation) approach(a *simTask, name string, n int) bool {
+	if !a.moving {
+		a.moving = true
+		a.readyAt = sim.tick
+
+		if sim.loc != nil {
+			if target, ok := sim.loc(name, n); ok {
+				reach := sim.reach(a.task)
+				if d := sim.pos.Distance(target); d > reach {
+					dest := target.Add(sim.pos.Sub(target).Mul(reach / d))
+					a.readyAt += sim.walkTicks(dest)
+					sim.pos = dest
+					sim.wake(a.readyAt - sim.tick)
+				}
+			}
+		}
+	}
+	return sim.tick >= a.readyAt
+}
+
+func (sim *simulation) walkTicks(dest geo.Point) int {
+	return int(math.Ceil(sim.pos.PathDistance(dest) / sim.char.RunningSpeed))
+}
+
+// doAction performs the task at the front of the character_action queue and returns whether it's done
+func (sim *simulation) doAction(a *simTask) (bool, error) {
+	switch t := a.task.(type) {
+	case *taskWalk:
+		if !a.moving {
+			a.moving = true
+			a.readyAt = sim.tick + sim.walkTicks(t.Location)
+			sim.pos = t.Location
+			sim.wake(a.readyAt - sim.tick)
+		}
+		return sim.tick >= a.readyAt, nil
+
+	case *taskWait:
+		return sim.hasInventory(t.Entity, t.N, t.Item, t.Slot, t.Amount, t.Exact)
+
+	case *taskSpeed:
+		return true, nil
+
+	case *taskLaunch:
+		silo := sim.s.Silo()
+		if silo == nil {
+			return false, fmt.Errorf(`[launch] tick %d: no rocket silo placed`, sim.tick)
+		}
+		if !sim.approach(a, silo.Name(), silo.Index()) {
+			return false, nil
+		}
+		// the last part isn't in the rocket until its craft finishes
+		if m := sim.machines[state.BuildingID{Name: silo.Name(), N: silo.Index()}]; (m != nil && m.busy) || !silo.RocketReady() {
+			return false, nil
+		}
+		silo.Launch()
+		return true, nil
+
+	case *taskBuild:
+		if !sim.approach(a, t.Entity, t.N) {
+			return false, nil
+		}
+		if !a.acted {
+			a.acted = true
+			if sim.s.Inventory[t.Entity] == 0 {
+				return false, fmt.Errorf(`[build] tick %d: no %q in inventory`, sim.tick, t.Entity)
+			}
+			sim.s.Inventory[t.Entity]--
+			if ok := sim.s.ConstructBuilding(t.Entity, t.N); !ok {
+				return false, fmt.Errorf(`[build] tick %d: could not place %q`, sim.tick, label(t.Entity, t.N))
+			}
+			if b := sim.s.GetBuilding(t.Entity, t.N); b != nil {
+				sim.machines[state.BuildingID{Name: t.Entity, N: t.N}] = &simMachine{b: b}
+			}
+		}
+		return true, nil
+
+	case *taskRecipe:
+		m := sim.machines[state.BuildingID{Name: t.Entity, N: t.N}]
+		if m == nil {
+			return false, fmt.Errorf(`[recipe] tick %d: building %q not placed`, sim.tick, label(t.Entity, t.N))
+		}
+		if !sim.approach(a, t.Entity, t.N) {
+			return false, nil
+		}
+		b, ok := m.b.(*building.Assembler)
+		if !ok {
+			return false, fmt.Errorf(`[recipe] tick %d: cannot set recipes on %q`, sim.tick, label(t.Entity, t.N))
+		}
+		if r := b.Recipe(); r == nil || r.Name != t.Recipe {
+			// changing the recipe cancels the craft in progress
+			sim.refund(m)
+			m.gen++
+		}
+		for item, n := range b.SetRecipe(data.GetRecipe(t.Recipe)) {
+			sim.s.Inventory[item] += uint(n)
+		}
+		sim.poke(m)
+		return true, nil
+
+	case *taskPut:
+		m := sim.machines[state.BuildingID{Name: t.Entity, N: t.N}]
+		if m == nil {
+			return false, fmt.Errorf(`[put] tick %d: building %q not placed`, sim.tick, label(t.Entity, t.N))
+		}
+		if !sim.approach(a, t.Entity, t.N) {
+			return false, nil
+		}
+		if sim.s.Inventory[t.Item] < t.Amount {
+			return false, fmt.Errorf(`[put] tick %d: need %d %q but only have %d`, sim.tick, t.Amount, t.Item, sim.s.Inventory[t.Item])
+		}
+		inv := m.b.Inventory(t.Slot)
+		if inv == nil {
+			return false, fmt.Errorf(`[put] tick %d: building %q does not have slot %q`, sim.tick, label(t.Entity, t.N), t.Slot)
+		}
+		if err := inv.Put(t.Item, int(t.Amount)); err != nil {
+			return false, fmt.Errorf(`[put] tick %d: %v`, sim.tick, err)
+		}
+		sim.s.Inventory[t.Item] -= t.Amount
+
+		if _, ok := m.b.(*building.Boiler); ok && t.Slot == constants.InventoryFuel {
+			// electric buildings that were stopped for lack of power can start again
+			sim.s.PayPower()
+			for _, id := range sim.s.IDs() {
+				if other := sim.machines[id]; other != nil {
+					sim.poke(other)
+				}
+			}
+		}
+		sim.poke(m)
+		return true, nil
+
+	case *taskTake:
+		m := sim.machines[state.BuildingID{Name: t.Entity, N: t.N}]
+		if m == nil {
+			return false, fmt.Errorf(`[take] tick %d: building %q not placed`, sim.tick, label(t.Entity, t.N))
+		}
+		if !sim.approach(a, t.Entity, t.N) {
+			return false, nil
+		}
+		if n := sim.visibleCount(m, t.Slot, t.Item); n < int(t.Amount) {
+			return false, fmt.Errorf(`[take] tick %d: not enough %s in %q (wanted %d but have %d)`, sim.tick, t.Item, label(t.Entity, t.N), t.Amount, n)
+		}
+		_ = m.b.Inventory(t.Slot).Take(t.Item, int(t.Amount))
+		sim.s.Inventory[t.Item] += t.Amount
+		sim.poke(m)
+		return true, nil
+
+	case *taskMine:
+		if t.Resource != "" {
+			return sim.mineResource(a, t)
+		}
+		return sim.mineEntity(a, t)
+	}
+
+	return false, fmt.Errorf(`[simulate] cannot handle task type %q`, a.task.Type())
+}
+
+// Mining is done when the player has at least the requested amount in their inventory,
+// same as the mod's check. Anything already in the inventory counts towards it
+func (sim *simulation) mineResource(a *simTask, t *taskMine) (bool, error) {
+	if !sim.approach(a, t.Resource, 0) {
+		return false, nil
+	}
+
+	if !a.acted {
+		a.acted = true
+
+		res := data.GetResource(t.Resource)
+		if res == nil {
+			return false, fmt.Errorf(`[mine] tick %d: unknown resource %q`, sim.tick, t.Resource)
+		}
+
+		sim.miningGen++
+		gen := sim.miningGen
+		perItem := res.Minable.MiningTime / sim.char.MiningSpeed
+
+		var mineOne func(n int)
+		mineOne = func(n int) {
+			sim.schedule(toTicks(float64(n)*perItem)-toTicks(float64(n-1)*perItem), func() {
+				if gen != sim.miningGen {
+					return
+				}
+				sim.s.Inventory[t.Resource]++
+				sim.timeline.Mined[t.Resource]++
+				mineOne(n + 1)
+			})
+		}
+		mineOne(1)
+	}
+
+	if sim.s.Inventory[t.Resource] >= t.Amount {
+		sim.miningGen++
+		return true, nil
+	}
+	return false, nil
+}
+
+func (sim *simulation) mineEntity(a *simTask, t *taskMine) (bool, error) {
+	id := state.BuildingID{Name: t.Entity, N: t.N}
+	if !a.acted {
+		if !sim.s.IsPlaced(t.Entity, t.N) {
+			return false, fmt.Errorf(`[mine] tick %d: building %q not placed`, sim.tick, label(t.Entity, t.N))
+		}
+	}
+
+	if !sim.approach(a, t.Entity, t.N) {
+		return false, nil
+	}
+
+	if !a.acted {
+		a.acted = true
+
+		var miningTime float64
+		if m, ok := sim.machines[id]; ok {
+			miningTime = minable(m.b).MiningTime
+		}
+
+		sim.miningGen++
+		gen := sim.miningGen
+		sim.schedule(toTicks(miningTime/sim.char.MiningSpeed), func() {
+			if gen != sim.miningGen {
+				return
+			}
+			if m, ok := sim.machines[id]; ok {
+				sim.refund(m)
+				m.gen++
+			}
+			delete(sim.machines, id)
+			sim.s.MineBuilding(t.Entity, t.N)
+			sim.s.Inventory[t.Entity]++
+		})
+	}
+
+	// same as the mod, which waits for the building to be gone rather than checking the inventory
+	if !sim.s.IsPlaced(t.Entity, t.N) {
+		sim.miningGen++
+		return true, nil
+	}
+	return false, nil
+}
+
+// refund cancels the craft in progress. Like the game, the player gets its ingredients back
+// instead of the products
+func (sim *simulation) refund(m *simMachine) {
+	b, ok := m.b.(building.CraftingBuilding)
+	if !ok || !m.busy || m.recipe == nil {
+		return
+	}
+
+	for item, n := range m.pending {
+		_ = b.Inventory(b.Slots().Output).Take(item, n)
+	}
+	for _, ing := range m.recipe.Ingredients {
+		if !ing.IsFluid {
+			sim.s.Inventory[ing.Name] += uint(ing.Amount)
+		}
+	}
+	m.busy = false
+	m.pending = nil
+	m.recipe = nil
+}
+
+func minable(b building.Building) data.Minable {
+	switch b := b.(type) {
+	case *building.Assembler:
+		return b.Entity.Minable
+	case *building.Furnace:
+		return b.Entity.Minable
+	case *building.Boiler:
+		return b.Entity.Minable
+	case *building.Lab:
+		return b.Entity.Minable
+	case *building.RocketSilo:
+		return b.Entity.Minable
+	}
+	return data.Minable{}
+}
@@ -2,194 +2,541 @@ package tas
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/brettschalin/factorio-min-resources/building"
 	"github.com/brettschalin/factorio-min-resources/calc"
 	"github.com/brettschalin/factorio-min-resources/constants"
 	"github.com/brettschalin/factorio-min-resources/data"
+	"github.com/brettschalin/factorio-min-resources/shims/slices"
 	"github.com/brettschalin/factorio-min-resources/state"
 )
 
 type TAS struct {
 	tasks Tasks
+
+	// how many times each generated ID has been used, so numbering doesn't have to start over every time
+	idCount map[string]int
+
+	// the first `checked` tasks have been verified. `state` is what they leave behind, and `seen` and
+	// `ids` are the tasks and IDs they use, so Add only has to verify the tasks after them
+	checked int
+	state   *state.State
+	seen    map[Task]bool
+	ids     map[string]bool
 }
 
+// Add verifies the tasks, picking up from where the last Add left off, and adds them to the TAS. If any of
+// them fail the TAS is left as it was before the call
 func (t *TAS) Add(tasks ...Task) error {
+	n := len(t.tasks)
 	t.tasks = append(t.tasks, tasks...)
-	return t.Verify()
+	if err := t.check(); err != nil {
+		t.tasks = t.tasks[:n]
+		return err
+	}
+	return nil
 }
 
+// Verify checks every task again from the start, that everything the electric buildings used was paid for,
+// and that the mod's queues can't get stuck waiting on each other
 func (t *TAS) Verify() error {
+	t.reset()
+	if err := t.check(); err != nil {
+		return err
+	}
+	if err := verifyPower(t.state); err != nil {
+		return err
+	}
+	return t.verifyQueues()
+}
 
-	var err error
+// State returns a copy of the state after the last verified task
+func (t *TAS) State() *state.State {
+	if t.state == nil {
+		return state.New()
+	}
+	return t.state.Copy()
+}
 
-	if err = t.verifyPrereqs(); err != nil {
-		return err
+// StateAt returns the state right after the ith task. -1 is the state before any of them
+func (t *TAS) StateAt(i int) (*state.State, error) {
+	if i < -1 || i >= len(t.tasks) {
+		return nil, fmt.Errorf(`task %d out of range (have %d)`, i, len(t.tasks))
+	}
+	if i == t.checked-1 && t.state != nil {
+		return t.state.Copy(), nil
 	}
 
 	s := state.New()
-	if err = t.verifyState(s); err != nil {
-		return err
+	for _, task := range t.tasks[:i+1] {
+		if err := verifyTask(s, task); err != nil {
+			return nil, err
+		}
 	}
+	return s, nil
+}
 
-	return nil
+// Len returns how many tasks there are
+func (t *TAS) Len() int {
+	return len(t.tasks)
 }
 
-func (tas *TAS) verifyPrereqs() error {
+func (t *TAS) reset() {
+	t.checked = 0
+	t.state = state.New()
+	t.seen = map[Task]bool{}
+	t.ids = map[string]bool{}
+}
 
-	visited := map[string]bool{}
-	for i, task := range tas.tasks {
+// check verifies the tasks that haven't been yet and moves the checkpoint past them.
+// Nothing changes unless they all pass
+func (t *TAS) check() error {
+
+	if t.state == nil {
+		t.reset()
+	}
+	tasks := t.tasks[t.checked:]
+
+	ids, err := explicitIDs(tasks, t.ids)
+	if err != nil {
+		return err
+	}
+
+	added := map[Task]bool{}
+	for i, task := range tasks {
 		for _, p := range *task.Prerequisites() {
-			if !visited[p.ID()] && p.Type() != taskPrereq {
-				return fmt.Errorf(`task %d references unknown prerequisite %s`, i, p.ID())
+			if !t.seen[p] && !added[p] && p.Type() != taskPrereq {
+				return fmt.Errorf(`task %d references unknown prerequisite %s`, t.checked+i, p.ID())
+			}
+		}
+		added[task] = true
+	}
+
+	// research only finishes some time after the packs go in, so verifying in order isn't enough for crafts
+	// that need it. They also have to wait for the tech
+	var deps [][]int
+	s := t.state.Copy()
+	for i, task := range tasks {
+		unlocked := lockedRecipes(s, task)
+		if err = verifyTask(s, task); err != nil {
+			return err
+		}
+		if len(unlocked) > 0 && deps == nil {
+			deps = t.dependencies()
+		}
+		for _, r := range unlocked {
+			if tech := data.UnlockedBy(r.Name); !t.researchedFirst(deps, t.checked+i, tech) {
+				return fmt.Errorf(`[craft] recipe %q can be crafted before %q is researched (wait for the tech first)`, r.Name, tech)
 			}
 		}
-		visited[task.ID()] = true
 	}
 
+	for id := range ids {
+		t.ids[id] = true
+	}
+	t.nameTasks(tasks, t.ids)
+	for task := range added {
+		t.seen[task] = true
+	}
+	t.state = s
+	t.checked = len(t.tasks)
 	return nil
 }
 
-func (tas *TAS) verifyState(s *state.State) error {
-	for _, task := range tas.tasks {
-		switch t := task.(type) {
-		case *taskCraft:
-
-			newInv, err := calc.Handcraft(s.Inventory, data.GetRecipe(t.Recipe), t.Amount)
-			if err != nil {
-				return fmt.Errorf(`[craft] cannot handcraft %q: %v`, t.Recipe, err)
-			}
+// explicitIDs checks the IDs the tasks were given against each other and the `used` ones, and returns them
+func explicitIDs(tasks Tasks, used map[string]bool) (map[string]bool, error) {
+	ids := map[string]bool{}
+	for _, task := range tasks {
+		id := task.(interface{ base() *baseTask }).base().id
+		if id == "" {
+			continue
+		}
+		if !validID.MatchString(id) {
+			return nil, fmt.Errorf(`invalid task ID %q`, id)
+		}
+		if used[id] || ids[id] {
+			return nil, fmt.Errorf(`duplicate task ID %q`, id)
+		}
+		ids[id] = true
+	}
+	return ids, nil
+}
 
-			s.Inventory = newInv
-		case *taskTech:
-			if s.TechResearched[t.Tech] {
-				return fmt.Errorf(`[tech] %q already researched`, t.Tech)
-			}
-			tech := data.GetTech(t.Tech)
-			for _, p := range tech.Prerequisites {
-				if !s.TechResearched[p] {
-					return fmt.Errorf(`[tech] %q: prerequisite %q not yet researched`, t.Tech, p)
-				}
-			}
-			s.TechResearched[t.Tech] = true
+// nameTasks gives every task that doesn't have an ID one made from what it does, like
+// craft_iron_gear_wheel, skipping any that are `used` and adding them to it. Repeats are numbered from 2,
+// so adding or removing a task only renumbers later tasks that do the same thing and the rest of tasks.lua
+// stays the same
+func (tas *TAS) nameTasks(tasks Tasks, used map[string]bool) {
 
-		case *taskRecipe:
-			if !s.Buildings[t.Entity] {
-				return fmt.Errorf(`[recipe] building %q not placed`, t.Entity)
-			}
+	if tas.idCount == nil {
+		tas.idCount = map[string]int{}
+	}
 
-			if b, ok := s.GetBuilding(t.Entity).(*building.Assembler); ok {
-				inv := b.SetRecipe(data.GetRecipe(t.Recipe))
-				for ing, n := range inv {
-					s.Inventory[ing] += uint(n)
-				}
-			} else {
-				return fmt.Errorf(`[recipe] cannot set recipes on %q`, t.Entity)
+	for _, task := range tasks {
+		b := task.(interface{ base() *baseTask }).base()
+		if b.id != "" {
+			continue
+		}
+		key := task.ID()
+		for {
+			tas.idCount[key]++
+			id := key
+			if n := tas.idCount[key]; n > 1 {
+				id = fmt.Sprintf("%s_%d", key, n)
 			}
-		case *taskBuild:
-			if s.Inventory[t.Entity] == 0 {
-				return fmt.Errorf(`[build] no %q in inventory`, t.Entity)
+			if !used[id] {
+				b.id = id
+				used[id] = true
+				break
 			}
+		}
+	}
+}
 
-			s.Inventory[t.Entity]--
-			s.Buildings[t.Entity] = true
-			if ok := s.ConstructBuilding(t.Entity); !ok {
-				return fmt.Errorf(`[build] could not place %q`, t.Entity)
+// dependencies returns the tasks each task waits for before it can start: the one in front of it in its
+// queue, and its prerequisites
+func (t *TAS) dependencies() [][]int {
+	deps := make([][]int, len(t.tasks))
+	index := make(map[Task]int, len(t.tasks))
+	last := map[string]int{}
+	for i, task := range t.tasks {
+		if j, ok := last[task.Type().Queue()]; ok {
+			deps[i] = append(deps[i], j)
+		}
+		last[task.Type().Queue()] = i
+		for _, p := range *task.Prerequisites() {
+			if j, ok := index[p]; ok {
+				deps[i] = append(deps[i], j)
 			}
+		}
+		index[task] = i
+	}
+	return deps
+}
 
-		case *taskMine:
-			if t.Resource != "" {
-				s.Inventory[t.Resource] += t.Amount
-			} else if t.Entity != "" {
-				if !s.Buildings[t.Entity] {
-					return fmt.Errorf(`[mine] building %q not placed`, t.Entity)
-				}
-				delete(s.Buildings, t.Entity)
-				s.Inventory[t.Entity]++
-
-				// TODO: inventory transfers like in the *taskRecipe case
-				if ok := s.MineBuilding(t.Entity); !ok {
-					return fmt.Errorf(`[mine] building %q not placed`, t.Entity)
-				}
+// researchedFirst returns whether the ith task can't start until the tech is researched, which it can't if
+// it waits for the task that queues the tech, directly or through the tasks it waits for. A tech that isn't
+// queued was researched before the TAS started
+func (t *TAS) researchedFirst(deps [][]int, i int, tech string) bool {
+	queued := -1
+	for j, task := range t.tasks[:i] {
+		if tt, ok := task.(*taskTech); ok && tt.Tech == tech {
+			queued = j
+		}
+	}
+	if queued < 0 {
+		return true
+	}
 
+	// tasks only wait for ones before them, so there's no need to look past the tech
+	seen := map[int]bool{}
+	var visit func(j int) bool
+	visit = func(j int) bool {
+		if j == queued {
+			return true
+		}
+		if j < queued || seen[j] {
+			return false
+		}
+		seen[j] = true
+		for _, d := range deps[j] {
+			if visit(d) {
+				return true
 			}
+		}
+		return false
+	}
+	return visit(i)
+}
 
-		case *taskTake:
+// verifyTask checks that the task can be done in state `s` and updates it to what it'd be afterwards
+func verifyTask(s *state.State, task Task) error {
+	switch t := task.(type) {
+	case *taskCraft:
 
-			b := s.GetBuilding(t.Entity)
-			if b == nil {
-				return fmt.Errorf(`[take] building %q not placed`, t.Entity)
+		rec := data.GetRecipe(t.Recipe)
+		if err := locked(s, rec); err != nil {
+			return fmt.Errorf(`[craft] %v`, err)
+		}
+		newInv, crafts, err := calc.HandcraftQueue(s.Inventory, rec, t.items(rec))
+		if err != nil {
+			return fmt.Errorf(`[craft] cannot handcraft %q: %v`, t.Recipe, err)
+		}
+		// intermediates are only crafted for you if you could craft them yourself
+		for _, r := range sortRecipes(crafts) {
+			if err := locked(s, r); err != nil {
+				return fmt.Errorf(`[craft] cannot handcraft %q: %v`, t.Recipe, err)
 			}
+		}
 
-			inv := b.Inventory(t.Slot)
-			if inv == nil {
-				return fmt.Errorf(`[take] building %q does not have slot %q`, t.Entity, t.Slot)
+		s.Inventory = newInv
+	case *taskTech:
+		if s.TechResearched[t.Tech] || slices.Contains(s.Research, t.Tech) {
+			return fmt.Errorf(`[tech] %q already researched`, t.Tech)
+		}
+		tech := data.GetTech(t.Tech)
+		// the lab queue researches one tech at a time, so anything queued earlier will be done first
+		for _, p := range tech.Prerequisites {
+			if !s.TechResearched[p] && !slices.Contains(s.Research, p) {
+				return fmt.Errorf(`[tech] %q: prerequisite %q not yet researched`, t.Tech, p)
 			}
+		}
+		s.QueueResearch(t.Tech)
+		s.DoResearch()
+
+	case *taskRecipe:
+		if !s.IsPlaced(t.Entity, t.N) {
+			return fmt.Errorf(`[recipe] building %q not placed`, label(t.Entity, t.N))
+		}
 
-			err := inv.Take(t.Item, int(t.Amount))
-			if err != nil {
-				return fmt.Errorf(`[take] not enough %s in output slot of %q (wanted %d)`, t.Item, t.Entity, t.Amount)
+		if b, ok := s.GetBuilding(t.Entity, t.N).(*building.Assembler); ok {
+			rec := data.GetRecipe(t.Recipe)
+			if err := locked(s, rec); err != nil {
+				return fmt.Errorf(`[recipe] %v`, err)
 			}
+			inv := b.SetRecipe(rec)
+			for ing, n := range inv {
+				s.Inventory[ing] += uint(n)
+			}
+		} else {
+			return fmt.Errorf(`[recipe] cannot set recipes on %q`, label(t.Entity, t.N))
+		}
+	case *taskBuild:
+		if s.Inventory[t.Entity] == 0 {
+			return fmt.Errorf(`[build] no %q in inventory`, t.Entity)
+		}
 
-			if m, ok := b.(building.CraftingBuilding); ok {
-				for {
-					status := m.DoCraft()
-					if status != building.CraftStatusRunning {
-						break
-					}
-				}
+		s.Inventory[t.Entity]--
+		if ok := s.ConstructBuilding(t.Entity, t.N); !ok {
+			return fmt.Errorf(`[build] could not place %q`, label(t.Entity, t.N))
+		}
+		s.DoResearch()
+
+	case *taskMine:
+		if t.Resource != "" {
+			s.Inventory[t.Resource] += t.Amount
+		} else if t.Entity != "" {
+			if ok := s.MineBuilding(t.Entity, t.N); !ok {
+				return fmt.Errorf(`[mine] building %q not placed`, label(t.Entity, t.N))
 			}
+			s.Inventory[t.Entity]++
 
-			s.Inventory[t.Item] += t.Amount
+		}
 
-		case *taskPut:
+	case *taskLaunch:
+		silo := s.Silo()
+		if silo == nil {
+			return fmt.Errorf(`[launch] no rocket silo placed`)
+		}
+		if !silo.Launch() {
+			return fmt.Errorf(`[launch] rocket not finished: %d of %d parts made`, silo.Parts(), silo.PartsRequired())
+		}
 
-			b := s.GetBuilding(t.Entity)
-			if b == nil {
-				return fmt.Errorf(`[put] building %q not placed`, t.Entity)
-			}
+	case *taskWait:
+		b := s.GetBuilding(t.Entity, t.N)
+		if b == nil {
+			break
+		}
+		inv := b.Inventory(t.Slot)
+		if inv == nil || inv.Count(t.Item) >= int(t.Amount) {
+			break
+		}
+		if n, ok := stalled(b); ok {
+			return fmt.Errorf(`[wait] %q stalls with %d recipes left (out of fuel)`, label(t.Entity, t.N), n)
+		}
+		if fluid, ok := starved(s, b); ok {
+			return fmt.Errorf(`[wait] %q stalls waiting for %q (nothing supplies it)`, label(t.Entity, t.N), fluid)
+		}
 
-			if s.Inventory[t.Item] < t.Amount {
-				return fmt.Errorf(`[put] need %d %q but only have %d`, t.Amount, t.Item, s.Inventory[t.Item])
-			}
+	case *taskTake:
 
-			inv := b.Inventory(t.Slot)
-			if inv == nil {
-				return fmt.Errorf(`[put] building %q does not have slot %q`, t.Entity, t.Slot)
-			}
+		b := s.GetBuilding(t.Entity, t.N)
+		if b == nil {
+			return fmt.Errorf(`[take] building %q not placed`, label(t.Entity, t.N))
+		}
 
-			err := inv.Put(t.Item, int(t.Amount))
-			if err != nil {
-				return fmt.Errorf(`[put] cannot put %s in input slot of %q (wanted %d)`, t.Item, t.Entity, t.Amount)
-			}
+		inv := b.Inventory(t.Slot)
+		if inv == nil {
+			return fmt.Errorf(`[take] building %q does not have slot %q`, label(t.Entity, t.N), t.Slot)
+		}
 
-			if m, ok := b.(building.CraftingBuilding); ok {
-				for {
-					status := m.DoCraft()
-					if status != building.CraftStatusRunning {
-						break
-					}
-				}
+		err := inv.Take(t.Item, int(t.Amount))
+		if err != nil {
+			if n, ok := stalled(b); ok {
+				return fmt.Errorf(`[take] %q stalls with %d recipes left (out of fuel)`, label(t.Entity, t.N), n)
 			}
-			s.Inventory[t.Item] -= t.Amount
-
-			// TODO: in the future we should properly track fuel usage. For now just assume that it's correct and empty the inventory
-			if t.Slot == constants.InventoryFuel {
-				_ = inv.Take(t.Item, inv.Count(t.Item))
+			if fluid, ok := starved(s, b); ok {
+				return fmt.Errorf(`[take] %q stalls waiting for %q (nothing supplies it)`, label(t.Entity, t.N), fluid)
 			}
+			return fmt.Errorf(`[take] not enough %s in output slot of %q (wanted %d)`, t.Item, label(t.Entity, t.N), t.Amount)
+		}
 
-			// TODO: in the future we should also track science pack usage. Assume that's correct as well
-			if t.Slot == constants.InventoryLabInput {
-				_ = inv.Take(t.Item, inv.Count(t.Item))
-			}
+		if m, ok := b.(building.CraftingBuilding); ok {
+			runMachine(s, m)
+		}
+
+		s.Inventory[t.Item] += t.Amount
+
+	case *taskPut:
 
+		b := s.GetBuilding(t.Entity, t.N)
+		if b == nil {
+			return fmt.Errorf(`[put] building %q not placed`, label(t.Entity, t.N))
 		}
-		for k, v := range s.Inventory {
-			if v == 0 {
-				delete(s.Inventory, k)
-			}
+
+		if s.Inventory[t.Item] < t.Amount {
+			return fmt.Errorf(`[put] need %d %q but only have %d`, t.Amount, t.Item, s.Inventory[t.Item])
+		}
+
+		inv := b.Inventory(t.Slot)
+		if inv == nil {
+			return fmt.Errorf(`[put] building %q does not have slot %q`, label(t.Entity, t.N), t.Slot)
+		}
+
+		err := inv.Put(t.Item, int(t.Amount))
+		if err != nil {
+			return fmt.Errorf(`[put] cannot put %s in input slot of %q (wanted %d)`, t.Item, label(t.Entity, t.N), t.Amount)
+		}
+
+		if m, ok := b.(building.CraftingBuilding); ok {
+			runMachine(s, m)
+		}
+		s.Inventory[t.Item] -= t.Amount
+
+		// crafting buildings burn their fuel in DoCraft. Boilers burn theirs for whatever the
+		// electric buildings used while they were out
+		if _, ok := b.(*building.Boiler); ok && t.Slot == constants.InventoryFuel {
+			s.PayPower()
+		}
+
+		if _, ok := b.(*building.Lab); ok {
+			s.DoResearch()
 		}
+
+	}
+	for k, v := range s.Inventory {
+		if v == 0 {
+			delete(s.Inventory, k)
+		}
+	}
+
+	return nil
+}
+
+// verifyResearch checks that everything queued in the lab was actually researched. This
+// only makes sense once the TAS is complete, since techs are usually queued before their packs are made
+func verifyResearch(s *state.State) error {
+	if len(s.Research) == 0 {
+		return nil
 	}
 
+	tech := data.GetTech(s.Research[0])
+	return fmt.Errorf(`[tech] %q not finished: %g of %d units researched`, tech.Name, s.ResearchProgress(), tech.Unit.Count)
+}
+
+// verifyPower checks that the boilers burned fuel for all the power the electric buildings used. Like
+// verifyResearch this only makes sense once the TAS is complete, since fuel can go in after the buildings run
+func verifyPower(s *state.State) error {
+	if owed := s.PowerOwed(); owed > 0 {
+		return fmt.Errorf(`[power] %gMJ used by electric buildings was never generated (not enough power or boiler fuel)`, owed/1e6)
+	}
 	return nil
 }
+
+// locked returns an error if the recipe hasn't been unlocked yet
+func locked(s *state.State, recipe *data.Recipe) error {
+	if recipe == nil || s.RecipeUnlocked(recipe) {
+		return nil
+	}
+	if tech := data.UnlockedBy(recipe.Name); tech != "" {
+		return fmt.Errorf(`recipe %q not unlocked (needs tech %q)`, recipe.Name, tech)
+	}
+	return fmt.Errorf(`recipe %q not unlocked (no tech unlocks it)`, recipe.Name)
+}
+
+// lockedRecipes returns the recipes a craft uses, including intermediates, that aren't enabled from the start
+func lockedRecipes(s *state.State, task Task) []*data.Recipe {
+	t, ok := task.(*taskCraft)
+	if !ok {
+		return nil
+	}
+	rec := data.GetRecipe(t.Recipe)
+	_, crafts, err := calc.HandcraftQueue(s.Inventory, rec, t.items(rec))
+	if err != nil {
+		return nil
+	}
+	recipes := []*data.Recipe{}
+	for _, r := range sortRecipes(crafts) {
+		if !r.IsEnabled() {
+			recipes = append(recipes, r)
+		}
+	}
+	return recipes
+}
+
+// sortRecipes returns the recipes a craft uses sorted by name, so errors about them don't change from run to run
+func sortRecipes(crafts map[*data.Recipe]uint) []*data.Recipe {
+	recipes := make([]*data.Recipe, 0, len(crafts))
+	for r := range crafts {
+		recipes = append(recipes, r)
+	}
+	sort.Slice(recipes, func(i, j int) bool { return recipes[i].Name < recipes[j].Name })
+	return recipes
+}
+
+// label names a building in error messages. Indexed buildings get their index appended
+func label(entity string, n int) string {
+	if n != 0 {
+		return fmt.Sprintf("%s[%d]", entity, n)
+	}
+	return entity
+}
+
+// runMachine crafts until the machine stops, pumping in fluids before each craft and drawing
+// power for it after. Verifying doesn't track time, so a network that's short doesn't stop anything
+func runMachine(s *state.State, m building.CraftingBuilding) {
+	for {
+		s.SupplyFluids(m)
+		if m.DoCraft() != building.CraftStatusRunning {
+			return
+		}
+		s.UsePower(m, m.Recipe().CraftingTime()/m.CraftingSpeed())
+	}
+}
+
+// starved reports whether b is waiting on a fluid that nothing on the map supplies and, if so, which
+func starved(s *state.State, b building.Building) (string, bool) {
+	m, ok := b.(building.CraftingBuilding)
+	if !ok || m.Status() != building.CraftStatusWaitingForInput {
+		return "", false
+	}
+	if missing := s.SupplyFluids(m); len(missing) > 0 {
+		return missing[0], true
+	}
+	return "", false
+}
+
+// stalled reports whether b is a crafting building that ran out of fuel and, if so, how many
+// more recipes it could make with the ingredients it has
+func stalled(b building.Building) (int, bool) {
+	m, ok := b.(building.CraftingBuilding)
+	if !ok || m.Status() != building.CraftStatusNoFuel || m.Recipe() == nil {
+		return 0, false
+	}
+
+	left := -1
+	in := m.Inventory(m.Slots().Input)
+	for _, ing := range m.Recipe().Ingredients {
+		if ing.IsFluid {
+			continue
+		}
+		if n := in.Count(ing.Name) / ing.Amount; left < 0 || n < left {
+			left = n
+		}
+	}
+	if left < 0 {
+		left = 0
+	}
+	return left, true
+}
@@ -1,12 +1,16 @@
 package tas
 
 import (
+	"bytes"
 	"fmt"
+	"math"
+	"strings"
 	"testing"
 
 	"github.com/brettschalin/factorio-min-resources/building"
 	"github.com/brettschalin/factorio-min-resources/constants"
 	"github.com/brettschalin/factorio-min-resources/data"
+	"github.com/brettschalin/factorio-min-resources/geo"
 	"github.com/brettschalin/factorio-min-resources/state"
 	"github.com/r3labs/diff/v3"
 )
@@ -14,9 +18,9 @@ import (
 func TestVerifyPrereqs(t *testing.T) {
 
 	var (
-		t1 = Craft("iron-gear-wheel", 20)
+		t1 = Craft("iron-gear-wheel", 4)
 		t2 = Tech("automation")
-		t3 = Recipe("assembling-machine-2", "engine-unit")
+		t3 = Recipe("assembling-machine-2", 0, "engine-unit")
 	)
 
 	t2.Prerequisites().Add(t1)
@@ -48,7 +52,7 @@ func TestVerifyPrereqs(t *testing.T) {
 		},
 	} {
 		t.Run(test.name, func(tt *testing.T) {
-			err := test.input.verifyPrereqs()
+			err := test.input.Verify()
 			if d, _ := diff.Diff(err, test.err); len(d) > 0 {
 				tt.Fatal(d)
 			}
@@ -56,6 +60,14 @@ func TestVerifyPrereqs(t *testing.T) {
 	}
 }
 
+// addFrom is TAS.Add for a run that starts in state s instead of a new game
+func addFrom(s *state.State, tasks ...Task) (*TAS, error) {
+	tas := &TAS{}
+	tas.reset()
+	tas.state = s
+	return tas, tas.Add(tasks...)
+}
+
 func TestVerifyState(t *testing.T) {
 
 	for _, test := range []struct {
@@ -68,30 +80,37 @@ func TestVerifyState(t *testing.T) {
 			name: "valid",
 			input: TAS{
 				tasks: Tasks{
+					Build("solar-panel", 0),
 					Build("lab", 0),
 					Craft("automation-science-pack", 10),
 					Tech("automation"),
-					Transfer("lab", "automation-science-pack", constants.InventoryLabInput, 10, false),
+					Transfer("lab", 0, "automation-science-pack", constants.InventoryLabInput, 10, false),
 				},
 			},
 			inState: &state.State{
 				Inventory: map[string]uint{
+					"solar-panel":  1,
 					"lab":          1,
 					"iron-plate":   20,
 					"copper-plate": 15,
 				},
 				TechResearched: map[string]bool{},
-				Buildings:      map[string]bool{},
+				Buildings:      map[state.BuildingID]building.Building{},
 			},
 			outState: &state.State{
 				TechResearched: map[string]bool{
 					"automation": true,
 				},
+				Unlocked: map[string]bool{
+					"assembling-machine-1": true,
+				},
 				Inventory: map[string]uint{
 					"copper-plate": 5,
 				},
-				Buildings: map[string]bool{"lab": true},
-				Lab:       building.NewLab(data.GetLab("lab")),
+				Buildings: map[state.BuildingID]building.Building{
+					{Name: "solar-panel"}: building.New("solar-panel", 0),
+					{Name: "lab"}:         building.New("lab", 0),
+				},
 			},
 		}, {
 			name: "unresearched technology",
@@ -106,13 +125,1379 @@ func TestVerifyState(t *testing.T) {
 		},
 	} {
 		t.Run(test.name, func(tt *testing.T) {
-			err := test.input.verifyState(test.inState)
+			tas, err := addFrom(test.inState, test.input.tasks...)
 			if d, _ := diff.Diff(err, test.err); len(d) > 0 {
 				tt.Fatal(d)
 			}
-			if d, _ := diff.Diff(test.inState, test.outState); len(d) > 0 {
+			if d, _ := diff.Diff(tas.state, test.outState); len(d) > 0 {
+				tt.Fatal(d)
+			}
+		})
+	}
+}
+
+func TestVerifyUnlocks(t *testing.T) {
+
+	tech := Tech("automation")
+	research := Tasks{
+		Build("lab", 0),
+		Craft("automation-science-pack", 10),
+		tech,
+		Transfer("lab", 0, "automation-science-pack", constants.InventoryLabInput, 10, false),
+	}
+	afterTech := Craft("assembling-machine-1", 1)
+	afterTech.Prerequisites().Add(tech)
+
+	for _, test := range []struct {
+		name  string
+		tasks Tasks
+		err   error
+	}{
+		{
+			name:  "craft before research",
+			tasks: Tasks{Craft("assembling-machine-1", 1)},
+			err:   fmt.Errorf(`[craft] recipe %q not unlocked (needs tech %q)`, "assembling-machine-1", "automation"),
+		},
+		{
+			name:  "craft after research",
+			tasks: append(append(Tasks{}, research...), afterTech),
+		},
+		{
+			// the packs are in, but the craft could start before the lab's done with them
+			name:  "craft right after the packs go in",
+			tasks: append(append(Tasks{}, research...), Craft("assembling-machine-1", 1)),
+			err:   fmt.Errorf(`[craft] recipe %q can be crafted before %q is researched (wait for the tech first)`, "assembling-machine-1", "automation"),
+		},
+		{
+			// queued isn't the same as researched
+			name:  "craft before the packs go in",
+			tasks: append(append(Tasks{}, research[:3]...), Craft("assembling-machine-1", 1)),
+			err:   fmt.Errorf(`[craft] recipe %q not unlocked (needs tech %q)`, "assembling-machine-1", "automation"),
+		},
+		{
+			name:  "set recipe",
+			tasks: Tasks{Build("assembling-machine-1", 0), Recipe("assembling-machine-1", 0, "logistic-science-pack")},
+			err:   fmt.Errorf(`[recipe] recipe %q not unlocked (needs tech %q)`, "logistic-science-pack", "logistic-science-pack"),
+		},
+	} {
+		t.Run(test.name, func(tt *testing.T) {
+			s := &state.State{
+				Inventory: map[string]uint{
+					"lab":                  1,
+					"assembling-machine-1": 1,
+					"iron-plate":           100,
+					"copper-plate":         100,
+				},
+				TechResearched: map[string]bool{},
+				Buildings:      map[state.BuildingID]building.Building{},
+			}
+			_, err := addFrom(s, test.tasks...)
+			if d, _ := diff.Diff(err, test.err); len(d) > 0 {
+				tt.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestVerifyCraftAmount(t *testing.T) {
+
+	// like begin_crafting, the amount is how many times the recipe is crafted. Copper cable makes 2 a craft
+	s := &state.State{
+		Inventory:      map[string]uint{"copper-plate": 5},
+		TechResearched: map[string]bool{},
+		Buildings:      map[state.BuildingID]building.Building{},
+	}
+	tas, err := addFrom(s, Craft("copper-cable", 5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := tas.State().Inventory["copper-cable"]; n != 10 {
+		t.Fatalf("expected 10 copper cable, got %d", n)
+	}
+}
+
+func TestVerifyFuel(t *testing.T) {
+
+	for _, test := range []struct {
+		name string
+		fuel uint
+		err  error
+	}{
+		{
+			name: "enough fuel",
+			fuel: 2,
+		},
+		{
+			// one coal smelts 13 ore
+			name: "furnace stalls",
+			fuel: 1,
+			err:  fmt.Errorf(`[wait] %q stalls with %d recipes left (out of fuel)`, "stone-furnace", 7),
+		},
+	} {
+		t.Run(test.name, func(tt *testing.T) {
+			furnace := building.NewFurnace(data.GetFurnace("stone-furnace"))
+			smelt, _ := MineAndSmelt("iron-ore", furnace, 20, constants.PreferredFuel)
+
+			tasks := Tasks{Build("stone-furnace", 0)}
+			tasks.Add(FuelMachine(constants.PreferredFuel, "stone-furnace", 0, test.fuel)...)
+			tasks.Add(smelt...)
+
+			s := &state.State{
+				Inventory:      map[string]uint{"stone-furnace": 1},
+				TechResearched: map[string]bool{},
+				Buildings:      map[state.BuildingID]building.Building{},
+			}
+			_, err := addFrom(s, tasks...)
+			if d, _ := diff.Diff(err, test.err); len(d) > 0 {
 				tt.Fatal(d)
 			}
 		})
 	}
 }
+
+func TestVerifyResearch(t *testing.T) {
+
+	for _, test := range []struct {
+		name  string
+		packs uint
+		err   error
+	}{
+		{
+			name:  "enough packs",
+			packs: 10,
+		},
+		{
+			name:  "one pack short",
+			packs: 9,
+			err:   fmt.Errorf(`[tech] %q not finished: %g of %d units researched`, "automation", 9.0, 10),
+		},
+	} {
+		t.Run(test.name, func(tt *testing.T) {
+			tasks := Tasks{
+				Build("lab", 0),
+				Tech("automation"),
+				Transfer("lab", 0, "automation-science-pack", constants.InventoryLabInput, test.packs, false),
+			}
+			s := &state.State{
+				Inventory: map[string]uint{
+					"lab":                     1,
+					"automation-science-pack": test.packs,
+				},
+				TechResearched: map[string]bool{},
+				Buildings:      map[state.BuildingID]building.Building{},
+			}
+			tas, err := addFrom(s, tasks...)
+			if err != nil {
+				tt.Fatal(err)
+			}
+			s = tas.State()
+			err = verifyResearch(s)
+			if d, _ := diff.Diff(err, test.err); len(d) > 0 {
+				tt.Fatal(d)
+			}
+			if s.TechResearched["automation"] != (test.err == nil) {
+				tt.Fatalf("automation researched = %v", s.TechResearched["automation"])
+			}
+		})
+	}
+}
+
+func TestVerifyMineBuilding(t *testing.T) {
+
+	tasks := Tasks{
+		Build("stone-furnace", 0),
+		Transfer("stone-furnace", 0, "coal", constants.InventoryFuel, 5, false),
+		Transfer("stone-furnace", 0, "iron-ore", constants.InventoryFurnaceSource, 10, false),
+		MineEntity("stone-furnace", 0),
+	}
+
+	s := &state.State{
+		Inventory: map[string]uint{
+			"stone-furnace": 1,
+			"coal":          5,
+			"iron-ore":      10,
+		},
+		TechResearched: map[string]bool{},
+		Buildings:      map[state.BuildingID]building.Building{},
+	}
+	tas, err := addFrom(s, tasks...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s = tas.State()
+
+	// smelting 10 ore burns one coal
+	expected := map[string]uint{
+		"stone-furnace": 1,
+		"coal":          4,
+		"iron-plate":    10,
+	}
+	if d, _ := diff.Diff(s.Inventory, expected); len(d) > 0 {
+		t.Fatal(d)
+	}
+}
+
+func TestVerifyMultipleBuildings(t *testing.T) {
+
+	tasks := Tasks{
+		Build("stone-furnace", 1),
+		Build("stone-furnace", 2),
+		Transfer("stone-furnace", 1, "coal", constants.InventoryFuel, 1, false),
+		Transfer("stone-furnace", 2, "coal", constants.InventoryFuel, 1, false),
+		Transfer("stone-furnace", 1, "iron-ore", constants.InventoryFurnaceSource, 10, false),
+		Transfer("stone-furnace", 2, "copper-ore", constants.InventoryFurnaceSource, 10, false),
+		WaitInventory("stone-furnace", 1, "iron-plate", constants.InventoryFurnaceResult, 10, true),
+		Transfer("stone-furnace", 1, "iron-plate", constants.InventoryFurnaceResult, 10, true),
+		WaitInventory("stone-furnace", 2, "copper-plate", constants.InventoryFurnaceResult, 10, true),
+		Transfer("stone-furnace", 2, "copper-plate", constants.InventoryFurnaceResult, 10, true),
+	}
+
+	s := &state.State{
+		Inventory: map[string]uint{
+			"stone-furnace": 2,
+			"coal":          2,
+			"iron-ore":      10,
+			"copper-ore":    10,
+		},
+		TechResearched: map[string]bool{},
+		Buildings:      map[state.BuildingID]building.Building{},
+	}
+	tas, err := addFrom(s, tasks...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s = tas.State()
+
+	expected := map[string]uint{
+		"iron-plate":   10,
+		"copper-plate": 10,
+	}
+	if d, _ := diff.Diff(s.Inventory, expected); len(d) > 0 {
+		t.Fatal(d)
+	}
+
+	_, err = addFrom(&state.State{
+		Inventory:      map[string]uint{"stone-furnace": 1, "coal": 1},
+		TechResearched: map[string]bool{},
+		Buildings:      map[state.BuildingID]building.Building{},
+	},
+		Build("stone-furnace", 1),
+		Transfer("stone-furnace", 2, "coal", constants.InventoryFuel, 1, false),
+	)
+	if d, _ := diff.Diff(err, fmt.Errorf(`[put] building %q not placed`, "stone-furnace[2]")); len(d) > 0 {
+		t.Fatal(d)
+	}
+}
+
+func TestVerifyQueues(t *testing.T) {
+
+	coal := PrereqWait("player", 0, "coal", constants.InventoryCharacterMain, 10, false)
+
+	for _, test := range []struct {
+		name  string
+		tasks func() Tasks
+		err   error
+	}{
+		{
+			name: "no deadlock",
+			tasks: func() Tasks {
+				craft := Craft("iron-gear-wheel", 1)
+				craft.Prerequisites().Add(coal)
+				stone := MineResource("stone", 5)
+				stone.Prerequisites().Add(craft)
+				return Tasks{craft, MineResource("coal", 10), stone}
+			},
+		},
+		{
+			// the coal the craft waits for is mined after the stone, which waits for the craft
+			name: "cycle",
+			tasks: func() Tasks {
+				craft := Craft("iron-gear-wheel", 1)
+				craft.Prerequisites().Add(coal)
+				stone := MineResource("stone", 5)
+				stone.Prerequisites().Add(craft)
+				return Tasks{craft, stone, MineResource("coal", 10)}
+			},
+			err: fmt.Errorf(`[deadlock] craft_iron_gear_wheel (character_craft) waits for %s from mine_coal (character_action), `+
+				`which is queued behind mine_stone (character_action), which needs craft_iron_gear_wheel (character_craft)`, coal.ID()),
+		},
+		{
+			name: "never happens",
+			tasks: func() Tasks {
+				craft := Craft("iron-gear-wheel", 1)
+				craft.Prerequisites().Add(coal)
+				return Tasks{craft, MineResource("coal", 5)}
+			},
+			err: fmt.Errorf(`[deadlock] craft_iron_gear_wheel (character_craft) waits for %s, which never happens`, coal.ID()),
+		},
+		{
+			name: "never researched",
+			tasks: func() Tasks {
+				tech := Tech("automation")
+				craft := Craft("iron-gear-wheel", 1)
+				craft.Prerequisites().Add(tech)
+				return Tasks{tech, craft}
+			},
+			err: fmt.Errorf(`[deadlock] craft_iron_gear_wheel (character_craft) needs tech_automation (lab), which is never researched`),
+		},
+		{
+			// there's no stone before it's mined, but the wait can't start until then. It's only used up by
+			// the furnace, which needs the coal that's queued behind the wait
+			name: "held before the wait could start",
+			tasks: func() Tasks {
+				coal := MineResource("coal", 1)
+				craft := Craft("stone-furnace", 1)
+				craft.Prerequisites().Add(coal)
+				return Tasks{
+					MineResource("stone", 5),
+					WaitInventory("player", 0, "stone", constants.InventoryCharacterMain, 0, true),
+					coal,
+					craft,
+				}
+			},
+			err: fmt.Errorf(`[deadlock] wait_player_stone (character_action) waits for wait_player_stone from craft_stone_furnace (character_craft), ` +
+				`which needs mine_coal (character_action), which is queued behind wait_player_stone (character_action)`),
+		},
+	} {
+		t.Run(test.name, func(tt *testing.T) {
+			tas := &TAS{}
+			if err := tas.Add(test.tasks()...); err != nil {
+				tt.Fatal(err)
+			}
+			err := tas.Verify()
+			if d, _ := diff.Diff(err, test.err); len(d) > 0 {
+				tt.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestSimulate(t *testing.T) {
+
+	furnace := building.NewFurnace(data.GetFurnace("stone-furnace"))
+	smelt, _ := MineAndSmelt("iron-ore", furnace, 20, constants.PreferredFuel)
+
+	craft := Craft("iron-gear-wheel", 5)
+	craft.Prerequisites().Add(PrereqWait("player", 0, "iron-plate", constants.InventoryCharacterMain, 28))
+
+	tasks := Tasks{Build("stone-furnace", 0)}
+	tasks.Add(FuelMachine(constants.PreferredFuel, "stone-furnace", 0, 5)...)
+	tasks.Add(smelt...)
+	tasks.Add(craft)
+
+	for _, test := range []struct {
+		name     string
+		loc      Locator
+		expected [][2]int
+		ticks    int
+	}{
+		{
+			name: "everything in reach",
+			expected: [][2]int{
+				{0, 0},       // build
+				{1, 601},     // mine 5 coal
+				{602, 602},   // fuel
+				{603, 3003},  // mine 20 ore
+				{3004, 3004}, // put
+				{3005, 6844}, // wait for smelting
+				{6845, 6845}, // take
+				{6846, 6996}, // craft gears
+			},
+			ticks: 6996,
+		},
+		{
+			name: "walk to the ore",
+			loc: func(name string, _ int) (geo.Point, bool) {
+				if name == "iron-ore" {
+					return geo.Point{X: 30, Y: 0}, true
+				}
+				return geo.Point{}, true
+			},
+			expected: [][2]int{
+				{0, 0},
+				{1, 601},
+				{602, 602},
+				{603, 3119},  // 17.3 tiles (116 ticks) of walking
+				{3120, 3120}, // the furnace is within reach of the ore
+				{3121, 6960},
+				{6961, 6961},
+				{6962, 7112},
+			},
+			ticks: 7112,
+		},
+	} {
+		t.Run(test.name, func(tt *testing.T) {
+			tl, err := (&TAS{tasks: tasks}).Simulate(test.loc)
+			if err != nil {
+				tt.Fatal(err)
+			}
+			for i, e := range test.expected {
+				if s := tl.Tasks[i]; s.Start != e[0] || s.End != e[1] {
+					tt.Errorf(`wrong timing for task %d (wanted %v but got [%d %d])`, i, e, s.Start, s.End)
+				}
+			}
+			if tl.Ticks != test.ticks {
+				tt.Errorf(`wrong total (wanted %d but got %d)`, test.ticks, tl.Ticks)
+			}
+			if d, _ := diff.Diff(tl.Mined, map[string]uint{"coal": 5, "iron-ore": 20}); len(d) > 0 {
+				tt.Errorf(`wrong resources mined: %v`, d)
+			}
+		})
+	}
+}
+
+func TestSimulateMineBuilding(t *testing.T) {
+
+	craft := Craft("stone-furnace", 1)
+	craft.Prerequisites().Add(PrereqWait("player", 0, "stone", constants.InventoryCharacterMain, 5))
+	mine := MineEntity("stone-furnace", 0)
+	mine.Prerequisites().Add(craft)
+
+	// the player is holding the furnace they just crafted when the placed one is mined
+	sim := newSimulation(Tasks{
+		Build("stone-furnace", 0),
+		MineResource("stone", 5),
+		craft,
+		mine,
+	}, nil)
+	if err := sim.run(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 0.2s at half speed
+	if s := sim.timeline.Tasks[3]; s.End-s.Start != 24 {
+		t.Errorf("expected mining to take 24 ticks, took %d", s.End-s.Start)
+	}
+	if sim.s.IsPlaced("stone-furnace", 0) {
+		t.Error("stone-furnace still placed")
+	}
+	if n := sim.s.Inventory["stone-furnace"]; n != 2 {
+		t.Errorf("expected 2 stone-furnaces, got %d", n)
+	}
+}
+
+func TestSimulateUnlocks(t *testing.T) {
+
+	for _, test := range []struct {
+		name     string
+		waitTech bool
+		err      error
+	}{
+		{
+			name:     "craft waits for the tech",
+			waitTech: true,
+		},
+		{
+			name: "craft doesn't wait",
+			err:  fmt.Errorf(`[craft] tick %d: recipe %q not unlocked (needs tech %q)`, 0, "assembling-machine-1", "automation"),
+		},
+	} {
+		t.Run(test.name, func(tt *testing.T) {
+			tech := Tech("automation")
+			craft := Craft("assembling-machine-1", 1)
+			if test.waitTech {
+				craft.Prerequisites().Add(tech)
+			}
+			sim := newSimulation(Tasks{
+				Build("solar-panel", 0),
+				Build("lab", 0),
+				tech,
+				Transfer("lab", 0, "automation-science-pack", constants.InventoryLabInput, 10, false),
+				craft,
+			}, nil)
+			for item, n := range map[string]uint{
+				"solar-panel":             1,
+				"lab":                     1,
+				"automation-science-pack": 10,
+				"iron-plate":              100,
+				"copper-plate":            100,
+			} {
+				sim.s.Inventory[item] = n
+			}
+
+			err := sim.run()
+			if d, _ := diff.Diff(err, test.err); len(d) > 0 {
+				tt.Fatal(err)
+			}
+			if test.err == nil && sim.s.Inventory["assembling-machine-1"] != 1 {
+				tt.Fatal("assembling-machine-1 not crafted")
+			}
+		})
+	}
+}
+
+func TestVerifyFluids(t *testing.T) {
+
+	for _, test := range []struct {
+		name     string
+		pumpjack bool
+		err      error
+	}{
+		{
+			name:     "pumpjack feeds the refinery",
+			pumpjack: true,
+		},
+		{
+			name: "no crude oil",
+			err:  fmt.Errorf(`[wait] %q stalls waiting for %q (nothing supplies it)`, "chemical-plant", "petroleum-gas"),
+		},
+	} {
+		t.Run(test.name, func(tt *testing.T) {
+			s := &state.State{
+				Inventory: map[string]uint{
+					"pumpjack":       1,
+					"oil-refinery":   1,
+					"chemical-plant": 1,
+				},
+				TechResearched: map[string]bool{},
+				// oil processing isn't in the test data, so nothing unlocks these
+				Unlocked:  map[string]bool{"basic-oil-processing": true, "plastic-bar": true},
+				Buildings: map[state.BuildingID]building.Building{},
+			}
+			tas, err := addFrom(s, plasticTasks(test.pumpjack)...)
+			if d, _ := diff.Diff(err, test.err); len(d) > 0 {
+				tt.Fatal(d)
+			}
+			if n := tas.State().Inventory["plastic-bar"]; test.err == nil && n != 10 {
+				tt.Fatalf("expected 10 plastic bars, got %d", n)
+			}
+		})
+	}
+}
+
+func TestVerifyPower(t *testing.T) {
+
+	setup := Tasks{
+		Build("offshore-pump", 0),
+		Build("boiler", 0),
+		Build("steam-engine", 0),
+		Build("lab", 0),
+		Tech("automation"),
+	}
+	fuel := Transfer("boiler", 0, "coal", constants.InventoryFuel, 5, false)
+	packs := Transfer("lab", 0, "automation-science-pack", constants.InventoryLabInput, 10, false)
+
+	unpaid := fmt.Errorf(`[power] %gMJ used by electric buildings was never generated (not enough power or boiler fuel)`, 6.0)
+
+	for _, test := range []struct {
+		name  string
+		tasks Tasks
+		err   error
+	}{
+		{
+			name:  "fuel first",
+			tasks: append(append(Tasks{}, setup...), fuel, packs),
+		},
+		{
+			// the research is paid for once the fuel arrives
+			name:  "fuel after the packs",
+			tasks: append(append(Tasks{}, setup...), packs, fuel),
+		},
+		{
+			name:  "boiler never fueled",
+			tasks: append(append(Tasks{}, setup...), packs),
+			err:   unpaid,
+		},
+		{
+			name:  "no generator",
+			tasks: Tasks{Build("lab", 0), Tech("automation"), packs},
+			err:   unpaid,
+		},
+	} {
+		t.Run(test.name, func(tt *testing.T) {
+			s := &state.State{
+				Inventory: map[string]uint{
+					"offshore-pump":           1,
+					"boiler":                  1,
+					"steam-engine":            1,
+					"lab":                     1,
+					"coal":                    5,
+					"automation-science-pack": 10,
+				},
+				TechResearched: map[string]bool{},
+				Buildings:      map[state.BuildingID]building.Building{},
+			}
+			tas, err := addFrom(s, test.tasks...)
+			if err != nil {
+				tt.Fatal(err)
+			}
+			s = tas.State()
+			if !s.TechResearched["automation"] {
+				tt.Fatal("automation not researched")
+			}
+			err = verifyPower(s)
+			if d, _ := diff.Diff(err, test.err); len(d) > 0 {
+				tt.Fatal(d)
+			}
+			if err != nil {
+				return
+			}
+			// 6MJ of research burns 1.5 coal, so one is still burning
+			if n := s.Boiler().Inventory(constants.InventoryFuel).Count("coal"); n != 3 {
+				tt.Fatalf("expected 3 coal left in the boiler, got %d", n)
+			}
+			if s.PowerOwed() != 0 {
+				tt.Fatalf("%g J of power not paid for", s.PowerOwed())
+			}
+		})
+	}
+}
+
+func TestVerifySlowSolar(t *testing.T) {
+
+	// the assembler (75kW) draws more than the solar panel makes. With nothing else on the network that
+	// only slows it down
+	s := &state.State{
+		Inventory: map[string]uint{
+			"solar-panel":          1,
+			"assembling-machine-1": 1,
+			"iron-plate":           20,
+		},
+		TechResearched: map[string]bool{},
+		Buildings:      map[state.BuildingID]building.Building{},
+	}
+	tas, err := addFrom(s,
+		Build("solar-panel", 0),
+		Build("assembling-machine-1", 0),
+		Recipe("assembling-machine-1", 0, "iron-gear-wheel"),
+		Transfer("assembling-machine-1", 0, "iron-plate", constants.InventoryAssemblingMachineInput, 20, false),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyPower(tas.State()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSimulateSharedSolar(t *testing.T) {
+
+	sim := newSimulation(Tasks{
+		Build("offshore-pump", 0),
+		Build("boiler", 0),
+		Build("steam-engine", 0),
+		Build("solar-panel", 0),
+		Build("lab", 0),
+		Build("assembling-machine-1", 0),
+		Transfer("boiler", 0, "coal", constants.InventoryFuel, 5, false),
+		Recipe("assembling-machine-1", 0, "iron-gear-wheel"),
+		Tech("automation"),
+		Transfer("lab", 0, "automation-science-pack", constants.InventoryLabInput, 10, false),
+		Transfer("assembling-machine-1", 0, "iron-plate", constants.InventoryAssemblingMachineInput, 100, false),
+	}, nil)
+	for item, n := range map[string]uint{
+		"offshore-pump":           1,
+		"boiler":                  1,
+		"steam-engine":            1,
+		"solar-panel":             1,
+		"lab":                     1,
+		"assembling-machine-1":    1,
+		"coal":                    5,
+		"automation-science-pack": 10,
+		"iron-plate":              100,
+	} {
+		sim.s.Inventory[item] = n
+	}
+	if err := sim.run(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the lab (60kW) researches for 100 seconds and the assembler (75kW) crafts for the first 50. While
+	// both are working the solar panel's 60kW is split between them by what they draw, so the boiler makes
+	// 1.67MJ for the lab and 2.08MJ for the assembler out of 1 coal. After that the lab has it to itself
+	if !sim.s.TechResearched["automation"] {
+		t.Fatal("automation not researched")
+	}
+	if n := sim.s.Boiler().Inventory(constants.InventoryFuel).Count("coal"); n != 4 {
+		t.Fatalf("expected 4 coal left in the boiler, got %d", n)
+	}
+	if e := sim.s.Boiler().Energy(); math.Abs(e-16.25e6) > 100 {
+		t.Fatalf("expected 16.25MJ left in the boiler, got %g", e)
+	}
+}
+
+func TestSimulateNoPower(t *testing.T) {
+
+	// nothing generates power, so the lab never starts
+	sim := newSimulation(Tasks{
+		Build("lab", 0),
+		Tech("automation"),
+		Transfer("lab", 0, "automation-science-pack", constants.InventoryLabInput, 10, false),
+	}, nil)
+	sim.s.Inventory["lab"] = 1
+	sim.s.Inventory["automation-science-pack"] = 10
+
+	err := sim.run()
+	if d, _ := diff.Diff(err, fmt.Errorf(`[simulate] stuck at tick %d; nothing can progress. Waiting on %s`, 3, "tech_automation (lab)")); len(d) > 0 {
+		t.Fatal(d)
+	}
+}
+
+func TestVerifyFluidPower(t *testing.T) {
+
+	tasks := Tasks{
+		Build("offshore-pump", 0),
+		Build("boiler", 0),
+		Build("steam-engine", 0),
+		Transfer("boiler", 0, "coal", constants.InventoryFuel, 10, false),
+	}
+	tasks.Add(plasticTasks(true)...)
+
+	s := &state.State{
+		Inventory: map[string]uint{
+			"offshore-pump":  1,
+			"boiler":         1,
+			"steam-engine":   1,
+			"coal":           10,
+			"pumpjack":       1,
+			"oil-refinery":   1,
+			"chemical-plant": 1,
+		},
+		TechResearched: map[string]bool{},
+		Unlocked:       map[string]bool{"basic-oil-processing": true, "plastic-bar": true},
+		Buildings:      map[state.BuildingID]building.Building{},
+	}
+	tas, err := addFrom(s, tasks...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s = tas.State()
+
+	// 5 plastic crafts (1.05MJ) need 3 refinery crafts for their gas (6.3MJ), which the boiler burns 2 coal for
+	if n := s.Boiler().Inventory(constants.InventoryFuel).Count("coal"); n != 8 {
+		t.Fatalf("expected 8 coal left in the boiler, got %d", n)
+	}
+	if e := s.Boiler().Energy(); math.Abs(e-32.65e6) > 1 {
+		t.Fatalf("expected 32.65MJ left in the boiler, got %g", e)
+	}
+}
+
+// plasticTasks builds an oil setup and makes 10 plastic bars
+func plasticTasks(pumpjack bool) Tasks {
+	tasks := Tasks{}
+	if pumpjack {
+		tasks.Add(Build("pumpjack", 0))
+	}
+	tasks.Add(
+		Build("oil-refinery", 0),
+		Recipe("oil-refinery", 0, "basic-oil-processing"),
+		Build("chemical-plant", 0),
+		MineResource("coal", 5),
+	)
+	chem := building.NewAssembler(data.GetAssemblingMachine("chemical-plant"))
+	tasks.Add(MachineCraft("plastic-bar", chem, 5, "")...)
+	return tasks
+}
+
+func TestVerifyLaunch(t *testing.T) {
+
+	// one rocket part's worth of ingredients, which is also as much as fits in the silo at once
+	parts := func(n int) Tasks {
+		out := Tasks{}
+		for i := 0; i < n; i++ {
+			for _, item := range []string{"low-density-structure", "rocket-fuel", "rocket-control-unit"} {
+				out.Add(Transfer("rocket-silo", 0, item, constants.InventoryRocketSiloInput, 10, false))
+			}
+		}
+		return out
+	}
+	modules := Transfer("rocket-silo", 0, "productivity-module", constants.InventoryRocketSiloModules, 4, false)
+
+	for _, test := range []struct {
+		name  string
+		tasks Tasks
+		err   error
+	}{
+		{
+			name:  "no silo",
+			tasks: Tasks{Launch()},
+			err:   fmt.Errorf(`[launch] no rocket silo placed`),
+		},
+		{
+			name:  "not finished",
+			tasks: append(append(Tasks{Build("rocket-silo", 0)}, parts(99)...), Launch()),
+			err:   fmt.Errorf(`[launch] rocket not finished: 99 of 100 parts made`),
+		},
+		{
+			name:  "finished",
+			tasks: append(append(Tasks{Build("rocket-silo", 0)}, parts(100)...), Launch()),
+		},
+		{
+			// +16% makes 13 extra parts
+			name:  "productivity",
+			tasks: append(append(Tasks{Build("rocket-silo", 0), modules}, parts(87)...), Launch()),
+		},
+		{
+			name:  "productivity not finished",
+			tasks: append(append(Tasks{Build("rocket-silo", 0), modules}, parts(86)...), Launch()),
+			err:   fmt.Errorf(`[launch] rocket not finished: 99 of 100 parts made`),
+		},
+		{
+			// the second rocket starts from nothing
+			name:  "launch twice",
+			tasks: append(append(Tasks{Build("rocket-silo", 0)}, parts(100)...), Launch(), Launch()),
+			err:   fmt.Errorf(`[launch] rocket not finished: 0 of 100 parts made`),
+		},
+	} {
+		t.Run(test.name, func(tt *testing.T) {
+			s := &state.State{
+				Inventory: map[string]uint{
+					"rocket-silo":           1,
+					"productivity-module":   4,
+					"low-density-structure": 1000,
+					"rocket-fuel":           1000,
+					"rocket-control-unit":   1000,
+				},
+				TechResearched: map[string]bool{},
+				Buildings:      map[state.BuildingID]building.Building{},
+			}
+			_, err := addFrom(s, test.tasks...)
+			if d, _ := diff.Diff(err, test.err); len(d) > 0 {
+				tt.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestInsertWalks(t *testing.T) {
+
+	craft := Craft("stone-furnace", 1)
+	craft.Prerequisites().Add(PrereqWait("player", 0, "stone", constants.InventoryCharacterMain, 5))
+	build := Build("stone-furnace", 2)
+	build.Prerequisites().Add(craft)
+
+	tas := TAS{tasks: Tasks{
+		MineResource("stone", 5),
+		craft,
+		Build("stone-furnace", 1),
+		build,
+		MineResource("coal", 2),
+		Transfer("stone-furnace", 1, "coal", constants.InventoryFuel, 1, false),
+		Transfer("stone-furnace", 2, "coal", constants.InventoryFuel, 1, false),
+	}}
+
+	positions := map[string]geo.Point{
+		"stone":           {X: 0, Y: 50},
+		"coal":            {X: -50, Y: 10},
+		"stone-furnace-1": {X: 60, Y: 0},
+		"stone-furnace-2": {X: -60, Y: 0},
+	}
+	loc := func(name string, n int) (geo.Point, bool) {
+		if n != 0 {
+			name = fmt.Sprintf("%s-%d", name, n)
+		}
+		p, ok := positions[name]
+		return p, ok
+	}
+
+	distance, err := tas.InsertWalks(loc, geo.Point{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the coal and stone-furnace[2] are in reach from where the character builds that furnace
+	expected := []TaskType{TaskWalk, TaskMine, TaskCraft, TaskWalk, TaskBuild, TaskWalk, TaskBuild, TaskMine, TaskPut, TaskWalk, TaskPut}
+	var (
+		types []TaskType
+		walks float64
+		pos   geo.Point
+	)
+	for _, task := range tas.tasks {
+		types = append(types, task.Type())
+		if w, ok := task.(*taskWalk); ok {
+			walks += pos.PathDistance(w.Location)
+			pos = w.Location
+		}
+	}
+	if d, _ := diff.Diff(types, expected); len(d) > 0 {
+		t.Fatal(d)
+	}
+	if math.Abs(walks-distance) > 1e-9 {
+		t.Fatalf("reported %g tiles walked but the walks add up to %g", distance, walks)
+	}
+
+	// the closer furnace is fueled first
+	if put := tas.tasks[8].(*taskPut); put.N != 2 {
+		t.Fatalf("expected the put into stone-furnace[2] first, got stone-furnace[%d]", put.N)
+	}
+
+	// the simulation agrees nothing's left out of reach
+	tl, err := tas.Simulate(loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, tt := range tl.Tasks {
+		switch tt.Task.Type() {
+		case TaskBuild, TaskPut:
+			if tt.End != tt.Start {
+				t.Errorf("task %d (%s) took %d ticks, expected it to be in reach", i, tt.Task.ID(), tt.End-tt.Start)
+			}
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+
+	craft := Craft("stone-furnace", 1)
+	craft.Prerequisites().Add(PrereqWait("player", 0, "stone", constants.InventoryCharacterMain, 5))
+	build := Build("stone-furnace", 0)
+	build.Prerequisites().Add(craft)
+
+	tas := TAS{tasks: Tasks{
+		Speed(10),
+		MineResource("stone", 5),
+		craft,
+		Walk(geo.Point{X: 1.5, Y: -2}),
+		build,
+		MineResource("coal", 2),
+		Transfer("stone-furnace", 0, "coal", constants.InventoryFuel, 2, false),
+		MineResource("iron-ore", 1),
+		Transfer("stone-furnace", 0, "iron-ore", constants.InventoryFurnaceSource, 1, false),
+		WaitInventory("stone-furnace", 0, "iron-plate", constants.InventoryFurnaceResult, 1, true),
+		Transfer("stone-furnace", 0, "iron-plate", constants.InventoryFurnaceResult, 1, true),
+	}}
+
+	lua := func(tasks Tasks) string {
+		var out []byte
+		for _, task := range tasks {
+			out = append(out, task.Export()...)
+		}
+		return string(out)
+	}
+
+	var buf bytes.Buffer
+	if err := tas.ExportJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	imported, err := ImportJSON(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := lua(tas.tasks), lua(imported.tasks); expected != got {
+		t.Fatalf("round trip changed the tasks. Expected\n%s\ngot\n%s", expected, got)
+	}
+
+	// science packs and modules aren't plain items. Making them takes a whole run, so this one starts with
+	// them and is only parsed
+	research, err := addFrom(researchState(), researchTasks()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := research.ExportJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := parseJSON(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := lua(research.tasks), lua(parsed); expected != got {
+		t.Fatalf("round trip changed the tasks. Expected\n%s\ngot\n%s", expected, got)
+	}
+
+	for _, test := range []struct {
+		name  string
+		input string
+		err   error
+	}{
+		{
+			name:  "unknown prerequisite",
+			input: `{"tasks": [{"id": "craft_a", "type": "craft", "args": {"recipe": "iron-gear-wheel", "amount": 1}, "prerequisites": [{"id": "mine_a"}]}]}`,
+			err:   fmt.Errorf(`[json] task %q references unknown prerequisite %q`, "craft_a", "mine_a"),
+		},
+		{
+			name: "duplicate ID",
+			input: `{"tasks": [{"id": "mine_a", "type": "mine", "args": {"resource": "coal", "amount": 1}},
+				{"id": "mine_a", "type": "mine", "args": {"resource": "coal", "amount": 1}}]}`,
+			err: fmt.Errorf(`[json] duplicate task ID %q`, "mine_a"),
+		},
+		{
+			name:  "unknown recipe",
+			input: `{"tasks": [{"id": "craft_a", "type": "craft", "args": {"recipe": "nope", "amount": 1}}]}`,
+			err:   fmt.Errorf(`[json] task %q: unknown recipe %q`, "craft_a", "nope"),
+		},
+		{
+			name:  "invalid ID",
+			input: `{"tasks": [{"id": "mine a", "type": "mine", "args": {"resource": "coal", "amount": 1}}]}`,
+			err:   fmt.Errorf(`[json] task %d has invalid ID %q`, 0, "mine a"),
+		},
+		{
+			name:  "unknown item",
+			input: `{"tasks": [{"id": "put_lab", "type": "put", "args": {"entity": "lab", "item": "nope", "inventory": "lab_input", "amount": 1}}]}`,
+			err:   fmt.Errorf(`[json] task %q: unknown item %q`, "put_lab", "nope"),
+		},
+	} {
+		t.Run(test.name, func(tt *testing.T) {
+			_, err := ImportJSON(strings.NewReader(test.input))
+			if d, _ := diff.Diff(err, test.err); len(d) > 0 {
+				tt.Fatal(d)
+			}
+		})
+	}
+}
+
+// researchTasks fills a lab with science packs and puts modules in it and an assembler
+func researchTasks() Tasks {
+	packs := Transfer("lab", 0, "logistic-science-pack", constants.InventoryLabInput, 10, false)
+	packs.Prerequisites().Add(PrereqWait("lab", 0, "logistic-science-pack", constants.InventoryLabInput, 0, true))
+
+	return Tasks{
+		Build("lab", 0),
+		Build("assembling-machine-2", 0),
+		Transfer("lab", 0, "automation-science-pack", constants.InventoryLabInput, 10, false),
+		WaitInventory("lab", 0, "automation-science-pack", constants.InventoryLabInput, 0, true),
+		packs,
+		Transfer("lab", 0, "productivity-module", constants.InventoryLabModules, 2, false),
+		Transfer("assembling-machine-2", 0, "productivity-module", constants.InventoryAssemblingMachineModules, 2, false),
+	}
+}
+
+// researchState has what researchTasks needs
+func researchState() *state.State {
+	return &state.State{
+		Inventory: map[string]uint{
+			"lab":                     1,
+			"assembling-machine-2":    1,
+			"automation-science-pack": 10,
+			"logistic-science-pack":   10,
+			"productivity-module":     4,
+		},
+		TechResearched: map[string]bool{},
+		Buildings:      map[state.BuildingID]building.Building{},
+	}
+}
+
+func TestImportLua(t *testing.T) {
+
+	craft := Craft("stone-furnace", 1)
+	craft.Prerequisites().Add(PrereqWait("player", 0, "stone", constants.InventoryCharacterMain, 5))
+	build := Build("stone-furnace", 1)
+	build.Prerequisites().Add(craft)
+
+	tas := TAS{tasks: Tasks{
+		Speed(10),
+		MineResource("stone", 5),
+		craft,
+		Walk(geo.Point{X: 1.5, Y: -2}),
+		build,
+		MineResource("coal", 2),
+		Transfer("stone-furnace", 1, "coal", constants.InventoryFuel, 2, false),
+		MineResource("iron-ore", 1),
+		Transfer("stone-furnace", 1, "iron-ore", constants.InventoryFurnaceSource, 1, false),
+		WaitInventory("stone-furnace", 1, "iron-plate", constants.InventoryFurnaceResult, 1, true),
+		Transfer("stone-furnace", 1, "iron-plate", constants.InventoryFurnaceResult, 1, true),
+	}}
+
+	lua := func(tasks Tasks) string {
+		var out []byte
+		for _, task := range tasks {
+			out = append(out, task.Export()...)
+		}
+		return string(out)
+	}
+
+	var buf bytes.Buffer
+	if err := tas.Export(&buf); err != nil {
+		t.Fatal(err)
+	}
+	imported, err := ImportLua(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := lua(tas.tasks), lua(imported.tasks); expected != got {
+		t.Fatalf("round trip changed the tasks. Expected\n%s\ngot\n%s", expected, got)
+	}
+
+	// same as TestJSON, these can only be parsed
+	research, err := addFrom(researchState(), researchTasks()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := parseLua(strings.NewReader(lua(research.tasks)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected, got := lua(research.tasks), lua(parsed); expected != got {
+		t.Fatalf("round trip changed the tasks. Expected\n%s\ngot\n%s", expected, got)
+	}
+
+	for _, test := range []struct {
+		name  string
+		input string
+		n     int
+		err   error
+	}{
+		{
+			name: "hand edited",
+			input: `
+-- mined a bit extra to be safe
+my_stone = add_task("mine", nil, {resource = "stone", amount = 6})
+add_task("craft", {
+    my_stone, -- the stone
+    has_inventory("player", "stone", 5, false, defines.inventory.character_main),
+}, {item = "stone-furnace", amount = 1})
+`,
+			n: 2,
+		},
+		{
+			name:  "unknown prerequisite",
+			input: `craft_a = add_task("craft", {mine_a}, {item = "iron-gear-wheel", amount = 1})`,
+			err:   fmt.Errorf(`[lua] task %q references unknown prerequisite %q`, "craft_a", "mine_a"),
+		},
+		{
+			name:  "unsupported argument",
+			input: `add_task("mine", nil, {resource = "coal", amount = count})`,
+			err:   fmt.Errorf(`[lua] line %d: %v`, 1, "amount must be a number"),
+		},
+		{
+			name:  "unclosed call",
+			input: `add_task("mine", nil, {resource = "coal",`,
+			err:   fmt.Errorf(`[lua] line %d: %v`, 1, errLuaEOF),
+		},
+		{
+			name:  "verified",
+			input: `add_task("craft", nil, {item = "stone-furnace", amount = 1})`,
+			err:   fmt.Errorf(`[craft] cannot handcraft %q: %v`, "stone-furnace", `missing 5 "stone"(s)`),
+		},
+	} {
+		t.Run(test.name, func(tt *testing.T) {
+			imported, err := ImportLua(strings.NewReader(test.input))
+			if err != nil || test.err != nil {
+				if err == nil || test.err == nil || err.Error() != test.err.Error() {
+					tt.Fatalf("expected error %v but got %v", test.err, err)
+				}
+				return
+			}
+			if len(imported.tasks) != test.n {
+				tt.Fatalf("expected %d tasks but got %d", test.n, len(imported.tasks))
+			}
+		})
+	}
+}
+
+func TestAssignIDs(t *testing.T) {
+
+	run := func(extra ...Task) Tasks {
+		tasks := append(Tasks{}, extra...)
+		tasks.Add(
+			MineResource("iron-ore", 10),
+			Craft("iron-gear-wheel", 1),
+			Craft("iron-gear-wheel", 1),
+			Named(Craft("iron-gear-wheel", 1), "craft_iron_gear_wheel_3"),
+			Craft("iron-gear-wheel", 1),
+			Build("stone-furnace", 1),
+			MineResource("coal", 1),
+			Transfer("stone-furnace", 1, "coal", constants.InventoryFuel, 1, false),
+		)
+		return tasks
+	}
+	ids := func(tasks Tasks) []string {
+		out := make([]string, len(tasks))
+		for i, task := range tasks {
+			out[i] = task.ID()
+		}
+		return out
+	}
+
+	tas := TAS{}
+	if err := tas.Add(run()...); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"mine_iron_ore",
+		"craft_iron_gear_wheel",
+		"craft_iron_gear_wheel_2",
+		"craft_iron_gear_wheel_3",
+		"craft_iron_gear_wheel_4",
+		"build_stone_furnace_1",
+		"mine_coal",
+		"put_stone_furnace_1_coal",
+	}
+	if d, _ := diff.Diff(ids(tas.tasks), expected); len(d) > 0 {
+		t.Fatalf("wrong IDs: %v", d)
+	}
+
+	// adding something unrelated at the start doesn't change the rest
+	other := TAS{}
+	if err := other.Add(run(Speed(10), MineResource("stone", 1))...); err != nil {
+		t.Fatal(err)
+	}
+	if d, _ := diff.Diff(ids(other.tasks)[2:], expected); len(d) > 0 {
+		t.Fatalf("IDs changed: %v", d)
+	}
+
+	for _, test := range []struct {
+		name  string
+		tasks Tasks
+		err   error
+	}{
+		{
+			name:  "duplicate",
+			tasks: Tasks{Named(Speed(10), "fast"), Named(Speed(1), "fast")},
+			err:   fmt.Errorf(`duplicate task ID %q`, "fast"),
+		},
+		{
+			name:  "invalid",
+			tasks: Tasks{Named(Speed(10), "go-fast")},
+			err:   fmt.Errorf(`invalid task ID %q`, "go-fast"),
+		},
+	} {
+		t.Run(test.name, func(tt *testing.T) {
+			err := (&TAS{}).Add(test.tasks...)
+			if d, _ := diff.Diff(err, test.err); len(d) > 0 {
+				tt.Fatal(d)
+			}
+		})
+	}
+}
+
+func TestIncrementalAdd(t *testing.T) {
+
+	tas := &TAS{}
+	steps := []Tasks{
+		{MineResource("coal", 5), MineResource("iron-ore", 20)},
+		{Build("stone-furnace", 0), Transfer("stone-furnace", 0, "coal", constants.InventoryFuel, 5, false)},
+		{Transfer("stone-furnace", 0, "iron-ore", constants.InventoryFurnaceSource, 10, false)},
+		{Transfer("stone-furnace", 0, "iron-plate", constants.InventoryFurnaceResult, 10, true)},
+	}
+	for i, step := range steps {
+		if err := tas.Add(step...); err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+	}
+
+	before := tas.State()
+
+	// the ore goes in before the second build fails. None of it should stick
+	err := tas.Add(
+		Transfer("stone-furnace", 0, "iron-ore", constants.InventoryFurnaceSource, 10, false),
+		Build("stone-furnace", 1),
+	)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if len(tas.tasks) != 6 {
+		t.Fatalf("expected 6 tasks after the failed Add, got %d", len(tas.tasks))
+	}
+	after := tas.State()
+	plates := func(s *state.State) int {
+		return s.GetBuilding("stone-furnace", 0).Inventory(constants.InventoryFurnaceResult).Count("iron-plate")
+	}
+	if d, _ := diff.Diff(after.Inventory, before.Inventory); len(d) > 0 {
+		t.Fatal(d)
+	}
+	// everything it smelted earlier was taken out
+	if n := plates(after); n != 0 {
+		t.Fatalf("furnace has %d plates after the failed Add, expected 0", n)
+	}
+
+	// changing the returned state doesn't change the TAS
+	after.Inventory["iron-ore"] = 1000
+	if tas.State().Inventory["iron-ore"] != before.Inventory["iron-ore"] {
+		t.Fatal("State() returned the TAS's own state")
+	}
+
+	// verifying from the start ends up in the same place
+	full := &TAS{tasks: append(Tasks{}, tas.tasks...)}
+	if err := full.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	if d, _ := diff.Diff(full.State().Inventory, before.Inventory); len(d) > 0 {
+		t.Fatal(d)
+	}
+
+	if err := tas.Add(Craft("iron-gear-wheel", 2)); err != nil {
+		t.Fatal(err)
+	}
+	if tas.tasks[6].ID() != "craft_iron_gear_wheel" {
+		t.Fatalf("unexpected ID %q", tas.tasks[6].ID())
+	}
+}
+
+func TestStateAt(t *testing.T) {
+
+	tas := &TAS{}
+	if err := tas.Add(
+		MineResource("iron-ore", 10),
+		Build("stone-furnace", 0),
+		Transfer("stone-furnace", 0, "iron-ore", constants.InventoryFurnaceSource, 10, false),
+		MineEntity("stone-furnace", 0),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		i      int
+		placed bool
+		ore    uint
+	}{
+		{i: -1},
+		{i: 0, ore: 10},
+		{i: 1, placed: true, ore: 10},
+		{i: 2, placed: true},
+		// the furnace has no fuel, so the ore comes back with it
+		{i: 3, ore: 10},
+	} {
+		s, err := tas.StateAt(test.i)
+		if err != nil {
+			t.Fatalf("task %d: %v", test.i, err)
+		}
+		if placed := s.IsPlaced("stone-furnace", 0); placed != test.placed {
+			t.Fatalf("task %d: furnace placed = %v", test.i, placed)
+		}
+		if s.Inventory["iron-ore"] != test.ore {
+			t.Fatalf("task %d: expected %d ore, got %d", test.i, test.ore, s.Inventory["iron-ore"])
+		}
+	}
+
+	last, _ := tas.StateAt(tas.Len() - 1)
+	if d, _ := diff.Diff(last.Inventory, tas.State().Inventory); len(d) > 0 {
+		t.Fatal(d)
+	}
+
+	if _, err := tas.StateAt(tas.Len()); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestConcurrentPlans(t *testing.T) {
+
+	plan := func() (string, error) {
+		furnace := building.NewFurnace(data.GetFurnace("stone-furnace"))
+		smelt, _ := MineAndSmelt("iron-ore", furnace, 20, constants.PreferredFuel)
+		craft := Craft("iron-gear-wheel", 5)
+		craft.Prerequisites().Add(PrereqWait("player", 0, "iron-plate", constants.InventoryCharacterMain, 28))
+
+		tas := &TAS{}
+		tasks := Tasks{Build("stone-furnace", 0)}
+		tasks.Add(FuelMachine(constants.PreferredFuel, "stone-furnace", 0, 5)...)
+		tasks.Add(smelt...)
+		tasks.Add(craft)
+		if err := tas.Add(tasks...); err != nil {
+			return "", err
+		}
+		if _, err := tas.Simulate(nil); err != nil {
+			return "", err
+		}
+		var buf bytes.Buffer
+		err := tas.Export(&buf)
+		return buf.String(), err
+	}
+
+	expected, err := plan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		t.Run(fmt.Sprintf("plan %d", i), func(tt *testing.T) {
+			tt.Parallel()
+			got, err := plan()
+			if err != nil {
+				tt.Fatal(err)
+			}
+			if got != expected {
+				tt.Fatal("plans built at the same time should be the same")
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
+	"regexp"
 
 	"github.com/brettschalin/factorio-min-resources/building"
 	"github.com/brettschalin/factorio-min-resources/calc"
@@ -78,29 +79,67 @@ func (t TaskType) String() string {
 	}
 }
 
-func (t *TaskType) MarshalJSON() ([]byte, error) {
+func (t TaskType) MarshalJSON() ([]byte, error) {
 	return json.Marshal(t.String())
 }
 
+func (t *TaskType) UnmarshalJSON(b []byte) error {
+	var name string
+	if err := json.Unmarshal(b, &name); err != nil {
+		return err
+	}
+	typ, ok := ParseTaskType(name)
+	if !ok {
+		return fmt.Errorf(`unknown task type %q`, name)
+	}
+	*t = typ
+	return nil
+}
+
+// ParseTaskType is the inverse of TaskType.String
+func ParseTaskType(name string) (TaskType, bool) {
+	for t := TaskWalk; t < taskPrereq; t++ {
+		if t.String() == name {
+			return t, true
+		}
+	}
+	return TaskUnknown, false
+}
+
 type baseTask struct {
 	id      string
 	prereqs Tasks
 }
 
-var ids = map[TaskType]int{}
-
-func (t *baseTask) getID(typ TaskType) string {
+// getID returns the task's ID. Until a TAS gives it one (see TAS.nameTasks) that's made from its type and
+// `parts`, which should be whatever tells it apart from other tasks of the same type
+func (t *baseTask) getID(typ TaskType, parts ...interface{}) string {
 	if t.id != "" {
 		return t.id
 	}
 
-	n := ids[typ]
-	ids[typ]++
-
-	t.id = fmt.Sprintf("%s_%d", typ.String(), n)
-	return t.id
+	id := typ.String()
+	for _, p := range parts {
+		switch p := p.(type) {
+		case string:
+			if p != "" {
+				id += "_" + p
+			}
+		case int:
+			if p != 0 {
+				id += fmt.Sprintf("_%d", p)
+			}
+		}
+	}
+	return invalidIDChars.ReplaceAllString(id, "_")
 }
 
+// IDs are variable names in tasks.lua, so anything that isn't allowed in one is replaced with an underscore
+var (
+	validID        = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	invalidIDChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+)
+
 func (t *baseTask) ID() string {
 	return t.getID(t.Type())
 }
@@ -113,6 +152,10 @@ func (t *baseTask) Prerequisites() *Tasks {
 	return &t.prereqs
 }
 
+func (t *baseTask) base() *baseTask {
+	return t
+}
+
 func (t baseTask) fmtPrereqs() []byte {
 
 	if len(t.prereqs) == 0 {
@@ -150,8 +193,17 @@ type taskCraft struct {
 	Amount uint
 }
 
+// items returns how many of the recipe's product the craft makes. Amount goes straight to begin_crafting,
+// so it's the number of crafts rather than items
+func (t *taskCraft) items(rec *data.Recipe) uint {
+	if rec == nil {
+		return t.Amount
+	}
+	return t.Amount * uint(rec.ProductCount(rec.Name))
+}
+
 func (t *taskCraft) ID() string {
-	return t.getID(t.Type())
+	return t.getID(TaskCraft, t.Recipe)
 }
 
 func (t *taskCraft) Type() TaskType {
@@ -172,7 +224,7 @@ type taskWalk struct {
 }
 
 func (t *taskWalk) ID() string {
-	return t.getID(t.Type())
+	return t.getID(TaskWalk)
 }
 
 func (t *taskWalk) Type() TaskType {
@@ -192,6 +244,7 @@ type taskWait struct {
 
 	// inventory has a certain # of items
 	Entity string
+	N      int
 	Slot   constants.Inventory
 	Item   string
 	Amount uint
@@ -202,7 +255,7 @@ type taskWait struct {
 }
 
 func (t *taskWait) ID() string {
-	return t.getID(t.Type())
+	return t.getID(TaskWait, t.Entity, t.N, t.Item)
 }
 
 func (t *taskWait) Type() TaskType {
@@ -212,12 +265,27 @@ func (t *taskWait) Type() TaskType {
 func (t *taskWait) Export() []byte {
 	return t.export(
 		t.ID(),
-		fmt.Sprintf(`done = has_inventory(%q, %q, %d, %t, %s)`,
-			t.Entity, t.Item, t.Amount, t.Exact, t.Slot),
+		`done = `+hasInventory(t.Entity, t.N, t.Item, t.Slot, t.Amount, t.Exact),
 		TaskWait,
 	)
 }
 
+// hasInventory formats a call to the has_inventory function in tasks.lua
+func hasInventory(entity string, n int, item string, slot constants.Inventory, amount uint, exact bool) string {
+	if n != 0 {
+		return fmt.Sprintf(`has_inventory(%q, %q, %d, %t, %s, %d)`, entity, item, amount, exact, slot, n)
+	}
+	return fmt.Sprintf(`has_inventory(%q, %q, %d, %t, %s)`, entity, item, amount, exact, slot)
+}
+
+// entityArgs formats the arguments that select a building
+func entityArgs(entity string, n int) string {
+	if n != 0 {
+		return fmt.Sprintf(`entity = %q, n = %d`, entity, n)
+	}
+	return fmt.Sprintf(`entity = %q`, entity)
+}
+
 type taskMine struct {
 	baseTask
 
@@ -231,7 +299,7 @@ type taskMine struct {
 }
 
 func (t *taskMine) ID() string {
-	return t.getID(t.Type())
+	return t.getID(TaskMine, t.Resource, t.Entity, t.N)
 }
 
 func (t *taskMine) Type() TaskType {
@@ -243,11 +311,7 @@ func (t *taskMine) Export() []byte {
 	if t.Resource != "" {
 		args = fmt.Sprintf(`resource = %q, amount = %d`, t.Resource, t.Amount)
 	} else {
-		if t.N != 0 {
-			args = fmt.Sprintf(`entity = %q, n = %d`, t.Entity, t.N)
-		} else {
-			args = fmt.Sprintf(`entity = %q`, t.Entity)
-		}
+		args = entityArgs(t.Entity, t.N)
 	}
 	return t.export(
 		t.ID(),
@@ -263,7 +327,7 @@ type taskBuild struct {
 }
 
 func (t *taskBuild) ID() string {
-	return t.getID(t.Type())
+	return t.getID(TaskBuild, t.Entity, t.N)
 }
 
 func (t *taskBuild) Type() TaskType {
@@ -271,15 +335,9 @@ func (t *taskBuild) Type() TaskType {
 }
 
 func (t *taskBuild) Export() []byte {
-	args := fmt.Sprintf(`entity = %q`, t.Entity)
-
-	if t.N != 0 {
-		args += fmt.Sprintf(`, n = %d`, t.N)
-	}
-
 	return t.export(
 		t.ID(),
-		args,
+		entityArgs(t.Entity, t.N),
 		TaskBuild,
 	)
 }
@@ -288,6 +346,7 @@ type taskTake struct {
 	baseTask
 
 	Entity string
+	N      int
 	Slot   constants.Inventory
 	Item   string
 	Amount uint
@@ -297,14 +356,14 @@ func (t *taskTake) Export() []byte {
 
 	return t.export(
 		t.ID(),
-		fmt.Sprintf(`entity = %q, inventory = %s, item = %q, amount = %d`,
-			t.Entity, t.Slot, t.Item, t.Amount),
+		fmt.Sprintf(`%s, inventory = %s, item = %q, amount = %d`,
+			entityArgs(t.Entity, t.N), t.Slot, t.Item, t.Amount),
 		TaskTake,
 	)
 }
 
 func (t *taskTake) ID() string {
-	return t.getID(t.Type())
+	return t.getID(TaskTake, t.Entity, t.N, t.Item)
 }
 
 func (t *taskTake) Type() TaskType {
@@ -315,13 +374,14 @@ type taskPut struct {
 	baseTask
 
 	Entity string
+	N      int
 	Slot   constants.Inventory
 	Item   string
 	Amount uint
 }
 
 func (t *taskPut) ID() string {
-	return t.getID(t.Type())
+	return t.getID(TaskPut, t.Entity, t.N, t.Item)
 }
 
 func (t *taskPut) Type() TaskType {
@@ -332,8 +392,8 @@ func (t *taskPut) Export() []byte {
 
 	return t.export(
 		t.ID(),
-		fmt.Sprintf(`entity = %q, inventory = %s, item = %q, amount = %d`,
-			t.Entity, t.Slot, t.Item, t.Amount),
+		fmt.Sprintf(`%s, inventory = %s, item = %q, amount = %d`,
+			entityArgs(t.Entity, t.N), t.Slot, t.Item, t.Amount),
 		TaskPut,
 	)
 }
@@ -341,11 +401,12 @@ func (t *taskPut) Export() []byte {
 type taskRecipe struct {
 	baseTask
 	Entity string
+	N      int
 	Recipe string
 }
 
 func (t *taskRecipe) ID() string {
-	return t.getID(t.Type())
+	return t.getID(TaskRecipe, t.Entity, t.N, t.Recipe)
 }
 
 func (t *taskRecipe) Type() TaskType {
@@ -355,7 +416,7 @@ func (t *taskRecipe) Type() TaskType {
 func (t *taskRecipe) Export() []byte {
 	return t.export(
 		t.ID(),
-		fmt.Sprintf(`entity = %q, recipe = %q`, t.Entity, t.Recipe),
+		fmt.Sprintf(`%s, recipe = %q`, entityArgs(t.Entity, t.N), t.Recipe),
 		TaskRecipe,
 	)
 }
@@ -366,7 +427,7 @@ type taskTech struct {
 }
 
 func (t *taskTech) ID() string {
-	return t.getID(t.Type())
+	return t.getID(TaskTech, t.Tech)
 }
 
 func (t *taskTech) Type() TaskType {
@@ -387,7 +448,7 @@ type taskSpeed struct {
 }
 
 func (t *taskSpeed) ID() string {
-	return t.getID(t.Type())
+	return t.getID(TaskSpeed)
 }
 
 func (t *taskSpeed) Type() TaskType {
@@ -407,7 +468,7 @@ type taskLaunch struct {
 }
 
 func (t *taskLaunch) ID() string {
-	return t.getID(t.Type())
+	return t.getID(TaskLaunch)
 }
 
 func (t *taskLaunch) Type() TaskType {
@@ -427,6 +488,7 @@ func (t *taskLaunch) Export() []byte {
 type taskPrereqWait struct {
 	baseTask
 	Entity string
+	N      int
 	Slot   constants.Inventory
 	Item   string
 	Amount uint
@@ -434,8 +496,7 @@ type taskPrereqWait struct {
 }
 
 func (t *taskPrereqWait) ID() string {
-	return fmt.Sprintf(`has_inventory(%q, %q, %d, %t, %s)`,
-		t.Entity, t.Item, t.Amount, t.Exact, t.Slot)
+	return hasInventory(t.Entity, t.N, t.Item, t.Slot, t.Amount, t.Exact)
 }
 
 func (t *taskPrereqWait) Export() []byte {
@@ -448,6 +509,13 @@ func (t *taskPrereqWait) Type() TaskType {
 
 /**** FUNCTIONS ****/
 
+// Named gives a task an ID instead of letting the TAS generate one. It's the task's variable name in
+// tasks.lua, so it has to be a valid Lua identifier and unique within the TAS
+func Named(task Task, id string) Task {
+	task.(interface{ base() *baseTask }).base().id = id
+	return task
+}
+
 // Build constructs a building facing the given direction. Locations
 // are hardcoded in locations.lua
 func Build(entity string, n int) Task {
@@ -457,7 +525,7 @@ func Build(entity string, n int) Task {
 	}
 }
 
-// Craft starts a handcrafting action
+// Craft starts a handcrafting action of `amount` crafts of the recipe
 func Craft(recipe string, amount uint) Task {
 	return &taskCraft{
 		Recipe: recipe,
@@ -488,11 +556,12 @@ func MineResource(resource string, amount uint) Task {
 	}
 }
 
-// Transfer takes resources from or adds them to the given inventory
-func Transfer(entity, item string, slot constants.Inventory, amount uint, take bool) Task {
+// Transfer takes resources from or adds them to the given inventory of the nth entity
+func Transfer(entity string, n int, item string, slot constants.Inventory, amount uint, take bool) Task {
 	if take {
 		return &taskTake{
 			Entity: entity,
+			N:      n,
 			Item:   item,
 			Slot:   slot,
 			Amount: amount,
@@ -500,16 +569,18 @@ func Transfer(entity, item string, slot constants.Inventory, amount uint, take b
 	}
 	return &taskPut{
 		Entity: entity,
+		N:      n,
 		Item:   item,
 		Slot:   slot,
 		Amount: amount,
 	}
 }
 
-// Recipe sets the current recipe on the given machine
-func Recipe(entity, recipe string) Task {
+// Recipe sets the current recipe on the nth machine with this name
+func Recipe(entity string, n int, recipe string) Task {
 	return &taskRecipe{
 		Entity: entity,
+		N:      n,
 		Recipe: recipe,
 	}
 }
@@ -521,10 +592,12 @@ func Tech(tech string) Task {
 	}
 }
 
-// WaitInventory pauses task execution until certain inventory criteria are met
-func WaitInventory(entity, item string, slot constants.Inventory, amount uint, exact bool) Task {
+// WaitInventory pauses task execution until certain inventory criteria are met.
+// `n` is ignored when the entity is "player"
+func WaitInventory(entity string, n int, item string, slot constants.Inventory, amount uint, exact bool) Task {
 	return &taskWait{
 		Entity: entity,
+		N:      n,
 		Item:   item,
 		Slot:   slot,
 		Amount: amount,
@@ -533,13 +606,14 @@ func WaitInventory(entity, item string, slot constants.Inventory, amount uint, e
 }
 
 // PrereqWait is like WaitInventory but used for prerequisite definitions
-func PrereqWait(entity, item string, slot constants.Inventory, amount uint, exact ...bool) Task {
+func PrereqWait(entity string, n int, item string, slot constants.Inventory, amount uint, exact ...bool) Task {
 	var e bool
 	if len(exact) > 0 {
 		e = exact[0]
 	}
 	return &taskPrereqWait{
 		Entity: entity,
+		N:      n,
 		Item:   item,
 		Slot:   slot,
 		Amount: amount,
@@ -567,7 +641,9 @@ func Walk(location geo.Point) Task {
 	}
 }
 
-// Craft inside a machine (assembler or furnace). Returns the tasks required
+// Craft inside a machine (assembler or furnace). Returns the tasks required. Burner machines
+// are topped up with `fuel` from the player's inventory before each batch; getting that fuel
+// is up to the caller. Fluids are assumed to be piped in and out
 func MachineCraft(recipe string, machine building.CraftingBuilding, amount uint, fuel string) Tasks {
 
 	var (
@@ -579,7 +655,7 @@ func MachineCraft(recipe string, machine building.CraftingBuilding, amount uint,
 	case *building.Assembler:
 		// only assemblers can have set recipes
 		if r := m.Recipe(); r == nil || r.Name != recipe {
-			tasks.Add(Recipe(m.Name(), recipe))
+			tasks.Add(Recipe(m.Name(), m.Index(), recipe))
 			// updating inventory not necessary here
 			m.SetRecipe(data.GetRecipe(recipe))
 		}
@@ -592,6 +668,7 @@ func MachineCraft(recipe string, machine building.CraftingBuilding, amount uint,
 
 	var (
 		mName           = machine.Name()
+		mN              = machine.Index()
 		inSlot          = machine.Slots().Input
 		outSlot         = machine.Slots().Output
 		onlyFluidInputs = true
@@ -614,13 +691,24 @@ func MachineCraft(recipe string, machine building.CraftingBuilding, amount uint,
 		// add batch of inputs
 		for _, ing := range rec.Ingredients {
 			if !ing.IsFluid {
-				tasks.Add(Transfer(mName, ing.Name, inSlot, amt*uint(ing.Amount), false))
+				tasks.Add(Transfer(mName, mN, ing.Name, inSlot, amt*uint(ing.Amount), false))
 				_ = machine.Inventory(inSlot).Put(ing.Name, int(amt)*ing.Amount)
 			}
 		}
 
+		// add enough fuel for the batch
+		if fuel != "" && machine.EnergySource().FuelCategory == constants.FuelCategoryChemical {
+			need := calc.FuelFromRecipes(machine, rec, int(amt), fuel) - machine.Energy()/float64(data.GetItem(fuel).FuelValue)
+			if need > 0 {
+				n := uint(math.Ceil(need))
+				tasks.Add(Transfer(mName, mN, fuel, machine.Slots().Fuel, n, false))
+				_ = machine.Inventory(machine.Slots().Fuel).Put(fuel, int(n))
+			}
+		}
+
 		// simulate crafts
 		for {
+			pipeFluids(machine, rec)
 			status = machine.DoCraft()
 			if status != building.CraftStatusRunning || thisBatch == amt {
 				break
@@ -634,8 +722,8 @@ func MachineCraft(recipe string, machine building.CraftingBuilding, amount uint,
 				if !prod.IsFluid {
 					toTake := uint(machine.Inventory(outSlot).Count(prod.Name))
 					tasks.Add(
-						WaitInventory(mName, prod.Name, outSlot, toTake, true),
-						Transfer(mName, prod.Name, outSlot, toTake, true),
+						WaitInventory(mName, mN, prod.Name, outSlot, toTake, true),
+						Transfer(mName, mN, prod.Name, outSlot, toTake, true),
 					)
 					_ = machine.Inventory(outSlot).Take(prod.Name, int(toTake))
 				}
@@ -643,6 +731,7 @@ func MachineCraft(recipe string, machine building.CraftingBuilding, amount uint,
 
 			// and craft until we can't
 			for {
+				pipeFluids(machine, rec)
 				status = machine.DoCraft()
 				if status != building.CraftStatusRunning {
 					break
@@ -655,8 +744,8 @@ func MachineCraft(recipe string, machine building.CraftingBuilding, amount uint,
 			if !prod.IsFluid {
 				toTake := uint(machine.Inventory(outSlot).Count(prod.Name))
 				tasks.Add(
-					WaitInventory(mName, prod.Name, outSlot, toTake, true),
-					Transfer(mName, prod.Name, outSlot, toTake, true),
+					WaitInventory(mName, mN, prod.Name, outSlot, toTake, true),
+					Transfer(mName, mN, prod.Name, outSlot, toTake, true),
 				)
 				_ = machine.Inventory(outSlot).Take(prod.Name, int(toTake))
 			}
@@ -671,8 +760,8 @@ func MachineCraft(recipe string, machine building.CraftingBuilding, amount uint,
 			toTake := uint(machine.Inventory(outSlot).Count(prod.Name))
 			if toTake > 0 {
 				tasks.Add(
-					WaitInventory(mName, prod.Name, outSlot, toTake, true),
-					Transfer(mName, prod.Name, outSlot, toTake, true),
+					WaitInventory(mName, mN, prod.Name, outSlot, toTake, true),
+					Transfer(mName, mN, prod.Name, outSlot, toTake, true),
 				)
 				_ = machine.Inventory(outSlot).Take(prod.Name, int(toTake))
 			}
@@ -682,6 +771,25 @@ func MachineCraft(recipe string, machine building.CraftingBuilding, amount uint,
 	return tasks
 }
 
+// pipeFluids stands in for the pipes while planning: the machine's fluid inputs are filled and its
+// fluid outputs emptied. Verifying the TAS checks that something actually supplies them
+func pipeFluids(machine building.CraftingBuilding, rec *data.Recipe) {
+	fb, ok := machine.(building.FluidBuilding)
+	if !ok {
+		return
+	}
+	for _, ing := range rec.Ingredients {
+		if ing.IsFluid {
+			fb.PutFluid(ing.Name, math.Inf(1))
+		}
+	}
+	for _, p := range rec.GetResults() {
+		if p.IsFluid {
+			fb.TakeFluid(p.Name, math.Inf(1))
+		}
+	}
+}
+
 // MineAndSmelt properly intersperses mining, waiting, and transferring
 // ores to work around stack size limitations. This does assume the machine is properly fueled
 func MineAndSmelt(ore string, machine building.CraftingBuilding, amount uint, fuel string) (tasks Tasks, fuelUsed float64) {
@@ -701,6 +809,7 @@ func MineAndSmelt(ore string, machine building.CraftingBuilding, amount uint, fu
 
 	var (
 		mName   = machine.Name()
+		mN      = machine.Index()
 		inSlot  = machine.Slots().Input
 		outSlot = machine.Slots().Output
 
@@ -713,7 +822,7 @@ func MineAndSmelt(ore string, machine building.CraftingBuilding, amount uint, fu
 	for amount > 0 {
 
 		tasks.Add(
-			Transfer(mName, ore, inSlot, amt, false),
+			Transfer(mName, mN, ore, inSlot, amt, false),
 		)
 
 		// mine the next batch, but only if we need to
@@ -727,8 +836,8 @@ func MineAndSmelt(ore string, machine building.CraftingBuilding, amount uint, fu
 		takeAmt := uint(float64(amt) * recipeMultiplier)
 
 		tasks.Add(
-			WaitInventory(mName, plate.Name, outSlot, takeAmt, true),
-			Transfer(mName, plate.Name, outSlot, takeAmt, true),
+			WaitInventory(mName, mN, plate.Name, outSlot, takeAmt, true),
+			Transfer(mName, mN, plate.Name, outSlot, takeAmt, true),
 		)
 
 		amt = nextBatch
@@ -742,7 +851,7 @@ func MineFuelAndSmelt(ore, fuel string, machine building.CraftingBuilding, nOre
 	tasks = Tasks{}
 
 	fuelItem := data.GetItem(fuel)
-	mName := machine.Name()
+	mName, mN := machine.Name(), machine.Index()
 
 	var (
 		rec              = data.GetSmeltingRecipe(ore)
@@ -773,7 +882,7 @@ func MineFuelAndSmelt(ore, fuel string, machine building.CraftingBuilding, nOre
 			extraFuel = minedFuel - nFuel
 
 			if minedFuel > 0 {
-				tasks.Add(FuelMachine(fuel, mName, uint(minedFuel))...)
+				tasks.Add(FuelMachine(fuel, mName, mN, uint(minedFuel))...)
 			}
 			t, _ := MineAndSmelt(ore, machine, round(uint(nRecipe/recipeMultiplier)), fuel)
 			tasks.Add(t...)
@@ -787,7 +896,7 @@ func MineFuelAndSmelt(ore, fuel string, machine building.CraftingBuilding, nOre
 		extraFuel = minedFuel - nFuel
 
 		// fuel the machine and do some smelting
-		tasks.Add(FuelMachine(fuel, mName, uint(minedFuel))...)
+		tasks.Add(FuelMachine(fuel, mName, mN, uint(minedFuel))...)
 		t, _ := MineAndSmelt(ore, machine, minedOre, fuel)
 		tasks.Add(t...)
 
@@ -796,9 +905,9 @@ func MineFuelAndSmelt(ore, fuel string, machine building.CraftingBuilding, nOre
 	return tasks, extraFuel
 }
 
-func FuelMachine(fuel, entity string, amount uint) Tasks {
+func FuelMachine(fuel, entity string, n int, amount uint) Tasks {
 	return Tasks{
 		MineResource(fuel, amount),
-		Transfer(entity, fuel, constants.InventoryFuel, amount, false),
+		Transfer(entity, n, fuel, constants.InventoryFuel, amount, false),
 	}
 }
@@ -79,9 +79,7 @@ func (c *craftTestCase) verify(t *testing.T, tasks Tasks, leftoverFuel float64)
 				t.Errorf(`wrong type for task %d (wanted %q but got %q)`, i, expected.Type(), task.Type())
 			}
 			e := string(task.Export())
-			delete(ids, task.Type())
 			e2 := string(expected.Export())
-			delete(ids, task.Type())
 
 			if e != e2 {
 				t.Errorf(`tasks at index %d not equal (wanted %q but got %q)`, i, e2, e)
@@ -98,10 +96,10 @@ func TestMachineCraft(t *testing.T) {
 			recipe:  "copper-cable",
 			amount:  20,
 			expectedTasks: Tasks{
-				Recipe(assembler.Name(), "copper-cable"),
-				Transfer(assembler.Name(), "copper-plate", constants.InventoryAssemblingMachineInput, 20, false),
-				WaitInventory(assembler.Name(), "copper-cable", constants.InventoryAssemblingMachineOutput, 40, true),
-				Transfer(assembler.Name(), "copper-cable", constants.InventoryAssemblingMachineOutput, 40, true),
+				Recipe(assembler.Name(), 0, "copper-cable"),
+				Transfer(assembler.Name(), 0, "copper-plate", constants.InventoryAssemblingMachineInput, 20, false),
+				WaitInventory(assembler.Name(), 0, "copper-cable", constants.InventoryAssemblingMachineOutput, 40, true),
+				Transfer(assembler.Name(), 0, "copper-cable", constants.InventoryAssemblingMachineOutput, 40, true),
 			},
 		},
 		{
@@ -110,14 +108,14 @@ func TestMachineCraft(t *testing.T) {
 			recipe:  "iron-gear-wheel",
 			amount:  60,
 			expectedTasks: Tasks{
-				Recipe(assembler.Name(), "iron-gear-wheel"),
-				Transfer(assembler.Name(), "iron-plate", constants.InventoryAssemblingMachineInput, 100, false),
-				WaitInventory(assembler.Name(), "iron-gear-wheel", constants.InventoryAssemblingMachineOutput, 50, true),
-				Transfer(assembler.Name(), "iron-gear-wheel", constants.InventoryAssemblingMachineOutput, 50, true),
-
-				Transfer(assembler.Name(), "iron-plate", constants.InventoryAssemblingMachineInput, 20, false),
-				WaitInventory(assembler.Name(), "iron-gear-wheel", constants.InventoryAssemblingMachineOutput, 10, true),
-				Transfer(assembler.Name(), "iron-gear-wheel", constants.InventoryAssemblingMachineOutput, 10, true),
+				Recipe(assembler.Name(), 0, "iron-gear-wheel"),
+				Transfer(assembler.Name(), 0, "iron-plate", constants.InventoryAssemblingMachineInput, 100, false),
+				WaitInventory(assembler.Name(), 0, "iron-gear-wheel", constants.InventoryAssemblingMachineOutput, 50, true),
+				Transfer(assembler.Name(), 0, "iron-gear-wheel", constants.InventoryAssemblingMachineOutput, 50, true),
+
+				Transfer(assembler.Name(), 0, "iron-plate", constants.InventoryAssemblingMachineInput, 20, false),
+				WaitInventory(assembler.Name(), 0, "iron-gear-wheel", constants.InventoryAssemblingMachineOutput, 10, true),
+				Transfer(assembler.Name(), 0, "iron-gear-wheel", constants.InventoryAssemblingMachineOutput, 10, true),
 			},
 		},
 		{
@@ -126,14 +124,14 @@ func TestMachineCraft(t *testing.T) {
 			recipe:  "iron-stick",
 			amount:  75,
 			expectedTasks: Tasks{
-				Recipe(assembler.Name(), "iron-stick"),
-				Transfer(assembler.Name(), "iron-plate", constants.InventoryAssemblingMachineInput, 50, false),
-				WaitInventory(assembler.Name(), "iron-stick", constants.InventoryAssemblingMachineOutput, 100, true),
-				Transfer(assembler.Name(), "iron-stick", constants.InventoryAssemblingMachineOutput, 100, true),
-
-				Transfer(assembler.Name(), "iron-plate", constants.InventoryAssemblingMachineInput, 25, false),
-				WaitInventory(assembler.Name(), "iron-stick", constants.InventoryAssemblingMachineOutput, 50, true),
-				Transfer(assembler.Name(), "iron-stick", constants.InventoryAssemblingMachineOutput, 50, true),
+				Recipe(assembler.Name(), 0, "iron-stick"),
+				Transfer(assembler.Name(), 0, "iron-plate", constants.InventoryAssemblingMachineInput, 50, false),
+				WaitInventory(assembler.Name(), 0, "iron-stick", constants.InventoryAssemblingMachineOutput, 100, true),
+				Transfer(assembler.Name(), 0, "iron-stick", constants.InventoryAssemblingMachineOutput, 100, true),
+
+				Transfer(assembler.Name(), 0, "iron-plate", constants.InventoryAssemblingMachineInput, 25, false),
+				WaitInventory(assembler.Name(), 0, "iron-stick", constants.InventoryAssemblingMachineOutput, 50, true),
+				Transfer(assembler.Name(), 0, "iron-stick", constants.InventoryAssemblingMachineOutput, 50, true),
 			},
 		},
 		{
@@ -142,11 +140,11 @@ func TestMachineCraft(t *testing.T) {
 			recipe:  "electronic-circuit",
 			amount:  50,
 			expectedTasks: Tasks{
-				Recipe(assembler.Name(), "electronic-circuit"),
-				Transfer(assembler.Name(), "iron-plate", constants.InventoryAssemblingMachineInput, 50, false),
-				Transfer(assembler.Name(), "copper-cable", constants.InventoryAssemblingMachineInput, 150, false),
-				WaitInventory(assembler.Name(), "electronic-circuit", constants.InventoryAssemblingMachineOutput, 50, true),
-				Transfer(assembler.Name(), "electronic-circuit", constants.InventoryAssemblingMachineOutput, 50, true),
+				Recipe(assembler.Name(), 0, "electronic-circuit"),
+				Transfer(assembler.Name(), 0, "iron-plate", constants.InventoryAssemblingMachineInput, 50, false),
+				Transfer(assembler.Name(), 0, "copper-cable", constants.InventoryAssemblingMachineInput, 150, false),
+				WaitInventory(assembler.Name(), 0, "electronic-circuit", constants.InventoryAssemblingMachineOutput, 50, true),
+				Transfer(assembler.Name(), 0, "electronic-circuit", constants.InventoryAssemblingMachineOutput, 50, true),
 			},
 		},
 		{
@@ -155,11 +153,11 @@ func TestMachineCraft(t *testing.T) {
 			recipe:  "iron-gear-wheel",
 			amount:  50,
 			expectedTasks: Tasks{
-				Recipe(moduledAssembler.Name(), "iron-gear-wheel"),
-				Transfer(moduledAssembler.Name(), "iron-plate", constants.InventoryAssemblingMachineInput, 100, false),
+				Recipe(moduledAssembler.Name(), 0, "iron-gear-wheel"),
+				Transfer(moduledAssembler.Name(), 0, "iron-plate", constants.InventoryAssemblingMachineInput, 100, false),
 
-				WaitInventory(moduledAssembler.Name(), "iron-gear-wheel", constants.InventoryAssemblingMachineOutput, 60, true),
-				Transfer(moduledAssembler.Name(), "iron-gear-wheel", constants.InventoryAssemblingMachineOutput, 60, true),
+				WaitInventory(moduledAssembler.Name(), 0, "iron-gear-wheel", constants.InventoryAssemblingMachineOutput, 60, true),
+				Transfer(moduledAssembler.Name(), 0, "iron-gear-wheel", constants.InventoryAssemblingMachineOutput, 60, true),
 			},
 		},
 		{
@@ -168,18 +166,18 @@ func TestMachineCraft(t *testing.T) {
 			recipe:  "copper-cable",
 			amount:  150,
 			expectedTasks: Tasks{
-				Recipe(moduledAssembler.Name(), "copper-cable"),
+				Recipe(moduledAssembler.Name(), 0, "copper-cable"),
 
-				Transfer(moduledAssembler.Name(), "copper-plate", constants.InventoryAssemblingMachineInput, 100, false),
-				WaitInventory(moduledAssembler.Name(), "copper-cable", constants.InventoryAssemblingMachineOutput, 200, true),
-				Transfer(moduledAssembler.Name(), "copper-cable", constants.InventoryAssemblingMachineOutput, 200, true),
+				Transfer(moduledAssembler.Name(), 0, "copper-plate", constants.InventoryAssemblingMachineInput, 100, false),
+				WaitInventory(moduledAssembler.Name(), 0, "copper-cable", constants.InventoryAssemblingMachineOutput, 200, true),
+				Transfer(moduledAssembler.Name(), 0, "copper-cable", constants.InventoryAssemblingMachineOutput, 200, true),
 
-				WaitInventory(moduledAssembler.Name(), "copper-cable", constants.InventoryAssemblingMachineOutput, 40, true),
-				Transfer(moduledAssembler.Name(), "copper-cable", constants.InventoryAssemblingMachineOutput, 40, true),
+				WaitInventory(moduledAssembler.Name(), 0, "copper-cable", constants.InventoryAssemblingMachineOutput, 40, true),
+				Transfer(moduledAssembler.Name(), 0, "copper-cable", constants.InventoryAssemblingMachineOutput, 40, true),
 
-				Transfer(moduledAssembler.Name(), "copper-plate", constants.InventoryAssemblingMachineInput, 50, false),
-				WaitInventory(moduledAssembler.Name(), "copper-cable", constants.InventoryAssemblingMachineOutput, 120, true),
-				Transfer(moduledAssembler.Name(), "copper-cable", constants.InventoryAssemblingMachineOutput, 120, true),
+				Transfer(moduledAssembler.Name(), 0, "copper-plate", constants.InventoryAssemblingMachineInput, 50, false),
+				WaitInventory(moduledAssembler.Name(), 0, "copper-cable", constants.InventoryAssemblingMachineOutput, 120, true),
+				Transfer(moduledAssembler.Name(), 0, "copper-cable", constants.InventoryAssemblingMachineOutput, 120, true),
 			},
 		},
 		{
@@ -188,9 +186,9 @@ func TestMachineCraft(t *testing.T) {
 			recipe:  "sulfur",
 			amount:  25,
 			expectedTasks: Tasks{
-				Recipe(chemPlant.Name(), "sulfur"),
-				WaitInventory(chemPlant.Name(), "sulfur", constants.InventoryAssemblingMachineOutput, 50, true),
-				Transfer(chemPlant.Name(), "sulfur", constants.InventoryAssemblingMachineOutput, 50, true),
+				Recipe(chemPlant.Name(), 0, "sulfur"),
+				WaitInventory(chemPlant.Name(), 0, "sulfur", constants.InventoryAssemblingMachineOutput, 50, true),
+				Transfer(chemPlant.Name(), 0, "sulfur", constants.InventoryAssemblingMachineOutput, 50, true),
 			},
 		},
 	} {
@@ -212,9 +210,9 @@ func TestMineAndSmelt(t *testing.T) {
 			expectedLeftoverFuel: addr(float64(1.8)),
 			expectedTasks: Tasks{
 				MineResource("iron-ore", 25),
-				Transfer(stoneFurnace.Name(), "iron-ore", constants.InventoryFurnaceSource, 25, false),
-				WaitInventory(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 25, true),
-				Transfer(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 25, true),
+				Transfer(stoneFurnace.Name(), 0, "iron-ore", constants.InventoryFurnaceSource, 25, false),
+				WaitInventory(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 25, true),
+				Transfer(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 25, true),
 			},
 		},
 		{
@@ -225,9 +223,9 @@ func TestMineAndSmelt(t *testing.T) {
 			machine:              stoneFurnace,
 			expectedLeftoverFuel: addr(float64(3.6)),
 			expectedTasks: Tasks{
-				Transfer(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceSource, 50, false),
-				WaitInventory(stoneFurnace.Name(), "steel-plate", constants.InventoryFurnaceResult, 10, true),
-				Transfer(stoneFurnace.Name(), "steel-plate", constants.InventoryFurnaceResult, 10, true),
+				Transfer(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceSource, 50, false),
+				WaitInventory(stoneFurnace.Name(), 0, "steel-plate", constants.InventoryFurnaceResult, 10, true),
+				Transfer(stoneFurnace.Name(), 0, "steel-plate", constants.InventoryFurnaceResult, 10, true),
 			},
 		},
 		{
@@ -239,24 +237,24 @@ func TestMineAndSmelt(t *testing.T) {
 			expectedLeftoverFuel: addr(float64(14.4)),
 			expectedTasks: Tasks{
 				MineResource("iron-ore", 50),
-				Transfer(stoneFurnace.Name(), "iron-ore", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "iron-ore", constants.InventoryFurnaceSource, 50, false),
 				MineResource("iron-ore", 50),
-				WaitInventory(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
-				Transfer(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				WaitInventory(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				Transfer(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
 
-				Transfer(stoneFurnace.Name(), "iron-ore", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "iron-ore", constants.InventoryFurnaceSource, 50, false),
 				MineResource("iron-ore", 50),
-				WaitInventory(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
-				Transfer(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				WaitInventory(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				Transfer(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
 
-				Transfer(stoneFurnace.Name(), "iron-ore", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "iron-ore", constants.InventoryFurnaceSource, 50, false),
 				MineResource("iron-ore", 50),
-				WaitInventory(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
-				Transfer(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				WaitInventory(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				Transfer(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
 
-				Transfer(stoneFurnace.Name(), "iron-ore", constants.InventoryFurnaceSource, 50, false),
-				WaitInventory(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
-				Transfer(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				Transfer(stoneFurnace.Name(), 0, "iron-ore", constants.InventoryFurnaceSource, 50, false),
+				WaitInventory(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				Transfer(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
 			},
 		},
 		{
@@ -268,14 +266,14 @@ func TestMineAndSmelt(t *testing.T) {
 			expectedLeftoverFuel: addr(float64(3.6)),
 			expectedTasks: Tasks{
 				MineResource("stone", 50),
-				Transfer(stoneFurnace.Name(), "stone", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "stone", constants.InventoryFurnaceSource, 50, false),
 				MineResource("stone", 50),
-				WaitInventory(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
-				Transfer(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				WaitInventory(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				Transfer(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
 
-				Transfer(stoneFurnace.Name(), "stone", constants.InventoryFurnaceSource, 50, false),
-				WaitInventory(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
-				Transfer(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				Transfer(stoneFurnace.Name(), 0, "stone", constants.InventoryFurnaceSource, 50, false),
+				WaitInventory(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				Transfer(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
 			},
 		},
 	} {
@@ -298,11 +296,11 @@ func TestMineFuelAndSmelt(t *testing.T) {
 			expectedLeftoverFuel: addr(float64(0.2)),
 			expectedTasks: Tasks{
 				MineResource(constants.PreferredFuel, 2),
-				Transfer(stoneFurnace.Name(), constants.PreferredFuel, constants.InventoryFuel, 2, false),
+				Transfer(stoneFurnace.Name(), 0, constants.PreferredFuel, constants.InventoryFuel, 2, false),
 				MineResource("iron-ore", 25),
-				Transfer(stoneFurnace.Name(), "iron-ore", constants.InventoryFurnaceSource, 25, false),
-				WaitInventory(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 25, true),
-				Transfer(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 25, true),
+				Transfer(stoneFurnace.Name(), 0, "iron-ore", constants.InventoryFurnaceSource, 25, false),
+				WaitInventory(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 25, true),
+				Transfer(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 25, true),
 			},
 		},
 		{
@@ -315,11 +313,11 @@ func TestMineFuelAndSmelt(t *testing.T) {
 			expectedLeftoverFuel: addr(float64(0.1)),
 			expectedTasks: Tasks{
 				MineResource(constants.PreferredFuel, 1),
-				Transfer(stoneFurnace.Name(), constants.PreferredFuel, constants.InventoryFuel, 1, false),
+				Transfer(stoneFurnace.Name(), 0, constants.PreferredFuel, constants.InventoryFuel, 1, false),
 				MineResource("iron-ore", 25),
-				Transfer(stoneFurnace.Name(), "iron-ore", constants.InventoryFurnaceSource, 25, false),
-				WaitInventory(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 25, true),
-				Transfer(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 25, true),
+				Transfer(stoneFurnace.Name(), 0, "iron-ore", constants.InventoryFurnaceSource, 25, false),
+				WaitInventory(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 25, true),
+				Transfer(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 25, true),
 			},
 		},
 		{
@@ -331,10 +329,10 @@ func TestMineFuelAndSmelt(t *testing.T) {
 			expectedLeftoverFuel: addr(float64(0.4)),
 			expectedTasks: Tasks{
 				MineResource(constants.PreferredFuel, 4),
-				Transfer(stoneFurnace.Name(), constants.PreferredFuel, constants.InventoryFuel, 4, false),
-				Transfer(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceSource, 50, false),
-				WaitInventory(stoneFurnace.Name(), "steel-plate", constants.InventoryFurnaceResult, 10, true),
-				Transfer(stoneFurnace.Name(), "steel-plate", constants.InventoryFurnaceResult, 10, true),
+				Transfer(stoneFurnace.Name(), 0, constants.PreferredFuel, constants.InventoryFuel, 4, false),
+				Transfer(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceSource, 50, false),
+				WaitInventory(stoneFurnace.Name(), 0, "steel-plate", constants.InventoryFurnaceResult, 10, true),
+				Transfer(stoneFurnace.Name(), 0, "steel-plate", constants.InventoryFurnaceResult, 10, true),
 			},
 		},
 		{
@@ -347,27 +345,27 @@ func TestMineFuelAndSmelt(t *testing.T) {
 			expectedTasks: Tasks{
 
 				MineResource(constants.PreferredFuel, 15),
-				Transfer(stoneFurnace.Name(), constants.PreferredFuel, constants.InventoryFuel, 15, false),
+				Transfer(stoneFurnace.Name(), 0, constants.PreferredFuel, constants.InventoryFuel, 15, false),
 
 				MineResource("iron-ore", 50),
-				Transfer(stoneFurnace.Name(), "iron-ore", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "iron-ore", constants.InventoryFurnaceSource, 50, false),
 				MineResource("iron-ore", 50),
-				WaitInventory(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
-				Transfer(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				WaitInventory(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				Transfer(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
 
-				Transfer(stoneFurnace.Name(), "iron-ore", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "iron-ore", constants.InventoryFurnaceSource, 50, false),
 				MineResource("iron-ore", 50),
-				WaitInventory(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
-				Transfer(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				WaitInventory(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				Transfer(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
 
-				Transfer(stoneFurnace.Name(), "iron-ore", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "iron-ore", constants.InventoryFurnaceSource, 50, false),
 				MineResource("iron-ore", 50),
-				WaitInventory(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
-				Transfer(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				WaitInventory(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				Transfer(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
 
-				Transfer(stoneFurnace.Name(), "iron-ore", constants.InventoryFurnaceSource, 50, false),
-				WaitInventory(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
-				Transfer(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				Transfer(stoneFurnace.Name(), 0, "iron-ore", constants.InventoryFurnaceSource, 50, false),
+				WaitInventory(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				Transfer(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
 			},
 		},
 		{
@@ -380,95 +378,95 @@ func TestMineFuelAndSmelt(t *testing.T) {
 			expectedLeftoverFuel: addr(float64(0.05)),
 			expectedTasks: Tasks{
 				MineResource(constants.PreferredFuel, 50),
-				Transfer(stoneFurnace.Name(), constants.PreferredFuel, constants.InventoryFuel, 50, false),
+				Transfer(stoneFurnace.Name(), 0, constants.PreferredFuel, constants.InventoryFuel, 50, false),
 
 				MineResource("iron-ore", 50),
-				Transfer(stoneFurnace.Name(), "iron-ore", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "iron-ore", constants.InventoryFurnaceSource, 50, false),
 				MineResource("iron-ore", 50),
-				WaitInventory(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
-				Transfer(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				WaitInventory(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				Transfer(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
 
-				Transfer(stoneFurnace.Name(), "iron-ore", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "iron-ore", constants.InventoryFurnaceSource, 50, false),
 				MineResource("iron-ore", 50),
-				WaitInventory(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
-				Transfer(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				WaitInventory(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				Transfer(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
 
-				Transfer(stoneFurnace.Name(), "iron-ore", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "iron-ore", constants.InventoryFurnaceSource, 50, false),
 				MineResource("iron-ore", 50),
-				WaitInventory(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
-				Transfer(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				WaitInventory(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				Transfer(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
 
-				Transfer(stoneFurnace.Name(), "iron-ore", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "iron-ore", constants.InventoryFurnaceSource, 50, false),
 				MineResource("iron-ore", 50),
-				WaitInventory(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
-				Transfer(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				WaitInventory(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				Transfer(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
 
-				Transfer(stoneFurnace.Name(), "iron-ore", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "iron-ore", constants.InventoryFurnaceSource, 50, false),
 				MineResource("iron-ore", 50),
-				WaitInventory(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
-				Transfer(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				WaitInventory(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				Transfer(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
 
-				Transfer(stoneFurnace.Name(), "iron-ore", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "iron-ore", constants.InventoryFurnaceSource, 50, false),
 				MineResource("iron-ore", 50),
-				WaitInventory(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
-				Transfer(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				WaitInventory(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				Transfer(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
 
-				Transfer(stoneFurnace.Name(), "iron-ore", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "iron-ore", constants.InventoryFurnaceSource, 50, false),
 				MineResource("iron-ore", 50),
-				WaitInventory(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
-				Transfer(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				WaitInventory(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				Transfer(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
 
-				Transfer(stoneFurnace.Name(), "iron-ore", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "iron-ore", constants.InventoryFurnaceSource, 50, false),
 				MineResource("iron-ore", 50),
-				WaitInventory(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
-				Transfer(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				WaitInventory(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				Transfer(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
 
-				Transfer(stoneFurnace.Name(), "iron-ore", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "iron-ore", constants.InventoryFurnaceSource, 50, false),
 				MineResource("iron-ore", 50),
-				WaitInventory(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
-				Transfer(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				WaitInventory(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				Transfer(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
 
-				Transfer(stoneFurnace.Name(), "iron-ore", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "iron-ore", constants.InventoryFurnaceSource, 50, false),
 				MineResource("iron-ore", 50),
-				WaitInventory(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
-				Transfer(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				WaitInventory(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				Transfer(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
 
-				Transfer(stoneFurnace.Name(), "iron-ore", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "iron-ore", constants.InventoryFurnaceSource, 50, false),
 				MineResource("iron-ore", 50),
-				WaitInventory(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
-				Transfer(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				WaitInventory(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				Transfer(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
 
-				Transfer(stoneFurnace.Name(), "iron-ore", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "iron-ore", constants.InventoryFurnaceSource, 50, false),
 				MineResource("iron-ore", 50),
-				WaitInventory(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
-				Transfer(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				WaitInventory(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				Transfer(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
 
-				Transfer(stoneFurnace.Name(), "iron-ore", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "iron-ore", constants.InventoryFurnaceSource, 50, false),
 				MineResource("iron-ore", 50),
-				WaitInventory(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
-				Transfer(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				WaitInventory(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				Transfer(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
 
-				Transfer(stoneFurnace.Name(), "iron-ore", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "iron-ore", constants.InventoryFurnaceSource, 50, false),
 				MineResource("iron-ore", 3),
-				WaitInventory(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
-				Transfer(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				WaitInventory(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				Transfer(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
 
-				Transfer(stoneFurnace.Name(), "iron-ore", constants.InventoryFurnaceSource, 3, false),
-				WaitInventory(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 3, true),
-				Transfer(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 3, true),
+				Transfer(stoneFurnace.Name(), 0, "iron-ore", constants.InventoryFurnaceSource, 3, false),
+				WaitInventory(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 3, true),
+				Transfer(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 3, true),
 
 				MineResource(constants.PreferredFuel, 7),
-				Transfer(stoneFurnace.Name(), constants.PreferredFuel, constants.InventoryFuel, 7, false),
+				Transfer(stoneFurnace.Name(), 0, constants.PreferredFuel, constants.InventoryFuel, 7, false),
 
 				MineResource("iron-ore", 50),
-				Transfer(stoneFurnace.Name(), "iron-ore", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "iron-ore", constants.InventoryFurnaceSource, 50, false),
 				MineResource("iron-ore", 47),
-				WaitInventory(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
-				Transfer(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				WaitInventory(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
+				Transfer(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 50, true),
 
-				Transfer(stoneFurnace.Name(), "iron-ore", constants.InventoryFurnaceSource, 47, false),
-				WaitInventory(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 47, true),
-				Transfer(stoneFurnace.Name(), "iron-plate", constants.InventoryFurnaceResult, 47, true),
+				Transfer(stoneFurnace.Name(), 0, "iron-ore", constants.InventoryFurnaceSource, 47, false),
+				WaitInventory(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 47, true),
+				Transfer(stoneFurnace.Name(), 0, "iron-plate", constants.InventoryFurnaceResult, 47, true),
 			},
 		},
 		{
@@ -480,155 +478,155 @@ func TestMineFuelAndSmelt(t *testing.T) {
 			expectedLeftoverFuel: addr(float64(0.6)),
 			expectedTasks: Tasks{
 				MineResource(constants.PreferredFuel, 50),
-				Transfer(stoneFurnace.Name(), constants.PreferredFuel, constants.InventoryFuel, 50, false),
+				Transfer(stoneFurnace.Name(), 0, constants.PreferredFuel, constants.InventoryFuel, 50, false),
 
 				MineResource("stone", 50),
-				Transfer(stoneFurnace.Name(), "stone", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "stone", constants.InventoryFurnaceSource, 50, false),
 				MineResource("stone", 50),
-				WaitInventory(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
-				Transfer(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				WaitInventory(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				Transfer(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
 
-				Transfer(stoneFurnace.Name(), "stone", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "stone", constants.InventoryFurnaceSource, 50, false),
 				MineResource("stone", 50),
-				WaitInventory(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
-				Transfer(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				WaitInventory(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				Transfer(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
 
-				Transfer(stoneFurnace.Name(), "stone", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "stone", constants.InventoryFurnaceSource, 50, false),
 				MineResource("stone", 50),
-				WaitInventory(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
-				Transfer(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				WaitInventory(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				Transfer(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
 
-				Transfer(stoneFurnace.Name(), "stone", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "stone", constants.InventoryFurnaceSource, 50, false),
 				MineResource("stone", 50),
-				WaitInventory(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
-				Transfer(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				WaitInventory(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				Transfer(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
 
-				Transfer(stoneFurnace.Name(), "stone", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "stone", constants.InventoryFurnaceSource, 50, false),
 				MineResource("stone", 50),
-				WaitInventory(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
-				Transfer(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				WaitInventory(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				Transfer(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
 
-				Transfer(stoneFurnace.Name(), "stone", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "stone", constants.InventoryFurnaceSource, 50, false),
 				MineResource("stone", 50),
-				WaitInventory(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
-				Transfer(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				WaitInventory(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				Transfer(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
 
-				Transfer(stoneFurnace.Name(), "stone", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "stone", constants.InventoryFurnaceSource, 50, false),
 				MineResource("stone", 50),
-				WaitInventory(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
-				Transfer(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				WaitInventory(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				Transfer(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
 
-				Transfer(stoneFurnace.Name(), "stone", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "stone", constants.InventoryFurnaceSource, 50, false),
 				MineResource("stone", 50),
-				WaitInventory(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
-				Transfer(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				WaitInventory(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				Transfer(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
 
-				Transfer(stoneFurnace.Name(), "stone", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "stone", constants.InventoryFurnaceSource, 50, false),
 				MineResource("stone", 50),
-				WaitInventory(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
-				Transfer(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				WaitInventory(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				Transfer(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
 
-				Transfer(stoneFurnace.Name(), "stone", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "stone", constants.InventoryFurnaceSource, 50, false),
 				MineResource("stone", 50),
-				WaitInventory(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
-				Transfer(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				WaitInventory(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				Transfer(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
 
-				Transfer(stoneFurnace.Name(), "stone", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "stone", constants.InventoryFurnaceSource, 50, false),
 				MineResource("stone", 50),
-				WaitInventory(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
-				Transfer(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				WaitInventory(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				Transfer(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
 
-				Transfer(stoneFurnace.Name(), "stone", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "stone", constants.InventoryFurnaceSource, 50, false),
 				MineResource("stone", 50),
-				WaitInventory(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
-				Transfer(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				WaitInventory(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				Transfer(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
 
-				Transfer(stoneFurnace.Name(), "stone", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "stone", constants.InventoryFurnaceSource, 50, false),
 				MineResource("stone", 50),
-				WaitInventory(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
-				Transfer(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				WaitInventory(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				Transfer(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
 
-				Transfer(stoneFurnace.Name(), "stone", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "stone", constants.InventoryFurnaceSource, 50, false),
 				MineResource("stone", 50),
-				WaitInventory(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
-				Transfer(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				WaitInventory(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				Transfer(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
 
-				Transfer(stoneFurnace.Name(), "stone", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "stone", constants.InventoryFurnaceSource, 50, false),
 				MineResource("stone", 50),
-				WaitInventory(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
-				Transfer(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				WaitInventory(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				Transfer(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
 
-				Transfer(stoneFurnace.Name(), "stone", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "stone", constants.InventoryFurnaceSource, 50, false),
 				MineResource("stone", 50),
-				WaitInventory(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
-				Transfer(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				WaitInventory(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				Transfer(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
 
-				Transfer(stoneFurnace.Name(), "stone", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "stone", constants.InventoryFurnaceSource, 50, false),
 				MineResource("stone", 50),
-				WaitInventory(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
-				Transfer(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				WaitInventory(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				Transfer(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
 
-				Transfer(stoneFurnace.Name(), "stone", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "stone", constants.InventoryFurnaceSource, 50, false),
 				MineResource("stone", 50),
-				WaitInventory(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
-				Transfer(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				WaitInventory(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				Transfer(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
 
-				Transfer(stoneFurnace.Name(), "stone", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "stone", constants.InventoryFurnaceSource, 50, false),
 				MineResource("stone", 50),
-				WaitInventory(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
-				Transfer(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				WaitInventory(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				Transfer(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
 
-				Transfer(stoneFurnace.Name(), "stone", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "stone", constants.InventoryFurnaceSource, 50, false),
 				MineResource("stone", 50),
-				WaitInventory(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
-				Transfer(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				WaitInventory(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				Transfer(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
 
-				Transfer(stoneFurnace.Name(), "stone", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "stone", constants.InventoryFurnaceSource, 50, false),
 				MineResource("stone", 50),
-				WaitInventory(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
-				Transfer(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				WaitInventory(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				Transfer(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
 
-				Transfer(stoneFurnace.Name(), "stone", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "stone", constants.InventoryFurnaceSource, 50, false),
 				MineResource("stone", 50),
-				WaitInventory(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
-				Transfer(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				WaitInventory(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				Transfer(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
 
-				Transfer(stoneFurnace.Name(), "stone", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "stone", constants.InventoryFurnaceSource, 50, false),
 				MineResource("stone", 50),
-				WaitInventory(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
-				Transfer(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				WaitInventory(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				Transfer(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
 
-				Transfer(stoneFurnace.Name(), "stone", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "stone", constants.InventoryFurnaceSource, 50, false),
 				MineResource("stone", 50),
-				WaitInventory(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
-				Transfer(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				WaitInventory(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				Transfer(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
 
-				Transfer(stoneFurnace.Name(), "stone", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "stone", constants.InventoryFurnaceSource, 50, false),
 				MineResource("stone", 50),
-				WaitInventory(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
-				Transfer(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				WaitInventory(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				Transfer(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
 
-				Transfer(stoneFurnace.Name(), "stone", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "stone", constants.InventoryFurnaceSource, 50, false),
 				MineResource("stone", 50),
-				WaitInventory(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
-				Transfer(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				WaitInventory(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				Transfer(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
 
-				Transfer(stoneFurnace.Name(), "stone", constants.InventoryFurnaceSource, 50, false),
+				Transfer(stoneFurnace.Name(), 0, "stone", constants.InventoryFurnaceSource, 50, false),
 				MineResource("stone", 38),
-				WaitInventory(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
-				Transfer(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				WaitInventory(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
+				Transfer(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 25, true),
 
-				Transfer(stoneFurnace.Name(), "stone", constants.InventoryFurnaceSource, 38, false),
-				WaitInventory(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 19, true),
-				Transfer(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 19, true),
+				Transfer(stoneFurnace.Name(), 0, "stone", constants.InventoryFurnaceSource, 38, false),
+				WaitInventory(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 19, true),
+				Transfer(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 19, true),
 
 				MineResource(constants.PreferredFuel, 1),
-				Transfer(stoneFurnace.Name(), constants.PreferredFuel, constants.InventoryFuel, 1, false),
+				Transfer(stoneFurnace.Name(), 0, constants.PreferredFuel, constants.InventoryFuel, 1, false),
 
 				MineResource("stone", 12),
-				Transfer(stoneFurnace.Name(), "stone", constants.InventoryFurnaceSource, 12, false),
-				WaitInventory(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 6, true),
-				Transfer(stoneFurnace.Name(), "stone-brick", constants.InventoryFurnaceResult, 6, true),
+				Transfer(stoneFurnace.Name(), 0, "stone", constants.InventoryFurnaceSource, 12, false),
+				WaitInventory(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 6, true),
+				Transfer(stoneFurnace.Name(), 0, "stone-brick", constants.InventoryFurnaceResult, 6, true),
 			},
 		},
 	} {
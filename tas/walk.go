@@ -0,0 +1,175 @@
+package tas
+
+import (
+	"math"
+
+	"github.com/brettschalin/factorio-min-resources/data"
+	"github.com/brettschalin/factorio-min-resources/geo"
+)
+
+// how far inside its reach the character stops when walking to something. The mod counts a
+// walk as done within 0.2 tiles of the destination, so this keeps the target in reach either way
+const walkSlack = 0.5
+
+// target returns the building or resource an action needs to be in reach of
+func target(task Task) (name string, n int, ok bool) {
+	switch t := task.(type) {
+	case *taskBuild:
+		return t.Entity, t.N, true
+	case *taskRecipe:
+		return t.Entity, t.N, true
+	case *taskPut:
+		return t.Entity, t.N, true
+	case *taskTake:
+		return t.Entity, t.N, true
+	case *taskMine:
+		if t.Resource != "" {
+			return t.Resource, 0, true
+		}
+		return t.Entity, t.N, true
+	case *taskLaunch:
+		return "rocket-silo", 0, true
+	}
+	return "", 0, false
+}
+
+// walker keeps track of where the character is while walks are added
+type walker struct {
+	char     *data.Character
+	loc      Locator
+	pos      geo.Point
+	distance float64
+}
+
+// walkTo returns where the character has to go to reach the task's target, and whether it needs to move at all
+func (w *walker) walkTo(task Task) (geo.Point, bool) {
+	name, n, ok := target(task)
+	if !ok {
+		return w.pos, false
+	}
+	dest, ok := w.loc(name, n)
+	if !ok {
+		return w.pos, false
+	}
+
+	r := reach(w.char, task)
+	if w.pos.Distance(dest) <= r {
+		return w.pos, false
+	}
+
+	var boxes []geo.Rectangle
+	if box, ok := data.CollisionBox(name); ok {
+		boxes = append(boxes, box)
+	}
+	return dest.ClosestWithin(w.pos, r-walkSlack, boxes...), true
+}
+
+// cost returns how far the character would walk to do the task from where it is now
+func (w *walker) cost(task Task) float64 {
+	dest, ok := w.walkTo(task)
+	if !ok {
+		return 0
+	}
+	return w.pos.PathDistance(dest)
+}
+
+// visit adds the task, preceded by a walk if it's out of reach
+func (w *walker) visit(out *Tasks, task Task) {
+	if walk, ok := task.(*taskWalk); ok {
+		w.distance += w.pos.PathDistance(walk.Location)
+		w.pos = walk.Location
+	} else if dest, ok := w.walkTo(task); ok {
+		out.Add(Walk(dest))
+		w.distance += w.pos.PathDistance(dest)
+		w.pos = dest
+	}
+	out.Add(task)
+}
+
+// reorderable returns whether a task can be moved around within a run of similar tasks. Only transfers
+// without prerequisites are, since they don't depend on anything but the inventories they touch
+func reorderable(task Task) bool {
+	switch task.(type) {
+	case *taskPut, *taskTake:
+		return len(*task.Prerequisites()) == 0
+	}
+	return false
+}
+
+// transfer returns what a put or take moves and whether it's a take
+func transfer(task Task) (entity string, n int, item string, take bool) {
+	switch t := task.(type) {
+	case *taskPut:
+		return t.Entity, t.N, t.Item, false
+	case *taskTake:
+		return t.Entity, t.N, t.Item, true
+	}
+	return "", 0, "", false
+}
+
+// conflicts returns whether two transfers have to stay in the order they were added: they touch the same
+// building, or one takes an item the other puts somewhere
+func conflicts(a, b Task) bool {
+	ae, an, ai, at := transfer(a)
+	be, bn, bi, bt := transfer(b)
+	return (ae == be && an == bn) || (ai == bi && at != bt)
+}
+
+// InsertWalks adds a Walk before every action whose target is out of reach of where the character will
+// be, so the walking is planned here instead of left for the mod to work out. Runs of transfers without
+// prerequisites are reordered to visit the closest building next, as long as that doesn't change what they do.
+// `loc` finds the targets and `start` is where the character is when the tasks begin.
+// Returns the total walking distance, including any walks already in the TAS
+func (t *TAS) InsertWalks(loc Locator, start geo.Point) (float64, error) {
+
+	w := &walker{
+		char: data.GetCharacter(),
+		loc:  loc,
+		pos:  start,
+	}
+	out := make(Tasks, 0, len(t.tasks))
+
+	for i := 0; i < len(t.tasks); {
+		if !reorderable(t.tasks[i]) {
+			w.visit(&out, t.tasks[i])
+			i++
+			continue
+		}
+
+		end := i
+		for end < len(t.tasks) && reorderable(t.tasks[end]) {
+			end++
+		}
+		run := append(Tasks{}, t.tasks[i:end]...)
+		i = end
+
+		for len(run) > 0 {
+			best, bestCost := -1, math.Inf(1)
+			for j, task := range run {
+				blocked := false
+				for _, earlier := range run[:j] {
+					if conflicts(earlier, task) {
+						blocked = true
+						break
+					}
+				}
+				if blocked {
+					continue
+				}
+				if c := w.cost(task); c < bestCost {
+					best, bestCost = j, c
+				}
+			}
+			w.visit(&out, run[best])
+			run = append(run[:best], run[best+1:]...)
+		}
+	}
+
+	old := t.tasks
+	t.tasks = out
+	if err := t.Verify(); err != nil {
+		t.tasks = old
+		return 0, err
+	}
+	return w.distance, nil
+}